@@ -0,0 +1,140 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const archiveManifestName = "manifest.json"
+
+// archiveManifest describes the dump stored in an archive.
+type archiveManifest struct {
+	Version  string `json:"version"`
+	Comparer string `json:"comparer"`
+	Format   string `json:"format"`
+	Entries  int    `json:"entries"`
+}
+
+// archiveEncoder writes a tar archive holding a manifest followed by a
+// dump in the inner format. The dump is buffered because tar needs its
+// size up front.
+type archiveEncoder struct {
+	w        io.Writer
+	manifest archiveManifest
+	buf      *bytes.Buffer
+	enc      dumpEncoder
+}
+
+func newArchiveEncoder(w io.Writer, format string, nentries int, hdr dumpHeader) (*archiveEncoder, error) {
+	if format == "archive" {
+		return nil, errors.New("archives cannot be nested")
+	}
+	buf := new(bytes.Buffer)
+	enc, err := newDumpEncoder(format, buf, nentries, hdr)
+	if err != nil {
+		return nil, err
+	}
+	manifest := archiveManifest{
+		Version:  getVersion(),
+		Comparer: hdr.Comparer,
+		Format:   format,
+		Entries:  nentries,
+	}
+	return &archiveEncoder{w, manifest, buf, enc}, nil
+}
+
+func (e *archiveEncoder) Encode(key, value []byte) error {
+	return e.enc.Encode(key, value)
+}
+
+func (e *archiveEncoder) Close() error {
+	if err := e.enc.Close(); err != nil {
+		return err
+	}
+	manifest, err := json.MarshalIndent(e.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifest = append(manifest, '\n')
+
+	now := time.Now()
+	tw := tar.NewWriter(e.w)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{archiveManifestName, manifest},
+		{"dump." + e.manifest.Format, e.buf.Bytes()},
+	} {
+		hdr := &tar.Header{
+			Name:    file.name,
+			Mode:    0o644,
+			Size:    int64(len(file.data)),
+			ModTime: now,
+			Format:  tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// archiveDecoder reads the dump stored in an archive written by
+// archiveEncoder.
+type archiveDecoder struct {
+	manifest archiveManifest
+	dec      dumpDecoder
+}
+
+func newArchiveDecoder(r io.Reader) (*archiveDecoder, error) {
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		return nil, errors.New("archive: empty archive")
+	} else if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	if hdr.Name != archiveManifestName {
+		return nil, fmt.Errorf("archive: expected %s, found %s", archiveManifestName, hdr.Name)
+	}
+	var manifest archiveManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("archive: %s: %w", archiveManifestName, err)
+	}
+	if manifest.Format == "archive" || manifest.Format == "auto" {
+		return nil, fmt.Errorf("archive: %s: invalid format %q", archiveManifestName, manifest.Format)
+	}
+
+	if _, err := tr.Next(); err == io.EOF {
+		return nil, errors.New("archive: missing dump")
+	} else if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	dec, err := newDumpDecoder(manifest.Format, tr)
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	return &archiveDecoder{manifest, dec}, nil
+}
+
+func (d *archiveDecoder) Decode() ([]byte, []byte, error) {
+	return d.dec.Decode()
+}
+
+// ComparerName returns the name of the comparer of the dumped database.
+func (d *archiveDecoder) ComparerName() string {
+	return d.manifest.Comparer
+}
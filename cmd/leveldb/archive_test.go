@@ -0,0 +1,97 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/cions/leveldb-cli/indexeddb"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	entries := []entry{
+		{Key: []byte(""), Value: []byte("")},
+		{Key: []byte("a,b"), Value: []byte("\"quoted\"\r\n")},
+		{Key: []byte("\x00\xff"), Value: bytes.Repeat([]byte("\x80"), 100)},
+	}
+
+	for _, format := range []string{"msgpack", "csv"} {
+		buf := new(bytes.Buffer)
+		enc, err := newArchiveEncoder(buf, format, len(entries), dumpHeader{Comparer: "idb_cmp1"})
+		if err != nil {
+			t.Fatalf("newArchiveEncoder(%q): unexpected error: %v", format, err)
+		}
+		for _, e := range entries {
+			if err := enc.Encode(e.Key, e.Value); err != nil {
+				t.Fatalf("%s: Encode: unexpected error: %v", format, err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("%s: Close: unexpected error: %v", format, err)
+		}
+
+		dec, err := newDumpDecoder("auto", buf)
+		if err != nil {
+			t.Fatalf("%s: newDumpDecoder: unexpected error: %v", format, err)
+		}
+		if cn, ok := dec.(comparerNamer); !ok {
+			t.Errorf("%s: decoder does not record the comparer", format)
+		} else if cn.ComparerName() != "idb_cmp1" {
+			t.Errorf("%s: ComparerName() = %q, want %q", format, cn.ComparerName(), "idb_cmp1")
+		}
+		for i, e := range entries {
+			key, value, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("%s: entry %d: unexpected error: %v", format, i, err)
+			}
+			if !bytes.Equal(key, e.Key) || !bytes.Equal(value, e.Value) {
+				t.Errorf("%s: entry %d = (%q, %q), want (%q, %q)", format, i, key, value, e.Key, e.Value)
+			}
+		}
+		if _, _, err := dec.Decode(); err != io.EOF {
+			t.Errorf("%s: expected io.EOF, got %v", format, err)
+		}
+	}
+
+	if _, err := newArchiveEncoder(io.Discard, "archive", 0, dumpHeader{}); err == nil {
+		t.Error("newArchiveEncoder(\"archive\") should fail")
+	}
+}
+
+func TestLoadArchiveComparer(t *testing.T) {
+	archive := new(bytes.Buffer)
+	enc, err := newArchiveEncoder(archive, "msgpack", 1, dumpHeader{Comparer: indexeddb.Comparer.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(idbNumberKey(1), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = loadDB(context.Background(), t.TempDir(), &opt.Options{}, bytes.NewReader(archive.Bytes()), &loadOptions{Format: "auto", StrictComparer: true})
+	if err == nil {
+		t.Error("loadDB: expected a comparer mismatch error")
+	}
+
+	dbpath := t.TempDir()
+	if err := loadDB(context.Background(), dbpath, &opt.Options{}, bytes.NewReader(archive.Bytes()), &loadOptions{Format: "auto"}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := leveldb.OpenFile(dbpath, &opt.Options{Comparer: indexeddb.Comparer, ErrorIfMissing: true})
+	if err != nil {
+		t.Fatalf("the loaded database cannot be opened with idb_cmp1: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Get(idbNumberKey(1), nil); err != nil {
+		t.Errorf("Get: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/urfave/cli/v2"
+)
+
+// copyFile copies src to dst, which must not exist.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copyDBFiles copies the files of the database at src into dst. Tables
+// are immutable, so they are hard-linked where possible. CURRENT is
+// written last, so dst cannot be opened until the copy is complete. The
+// database must be kept open to prevent concurrent writers.
+func copyDBFiles(ctx context.Context, src, dst string) (int, error) {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return 0, err
+	}
+
+	nfiles := 0
+	for _, e := range entries {
+		name := e.Name()
+		if !leveldbFilenamePattern.MatchString(name) || e.IsDir() {
+			continue
+		}
+		switch {
+		case name == "LOCK", strings.HasPrefix(name, "LOG"), strings.HasPrefix(name, "CURRENT"), strings.HasSuffix(name, ".tmp"):
+			continue
+		}
+		if err := checkContext(ctx); err != nil {
+			return nfiles, err
+		}
+
+		from, to := filepath.Join(src, name), filepath.Join(dst, name)
+		if strings.HasSuffix(name, ".ldb") || strings.HasSuffix(name, ".sst") {
+			if err := os.Link(from, to); err == nil {
+				nfiles++
+				continue
+			}
+		}
+		if err := copyFile(from, to); err != nil {
+			return nfiles, err
+		}
+		nfiles++
+	}
+
+	tmp := filepath.Join(dst, "CURRENT.tmp")
+	if err := copyFile(filepath.Join(src, "CURRENT"), tmp); err != nil {
+		return nfiles, err
+	}
+	if err := os.Rename(tmp, filepath.Join(dst, "CURRENT")); err != nil {
+		os.Remove(tmp)
+		return nfiles, err
+	}
+	return nfiles + 1, nil
+}
+
+// isEmptyDir reports whether dir is an empty directory. A missing
+// directory counts as empty.
+func isEmptyDir(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
+
+func backupCmd(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	dbpath := c.String("dbpath")
+	dest, err := resolveDBPath(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	if empty, err := isEmptyDir(dest); err != nil {
+		return err
+	} else if !empty {
+		return fmt.Errorf("%s is not empty", dest)
+	}
+
+	// Holding the database open keeps other processes from writing to it
+	// while the files are copied.
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = true
+	db, err := openDB(dbpath, o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, serr := os.Stat(dest); errors.Is(serr, fs.ErrNotExist) {
+		if err := os.MkdirAll(dest, 0o755); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				os.RemoveAll(dest)
+			}
+		}()
+	}
+
+	nfiles, err := copyDBFiles(c.Context, dbpath, dest)
+	if err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	check, err := openDB(dest, o)
+	if err != nil {
+		return fmt.Errorf("the backup cannot be opened: %w", err)
+	}
+	if err := check.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Copied %d files to %s\n", nfiles, dest)
+	return nil
+}
+
+// moveDBFiles moves the files of a database copied by copyDBFiles from
+// src into dst, CURRENT last.
+func moveDBFiles(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !leveldbFilenamePattern.MatchString(name) || e.IsDir() {
+			continue
+		}
+		switch {
+		case name == "LOCK", strings.HasPrefix(name, "LOG"), strings.HasPrefix(name, "CURRENT"):
+			continue
+		}
+		if err := os.Rename(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(filepath.Join(src, "CURRENT"), filepath.Join(dst, "CURRENT"))
+}
+
+// restoreDB replaces the database at dbpath with a copy of the database at
+// src, which must be kept open by the caller. The copy is made in a staging
+// directory inside dbpath and checked before the database at dbpath is
+// destroyed, so a failed copy leaves it intact.
+func restoreDB(ctx context.Context, src, dbpath string, o *opt.Options) (int, error) {
+	if err := os.MkdirAll(dbpath, 0o755); err != nil {
+		return 0, err
+	}
+	staging, err := os.MkdirTemp(dbpath, "restore-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(staging)
+
+	nfiles, err := copyDBFiles(ctx, src, staging)
+	if err != nil {
+		return 0, err
+	}
+	check, err := openDB(staging, o)
+	if err != nil {
+		return 0, fmt.Errorf("the restored database cannot be opened: %w", err)
+	}
+	if err := check.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := destroyDB(dbpath, false); err != nil {
+		return 0, err
+	}
+	if err := moveDBFiles(staging, dbpath); err != nil {
+		return 0, err
+	}
+	return nfiles, nil
+}
+
+func restoreCmd(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	dbpath := c.String("dbpath")
+	src, err := resolveDBPath(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(dbpath); err == nil {
+		if asrc, err := filepath.Abs(src); err == nil && abs == asrc {
+			return errors.New("cannot restore a database onto itself")
+		}
+	}
+
+	// Opening the source checks that it is a consistent database and
+	// keeps other processes from writing to it while it is copied.
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = true
+	db, err := openDB(src, o)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid database: %w", src, err)
+	}
+	defer db.Close()
+
+	empty, err := isEmptyDir(dbpath)
+	if err != nil {
+		return err
+	}
+	if !empty {
+		if !c.Bool("force") {
+			return fmt.Errorf("%s is not empty; use --force to replace the database in it", dbpath)
+		}
+		if needConfirmation(c) {
+			if ok, err := confirm(fmt.Sprintf("Replace the database at %s?", dbpath)); err != nil {
+				return err
+			} else if !ok {
+				return errAborted
+			}
+		}
+	}
+
+	nfiles, err := restoreDB(c.Context, src, dbpath, o)
+	if err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	check, err := openDB(dbpath, o)
+	if err != nil {
+		return fmt.Errorf("the restored database cannot be opened: %w", err)
+	}
+	if err := check.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Copied %d files from %s\n", nfiles, src)
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestCopyDBFiles(t *testing.T) {
+	src := t.TempDir()
+	db, err := leveldb.OpenFile(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 100 {
+		if err := db.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("value"), nil); err != nil {
+			t.Fatal(err)
+		}
+		if i == 49 {
+			// Leave half of the entries in the journal.
+			if err := db.CompactRange(util.Range{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = leveldb.OpenFile(src, &opt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "backup")
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := copyDBFiles(context.Background(), src, dst); err != nil {
+		t.Fatalf("copyDBFiles: unexpected error: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = leveldb.OpenFile(dst, &opt.Options{ErrorIfMissing: true})
+	if err != nil {
+		t.Fatalf("the copy cannot be opened: %v", err)
+	}
+	defer db.Close()
+	for i := range 100 {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		if _, err := db.Get(key, nil); err != nil {
+			t.Errorf("Get(%q): unexpected error: %v", key, err)
+		}
+	}
+}
+
+func TestRestoreDB(t *testing.T) {
+	src := newTestDB(t, "new")
+	db, err := leveldb.OpenFile(src, &opt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	o := &opt.Options{ErrorIfMissing: true, ReadOnly: true}
+
+	// A failed copy leaves the target intact.
+	dbpath := newTestDB(t, "old")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := restoreDB(ctx, src, dbpath, o); err == nil {
+		t.Error("restoreDB(cancelled): expected an error")
+	}
+	if got, want := dbKeys(t, dbpath), []string{"old"}; !slices.Equal(got, want) {
+		t.Errorf("after a failed restore: keys = %q, want %q", got, want)
+	}
+
+	other := filepath.Join(dbpath, "notes.txt")
+	if err := os.WriteFile(other, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restoreDB(context.Background(), src, dbpath, o); err != nil {
+		t.Fatalf("restoreDB: unexpected error: %v", err)
+	}
+	if got, want := dbKeys(t, dbpath), []string{"new"}; !slices.Equal(got, want) {
+		t.Errorf("after restoring: keys = %q, want %q", got, want)
+	}
+	entries, err := os.ReadDir(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			t.Errorf("restoreDB left %s behind", e.Name())
+		}
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("restoreDB removed an unrelated file: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cions/leveldb-cli/format"
+	"github.com/fatih/color"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/urfave/cli/v2"
+)
+
+type batchOp struct {
+	Delete bool
+	Key    []byte
+	Value  []byte
+}
+
+func parseBatchScript(r io.Reader, decode func([]byte) ([]byte, error)) ([]batchOp, error) {
+	var ops []batchOp
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64*1024*1024)
+	lineno := 0
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := splitArgs(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+
+		var op batchOp
+		switch args[0] {
+		case "put":
+			if len(args) != 3 {
+				return nil, fmt.Errorf("line %d: usage: put <key> <value>", lineno)
+			}
+		case "delete":
+			if len(args) != 2 {
+				return nil, fmt.Errorf("line %d: usage: delete <key>", lineno)
+			}
+			op.Delete = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown operation %q", lineno, args[0])
+		}
+
+		if op.Key, err = decode([]byte(args[1])); err != nil {
+			return nil, fmt.Errorf("line %d: key: %w", lineno, err)
+		}
+		if !op.Delete {
+			if op.Value, err = decode([]byte(args[2])); err != nil {
+				return nil, fmt.Errorf("line %d: value: %w", lineno, err)
+			}
+		}
+		ops = append(ops, op)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+}
+
+func batchCmd(c *cli.Context) error {
+	if c.NArg() > 1 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	var r io.Reader = os.Stdin
+	if name := c.Args().First(); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ops, err := parseBatchScript(r, func(arg []byte) ([]byte, error) {
+		return decodeArg(c, arg)
+	})
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		return errors.New("no operations")
+	}
+
+	if c.Bool("dry-run") {
+		keywriter := format.NewFormatter(color.Output).SetQuoting(true)
+		valuewriter := format.NewFormatter(color.Output).
+			SetQuoting(true).
+			SetTruncate(format.DefaultTruncate)
+		for _, op := range ops {
+			if op.Delete {
+				fmt.Print("Would delete ")
+				keywriter.Write(op.Key)
+			} else {
+				fmt.Print("Would put ")
+				keywriter.Write(op.Key)
+				fmt.Print(": ")
+				valuewriter.Write(op.Value)
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	for _, op := range ops {
+		if op.Delete {
+			batch.Delete(op.Key)
+		} else {
+			batch.Put(op.Key, op.Value)
+		}
+	}
+
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	db, err := openDB(c.String("dbpath"), o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Write(batch, getWriteOptions(c)); err != nil {
+		return err
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
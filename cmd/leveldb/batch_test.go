@@ -0,0 +1,48 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cions/leveldb-cli/format"
+)
+
+func TestParseBatchScript(t *testing.T) {
+	script := `# migration
+put key1 value1
+put "key 2" 'a\x00b'
+
+delete \x01key
+`
+	want := []batchOp{
+		{Key: []byte("key1"), Value: []byte("value1")},
+		{Key: []byte("key 2"), Value: []byte("a\x00b")},
+		{Delete: true, Key: []byte("\x01key")},
+	}
+
+	got, err := parseBatchScript(strings.NewReader(script), format.Unescape)
+	if err != nil {
+		t.Fatalf("parseBatchScript: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBatchScript() = %v, want %v", got, want)
+	}
+
+	for _, script := range []string{
+		"get key",
+		"put key",
+		"put key value extra",
+		"delete",
+		"delete key1 key2",
+		"put 'key value",
+		`put key \xZZ`,
+	} {
+		if _, err := parseBatchScript(strings.NewReader(script), format.Unescape); err == nil {
+			t.Errorf("parseBatchScript(%q) should fail", script)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/urfave/cli/v2"
+)
+
+// benchResult is the throughput of one phase of the benchmark.
+type benchResult struct {
+	Name    string
+	Ops     int
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+func (r benchResult) String() string {
+	secs := r.Elapsed.Seconds()
+	return fmt.Sprintf("%-6s %8d ops in %8.3fs  %12.0f ops/s  %8.2f MB/s",
+		r.Name+":", r.Ops, secs, float64(r.Ops)/secs, float64(r.Bytes)/secs/1e6)
+}
+
+// benchKeys returns n random keys of size bytes generated from seed.
+func benchKeys(n, size int, seed int64) [][]byte {
+	rng := rand.New(rand.NewSource(seed))
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = make([]byte, size)
+		rng.Read(keys[i])
+	}
+	return keys
+}
+
+func benchCmd(c *cli.Context) error {
+	if c.NArg() != 0 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	n, keySize, valueSize, ngets := c.Int("entries"), c.Int("key-size"), c.Int("value-size"), c.Int("gets")
+	if n < 1 {
+		return errors.New("option --entries: must be positive")
+	}
+	if keySize < 1 {
+		return errors.New("option --key-size: must be positive")
+	}
+	if valueSize < 0 {
+		return errors.New("option --value-size: must not be negative")
+	}
+	if !c.IsSet("gets") {
+		ngets = n
+	}
+
+	// The benchmark runs on a scratch database, never on --dbpath.
+	dbpath, err := os.MkdirTemp("", "leveldb-bench-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dbpath)
+
+	o := getOptions(c)
+	db, err := openDB(dbpath, o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	seed := c.Int64("seed")
+	keys := benchKeys(n, keySize, seed)
+	value := make([]byte, valueSize)
+	rand.New(rand.NewSource(seed)).Read(value)
+	entryBytes := int64(keySize + valueSize)
+
+	var results []benchResult
+
+	start := time.Now()
+	batch := new(leveldb.Batch)
+	for _, key := range keys {
+		if err := checkContext(c.Context); err != nil {
+			return err
+		}
+		batch.Put(key, value)
+		if batch.Len() >= clearBatchSize {
+			if err := db.Write(batch, getWriteOptions(c)); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := db.Write(batch, getWriteOptions(c)); err != nil {
+		return err
+	}
+	results = append(results, benchResult{"write", n, int64(n) * entryBytes, time.Since(start)})
+
+	start = time.Now()
+	nscanned := 0
+	var scanned int64
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		if err := checkContext(c.Context); err != nil {
+			iter.Release()
+			return err
+		}
+		nscanned++
+		scanned += int64(len(iter.Key()) + len(iter.Value()))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	results = append(results, benchResult{"scan", nscanned, scanned, time.Since(start)})
+
+	rng := rand.New(rand.NewSource(seed + 1))
+	start = time.Now()
+	var got int64
+	for range ngets {
+		if err := checkContext(c.Context); err != nil {
+			return err
+		}
+		key := keys[rng.Intn(n)]
+		v, err := db.Get(key, nil)
+		if err != nil {
+			return err
+		}
+		got += int64(len(key) + len(v))
+	}
+	results = append(results, benchResult{"get", ngets, got, time.Since(start)})
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d entries, %d-byte keys, %d-byte values, seed %d\n", n, keySize, valueSize, seed)
+	for _, r := range results {
+		fmt.Println(r)
+	}
+	return nil
+}
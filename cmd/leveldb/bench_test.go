@@ -0,0 +1,28 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestBenchKeys(t *testing.T) {
+	a := benchKeys(10, 8, 42)
+	if len(a) != 10 {
+		t.Fatalf("benchKeys returned %d keys, want 10", len(a))
+	}
+	for _, key := range a {
+		if len(key) != 8 {
+			t.Errorf("benchKeys returned a key of %d bytes, want 8", len(key))
+		}
+	}
+	if b := benchKeys(10, 8, 42); !slices.EqualFunc(a, b, bytes.Equal) {
+		t.Error("benchKeys is not deterministic for the same seed")
+	}
+	if b := benchKeys(10, 8, 43); slices.EqualFunc(a, b, bytes.Equal) {
+		t.Error("benchKeys returned the same keys for different seeds")
+	}
+}
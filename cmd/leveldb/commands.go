@@ -4,29 +4,215 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 	"regexp"
-
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cions/leveldb-cli/format"
 	"github.com/cions/leveldb-cli/indexeddb"
+	"github.com/cions/leveldb-cli/localstorage"
 	"github.com/fatih/color"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/comparer"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/table"
 	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/urfave/cli/v2"
-	"github.com/vmihailenco/msgpack/v5"
 )
 
-var leveldbFilenamePattern = regexp.MustCompile(`\A(?:LOCK|LOG(?:\.old)?|CURRENT(?:\.bak|\.\d+)?|MANIFEST-\d+|\d+\.(?:ldb|log|sst|tmp))\z`)
+var (
+	leveldbFilenamePattern = regexp.MustCompile(`\A(?:LOCK|LOG(?:\.old)?|CURRENT(?:\.bak|\.\d+)?|MANIFEST-\d+|\d+\.(?:ldb|log|sst|tmp))\z`)
+	tableFilenamePattern   = regexp.MustCompile(`\A\d+\.(?:ldb|sst)\z`)
+)
+
+// destroyWorkers is the number of goroutines used by destroy --fast.
+const destroyWorkers = 16
 
 type entry struct {
 	Key, Value []byte
 }
 
+type conflictPolicy int
+
+const (
+	overwriteOnConflict conflictPolicy = iota
+	skipOnConflict
+	errorOnConflict
+)
+
+type dumpOptions struct {
+	Format        string
+	ArchiveFormat string
+	Columns       dumpColumns
+	NoHeader      bool
+	Parallel      int
+	Range         *util.Range
+	MaxEntries    int
+	// Preview, if not nil, is called with the first PreviewEntries
+	// entries as they are encoded.
+	Preview        func(key, value []byte) error
+	PreviewEntries int
+}
+
+type loadOptions struct {
+	Format   string
+	Conflict conflictPolicy
+	Sync     bool
+	// StrictComparer makes a dump recorded with another comparer an error
+	// instead of switching to that comparer.
+	StrictComparer bool
+	// NoHeader reads a CSV dump written without a header.
+	NoHeader bool
+}
+
+var (
+	errTimeout     = errors.New("operation timed out")
+	errInterrupted = errors.New("interrupted")
+	errAborted     = errors.New("aborted")
+
+	errDatabaseLocked = errors.New("database is locked by another process")
+	// errKeyNotFound is returned only by getCmd; it exits with status 4.
+	errKeyNotFound        = errors.New("key not found")
+	errInvalidKeyEncoding = errors.New("invalid encoding")
+	errComparerMismatch   = errors.New("comparer mismatch")
+)
+
+// openDB opens the database at dbpath, wrapping the errors that callers
+// may want to tell apart with the sentinels above.
+func openDB(dbpath string, o *opt.Options) (*leveldb.DB, error) {
+	if o.GetErrorIfMissing() {
+		if err := checkDBPath(dbpath); err != nil {
+			return nil, err
+		}
+	}
+	if fc, ok := o.GetComparer().(*forcedComparer); ok {
+		o = forceComparer(dbpath, o, fc)
+	}
+	logOptions(dbpath, o)
+	start := time.Now()
+	db, err := leveldb.OpenFile(dbpath, o)
+	if err != nil {
+		logf("opening %s failed: %v", dbpath, err)
+		return nil, wrapOpenError(err)
+	}
+	logPhase("opening "+dbpath, start)
+	return db, nil
+}
+
+// forcedComparer orders keys like Comparer but reports the comparer name
+// recorded in the database, so that databases created with a comparer of
+// another name can be opened. It is used for --force-comparer.
+type forcedComparer struct {
+	comparer.Comparer
+	name string
+}
+
+func (fc *forcedComparer) Name() string {
+	if fc.name != "" {
+		return fc.name
+	}
+	return fc.Comparer.Name()
+}
+
+// forceComparer returns a copy of o whose comparer reports the name
+// recorded in the database at dbpath, if it differs from that of fc.
+func forceComparer(dbpath string, o *opt.Options, fc *forcedComparer) *opt.Options {
+	info, err := readManifest(dbpath)
+	if err != nil || info.Comparer == "" || info.Comparer == fc.Comparer.Name() {
+		return o
+	}
+	fmt.Fprintf(os.Stderr, "leveldb: warning: opening a database of comparer %q with the %q comparer; keys may be misordered, and writes may corrupt the database\n", info.Comparer, fc.Comparer.Name())
+	fo := *o
+	fo.Comparer = &forcedComparer{Comparer: fc.Comparer, name: info.Comparer}
+	return &fo
+}
+
+func wrapOpenError(err error) error {
+	if errors.Is(err, storage.ErrLocked) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK) {
+		return fmt.Errorf("%w: %w", errDatabaseLocked, err)
+	}
+	// goleveldb reports a comparer mismatch as a corrupted manifest.
+	var corrupted *lerrors.ErrCorrupted
+	if errors.As(err, &corrupted) {
+		var manifest *leveldb.ErrManifestCorrupted
+		if errors.As(corrupted.Err, &manifest) && manifest.Field == "comparer" {
+			return fmt.Errorf("%w: %w (use --force-comparer to open it anyway)", errComparerMismatch, err)
+		}
+	}
+	return err
+}
+
+func checkContext(ctx context.Context) error {
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errTimeout
+	case errors.Is(err, context.Canceled):
+		return errInterrupted
+	default:
+		return err
+	}
+}
+
+// needConfirmation reports whether destructive operations should ask the
+// user for confirmation.
+func needConfirmation(c *cli.Context) bool {
+	return !c.Bool("yes") && isTerminal(os.Stdin)
+}
+
+func confirm(prompt string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// comparerByName returns the comparer with the given name.
+func comparerByName(name string) (comparer.Comparer, error) {
+	for _, cmp := range []comparer.Comparer{comparer.DefaultComparer, indexeddb.Comparer} {
+		if cmp.Name() == name {
+			return cmp, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported comparer %q", name)
+}
+
+func getWriteOptions(c *cli.Context) *opt.WriteOptions {
+	return &opt.WriteOptions{Sync: c.Bool("sync")}
+}
+
 func getComparer(c *cli.Context) comparer.Comparer {
 	if c.Bool("indexeddb") {
 		return indexeddb.Comparer
@@ -34,15 +220,150 @@ func getComparer(c *cli.Context) comparer.Comparer {
 	return comparer.DefaultComparer
 }
 
+func decodeLocalStorageKey(key []byte) ([]byte, error) {
+	origin, scriptKey, err := localstorage.DecodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(origin, []byte(" / "), scriptKey), nil
+}
+
+func getOptions(c *cli.Context) *opt.Options {
+	o := &opt.Options{
+		Comparer: getComparer(c),
+	}
+	if c.Bool("force-comparer") {
+		o.Comparer = &forcedComparer{Comparer: o.Comparer}
+	}
+	if c.IsSet("block-cache-size") {
+		o.BlockCacheCapacity = c.Int("block-cache-size") * opt.MiB
+		if o.BlockCacheCapacity == 0 {
+			o.BlockCacheCapacity = -1
+		}
+	}
+	if c.IsSet("bloom-filter-bits") {
+		o.Filter = filter.NewBloomFilter(c.Int("bloom-filter-bits"))
+	}
+	return o
+}
+
+func getHashFunc(c *cli.Context) (func() hash.Hash, error) {
+	switch algo := c.String("hash-algo"); algo {
+	case "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "md5":
+		return md5.New, nil
+	case "crc32":
+		return func() hash.Hash { return crc32.NewIEEE() }, nil
+	default:
+		return nil, fmt.Errorf("option --hash-algo: unknown algorithm %q", algo)
+	}
+}
+
+type sliceSpec struct {
+	start, end *int
+}
+
+func parseSliceSpec(s string) (sliceSpec, error) {
+	startStr, endStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return sliceSpec{}, fmt.Errorf("invalid slice %q: expected START:END", s)
+	}
+
+	var spec sliceSpec
+	if startStr != "" {
+		start, err := strconv.Atoi(startStr)
+		if err != nil {
+			return sliceSpec{}, fmt.Errorf("invalid slice %q: %w", s, err)
+		}
+		spec.start = &start
+	}
+	if endStr != "" {
+		end, err := strconv.Atoi(endStr)
+		if err != nil {
+			return sliceSpec{}, fmt.Errorf("invalid slice %q: %w", s, err)
+		}
+		spec.end = &end
+	}
+	return spec, nil
+}
+
+func (spec sliceSpec) Apply(b []byte) []byte {
+	index := func(i int) int {
+		if i < 0 {
+			i += len(b)
+		}
+		return min(max(i, 0), len(b))
+	}
+
+	start, end := 0, len(b)
+	if spec.start != nil {
+		start = index(*spec.start)
+	}
+	if spec.end != nil {
+		end = index(*spec.end)
+	}
+	if start >= end {
+		return b[:0]
+	}
+	return b[start:end]
+}
+
+func getValueSlice(c *cli.Context) (*sliceSpec, error) {
+	if !c.IsSet("value-slice") {
+		return nil, nil
+	}
+	spec, err := parseSliceSpec(c.String("value-slice"))
+	if err != nil {
+		return nil, fmt.Errorf("option --value-slice: %w", err)
+	}
+	return &spec, nil
+}
+
 func getArg(c *cli.Context, n int) ([]byte, error) {
-	arg := []byte(c.Args().Get(n))
+	return decodeArg(c, []byte(c.Args().Get(n)))
+}
+
+func decodeArg(c *cli.Context, arg []byte) ([]byte, error) {
 	if c.Bool("base64") {
-		return decodeBase64(arg)
+		b, err := decodeBase64(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidKeyEncoding, err)
+		}
+		return b, nil
 	} else if c.Bool("raw") {
 		return arg, nil
 	} else {
-		return unescape(arg)
+		b, err := format.Unescape(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidKeyEncoding, err)
+		}
+		return b, nil
+	}
+}
+
+// getPrefixFrom returns the encoded IndexedDB key prefix (database, object
+// store and index IDs) of the key given by --prefix-from.
+func getPrefixFrom(c *cli.Context) ([]byte, error) {
+	if !c.Bool("indexeddb") {
+		return nil, errors.New("option --prefix-from requires --indexeddb")
+	}
+	for _, name := range []string{"prefix", "prefix-raw", "prefix-base64"} {
+		if c.IsSet(name) {
+			return nil, fmt.Errorf("options --prefix-from and --%s are mutually exclusive", name)
+		}
+	}
+	key, err := format.Unescape([]byte(c.String("prefix-from")))
+	if err != nil {
+		return nil, fmt.Errorf("option --prefix-from: %w: %w", errInvalidKeyEncoding, err)
+	}
+	prefix, _, err := indexeddb.DecodeKeyPrefix(key)
+	if err != nil {
+		return nil, fmt.Errorf("option --prefix-from: %w", err)
 	}
+	return indexeddb.EncodeKeyPrefix(prefix), nil
 }
 
 func hasKeyRange(c *cli.Context) bool {
@@ -56,6 +377,7 @@ func hasKeyRange(c *cli.Context) bool {
 		"prefix",
 		"prefix-raw",
 		"prefix-base64",
+		"prefix-from",
 	}
 	for _, flagName := range flagNames {
 		if c.IsSet(flagName) {
@@ -65,33 +387,64 @@ func hasKeyRange(c *cli.Context) bool {
 	return false
 }
 
-func getKeyRange(c *cli.Context) (*util.Range, error) {
+func getPrefixRange(c *cli.Context, prefix []byte) *util.Range {
+	if c.Bool("indexeddb") {
+		return indexeddb.Prefix(prefix)
+	}
+	return util.BytesPrefix(prefix)
+}
+
+// dbReader is implemented by both *leveldb.DB and *leveldb.Snapshot.
+type dbReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+// getPrefix returns the key prefix given by the --prefix options, or nil
+// if none is given.
+func getPrefix(c *cli.Context) ([]byte, error) {
+	if c.IsSet("prefix-from") {
+		return getPrefixFrom(c)
+	}
 	if c.IsSet("prefix-base64") {
 		prefix, err := decodeBase64([]byte(c.String("prefix-base64")))
 		if err != nil {
-			return nil, fmt.Errorf("option --prefix-base64: %w", err)
-		}
-		if c.Bool("indexeddb") {
-			return indexeddb.Prefix(prefix), nil
+			return nil, fmt.Errorf("option --prefix-base64: %w: %w", errInvalidKeyEncoding, err)
 		}
-		return util.BytesPrefix(prefix), nil
+		return prefix, nil
 	}
 	if c.IsSet("prefix-raw") {
-		prefix := []byte(c.String("prefix-raw"))
-		if c.Bool("indexeddb") {
-			return indexeddb.Prefix(prefix), nil
-		}
-		return util.BytesPrefix(prefix), nil
+		return []byte(c.String("prefix-raw")), nil
 	}
 	if c.IsSet("prefix") {
-		prefix, err := unescape([]byte(c.String("prefix")))
+		prefix, err := format.Unescape([]byte(c.String("prefix")))
 		if err != nil {
-			return nil, fmt.Errorf("option --prefix: %w", err)
-		}
-		if c.Bool("indexeddb") {
-			return indexeddb.Prefix(prefix), nil
+			return nil, fmt.Errorf("option --prefix: %w: %w", errInvalidKeyEncoding, err)
 		}
-		return util.BytesPrefix(prefix), nil
+		return prefix, nil
+	}
+	return nil, nil
+}
+
+// intersectRange returns the intersection of a and b under cmp. A nil
+// bound is unbounded.
+func intersectRange(cmp comparer.Comparer, a, b *util.Range) *util.Range {
+	slice := &util.Range{Start: a.Start, Limit: a.Limit}
+	if b.Start != nil && (slice.Start == nil || cmp.Compare(b.Start, slice.Start) > 0) {
+		slice.Start = b.Start
+	}
+	if b.Limit != nil && (slice.Limit == nil || cmp.Compare(b.Limit, slice.Limit) < 0) {
+		slice.Limit = b.Limit
+	}
+	return slice
+}
+
+// getKeyRange returns the key range given by the range options. If both a
+// prefix and --start/--end are given, the range is their intersection.
+func getKeyRange(c *cli.Context) (*util.Range, error) {
+	prefix, err := getPrefix(c)
+	if err != nil {
+		return nil, err
 	}
 
 	slice := &util.Range{}
@@ -99,15 +452,15 @@ func getKeyRange(c *cli.Context) (*util.Range, error) {
 	if c.IsSet("start-base64") {
 		start, err := decodeBase64([]byte(c.String("start-base64")))
 		if err != nil {
-			return nil, fmt.Errorf("option --start-base64: %w", err)
+			return nil, fmt.Errorf("option --start-base64: %w: %w", errInvalidKeyEncoding, err)
 		}
 		slice.Start = start
 	} else if c.IsSet("start-raw") {
 		slice.Start = []byte(c.String("start-raw"))
 	} else if c.IsSet("start") {
-		start, err := unescape([]byte(c.String("start")))
+		start, err := format.Unescape([]byte(c.String("start")))
 		if err != nil {
-			return nil, fmt.Errorf("option --start: %w", err)
+			return nil, fmt.Errorf("option --start: %w: %w", errInvalidKeyEncoding, err)
 		}
 		slice.Start = start
 	}
@@ -115,22 +468,94 @@ func getKeyRange(c *cli.Context) (*util.Range, error) {
 	if c.IsSet("end-base64") {
 		end, err := decodeBase64([]byte(c.String("end-base64")))
 		if err != nil {
-			return nil, fmt.Errorf("option --end-base64: %w", err)
+			return nil, fmt.Errorf("option --end-base64: %w: %w", errInvalidKeyEncoding, err)
 		}
 		slice.Limit = end
 	} else if c.IsSet("end-raw") {
 		slice.Limit = []byte(c.String("end-raw"))
 	} else if c.IsSet("end") {
-		end, err := unescape([]byte(c.String("end")))
+		end, err := format.Unescape([]byte(c.String("end")))
 		if err != nil {
-			return nil, fmt.Errorf("option --end: %w", err)
+			return nil, fmt.Errorf("option --end: %w: %w", errInvalidKeyEncoding, err)
 		}
 		slice.Limit = end
 	}
 
+	if c.Bool("end-inclusive") {
+		// Under idb_cmp1, the key that immediately follows another one
+		// depends on its type, and the limit of its prefix range would
+		// also include every longer key beginning with it.
+		if c.Bool("indexeddb") {
+			return nil, errors.New("options --end-inclusive and --indexeddb are mutually exclusive")
+		}
+		if slice.Limit != nil {
+			slice.Limit = append(bytes.Clone(slice.Limit), 0x00)
+		}
+	}
+
+	if prefix != nil {
+		slice = intersectRange(getComparer(c), getPrefixRange(c, prefix), slice)
+	}
+
+	logRange(slice)
 	return slice, nil
 }
 
+// wrapIntKeyWriter wraps kw to print fixed-width integer keys in decimal
+// to w if --key-int is given.
+func wrapIntKeyWriter(c *cli.Context, w, kw io.Writer) (io.Writer, error) {
+	if !c.IsSet("key-int") {
+		return kw, nil
+	}
+	size, order, err := parseIntKeyFormat(c.String("key-int"))
+	if err != nil {
+		return nil, fmt.Errorf("option --key-int: %w", err)
+	}
+	return newIntKeyWriter(w, kw, size, order), nil
+}
+
+// getStripPrefix returns the prefix to remove from printed keys if
+// --strip-prefix is given, or nil otherwise.
+func getStripPrefix(c *cli.Context) ([]byte, error) {
+	if !c.Bool("strip-prefix") {
+		return nil, nil
+	}
+	if c.Bool("indexeddb") {
+		// The range of an IndexedDB prefix is not a literal byte prefix.
+		return nil, errors.New("option --strip-prefix cannot be used with --indexeddb")
+	}
+	prefix, err := getPrefix(c)
+	if err != nil {
+		return nil, err
+	}
+	if prefix == nil {
+		return nil, errors.New("option --strip-prefix requires --prefix")
+	}
+	return prefix, nil
+}
+
+// explainKeyRange prints the start and limit of the key range given by the
+// range options, as they are passed to the iterator.
+func explainKeyRange(c *cli.Context) error {
+	slice, err := getKeyRange(c)
+	if err != nil {
+		return err
+	}
+	bound := func(b []byte) string {
+		if b == nil {
+			return "(unbounded)"
+		}
+		if len(b) == 0 {
+			return "(empty)"
+		}
+		return hex.EncodeToString(b)
+	}
+	fmt.Printf("Comparer: %s\n", getComparer(c).Name())
+	fmt.Printf("Start:    %s\n", bound(slice.Start))
+	fmt.Printf("Limit:    %s\n", bound(slice.Limit))
+	return nil
+}
+
 type matcher interface {
 	Match(key []byte) bool
 }
@@ -179,56 +604,189 @@ func newRegexpMatcher(patterns ...string) (regexpMatcher, error) {
 	return matcher, nil
 }
 
-func initCmd(c *cli.Context) error {
-	db, err := leveldb.OpenFile(c.String("dbpath"), &opt.Options{
-		Comparer:     getComparer(c),
-		ErrorIfExist: true,
-	})
+// getExcludeMatcher returns a matcher for the keys excluded by --exclude.
+func getExcludeMatcher(c *cli.Context) (matcher, error) {
+	if !c.IsSet("exclude") {
+		return constMatcher(false), nil
+	}
+	m, err := newRegexpMatcher(c.StringSlice("exclude")...)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("option --exclude: %w", err)
 	}
-	if err := db.Close(); err != nil {
-		return err
+	return m, nil
+}
+
+// timeMatcher matches keys that embed a big-endian unix timestamp in
+// [after, before).
+type timeMatcher struct {
+	offset int
+	size   int
+	after  *int64
+	before *int64
+}
+
+func (m *timeMatcher) Match(key []byte) bool {
+	if len(key) < m.offset+m.size {
+		return false
 	}
-	return nil
+	var v uint64
+	for _, b := range key[m.offset : m.offset+m.size] {
+		v = v<<8 | uint64(b)
+	}
+	ts := int64(v)
+	if m.after != nil && ts < *m.after {
+		return false
+	}
+	if m.before != nil && ts >= *m.before {
+		return false
+	}
+	return true
 }
 
-func getCmd(c *cli.Context) error {
-	if c.NArg() < 1 {
-		cli.ShowSubcommandHelpAndExit(c, 2)
+func parseTime(s string) (int64, error) {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Unix(), nil
+		}
 	}
+	return 0, fmt.Errorf("invalid time %q (expected unix seconds, RFC 3339 or YYYY-MM-DD)", s)
+}
 
-	key, err := getArg(c, 0)
-	if err != nil {
-		return err
+func getTimeMatcher(c *cli.Context) (matcher, error) {
+	if !c.IsSet("after") && !c.IsSet("before") {
+		return constMatcher(true), nil
+	}
+	m := &timeMatcher{
+		offset: c.Int("key-time-offset"),
+		size:   c.Int("key-time-size"),
+	}
+	if m.offset < 0 {
+		return nil, errors.New("option --key-time-offset: must not be negative")
+	}
+	if m.size < 1 || m.size > 8 {
+		return nil, errors.New("option --key-time-size: must be between 1 and 8")
+	}
+	for _, name := range []string{"after", "before"} {
+		if !c.IsSet(name) {
+			continue
+		}
+		ts, err := parseTime(c.String(name))
+		if err != nil {
+			return nil, fmt.Errorf("option --%s: %w", name, err)
+		}
+		if name == "after" {
+			m.after = &ts
+		} else {
+			m.before = &ts
+		}
 	}
+	return m, nil
+}
 
-	db, err := leveldb.OpenFile(c.String("dbpath"), &opt.Options{
-		Comparer:       getComparer(c),
-		ErrorIfMissing: true,
-		ReadOnly:       true,
-	})
-	if err != nil {
-		return err
+// ttlFilter matches values that start with a big-endian unix expiry time
+// in milliseconds. Values too short to hold one never match.
+type ttlFilter struct {
+	now       int64
+	expired   bool
+	unexpired bool
+}
+
+// ttlExpiry returns the expiry time at the start of value and the rest of
+// value. It reports false if value is too short to hold one.
+func ttlExpiry(value []byte) (time.Time, []byte, bool) {
+	if len(value) < 8 {
+		return time.Time{}, value, false
 	}
-	defer db.Close()
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(value))), value[8:], true
+}
 
-	value, err := db.Get(key, nil)
-	if err != nil {
-		return err
+func (f *ttlFilter) Match(value []byte) bool {
+	if !f.expired && !f.unexpired {
+		return true
 	}
-	if _, err := os.Stdout.Write(value); err != nil {
-		return err
+	expiry, _, ok := ttlExpiry(value)
+	if !ok {
+		return false
+	}
+	expired := expiry.UnixMilli() <= f.now
+	return expired == f.expired
+}
+
+// getTTLFilter returns the filter given by --ttl-prefix, or nil if it is
+// not given.
+func getTTLFilter(c *cli.Context) (*ttlFilter, error) {
+	if c.Bool("expired-only") && c.Bool("unexpired-only") {
+		return nil, errors.New("options --expired-only and --unexpired-only are mutually exclusive")
+	}
+	if !c.IsSet("ttl-prefix") {
+		for _, name := range []string{"expired-only", "unexpired-only"} {
+			if c.Bool(name) {
+				return nil, fmt.Errorf("option --%s requires --ttl-prefix", name)
+			}
+		}
+		return nil, nil
+	}
+	if s := c.String("ttl-prefix"); s != "be64" {
+		return nil, fmt.Errorf("option --ttl-prefix: unsupported format %q (expected be64)", s)
+	}
+	return &ttlFilter{
+		now:       time.Now().UnixMilli(),
+		expired:   c.Bool("expired-only"),
+		unexpired: c.Bool("unexpired-only"),
+	}, nil
+}
+
+// wrapPattern makes pattern match only the entire input if fullMatch is
+// true, and match case-insensitively if ignoreCase is true.
+func wrapPattern(pattern string, fullMatch, ignoreCase bool) string {
+	if fullMatch {
+		pattern = `\A(?:` + pattern + `)\z`
+	}
+	if ignoreCase {
+		pattern = `(?i)` + pattern
 	}
+	return pattern
+}
 
+func initCmd(c *cli.Context) error {
+	o := getOptions(c)
+	o.ErrorIfExist = true
+	db, err := openDB(c.String("dbpath"), o)
+	if err != nil {
+		return err
+	}
 	if err := db.Close(); err != nil {
 		return err
 	}
-
 	return nil
 }
 
-func putCmd(c *cli.Context) error {
+// getByPrefix returns the value of the only key in slice, which is the
+// range of keys starting with prefix.
+func getByPrefix(db *leveldb.DB, slice *util.Range, prefix []byte) ([]byte, error) {
+	iter := db.NewIterator(slice, nil)
+	defer iter.Release()
+	if !iter.Next() {
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+		return nil, leveldb.ErrNotFound
+	}
+	key := bytes.Clone(iter.Key())
+	value := bytes.Clone(iter.Value())
+	if iter.Next() {
+		return nil, fmt.Errorf("multiple keys start with %q, including %q and %q", prefix, key, iter.Key())
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func getCmd(c *cli.Context) error {
 	if c.NArg() < 1 {
 		cli.ShowSubcommandHelpAndExit(c, 2)
 	}
@@ -237,29 +795,50 @@ func putCmd(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-
-	var value []byte
-	if c.NArg() < 2 {
-		value, err = io.ReadAll(os.Stdin)
-	} else {
-		value, err = getArg(c, 1)
-	}
+	spec, err := getValueSlice(c)
 	if err != nil {
 		return err
 	}
 
-	db, err := leveldb.OpenFile(c.String("dbpath"), &opt.Options{
-		Comparer:       getComparer(c),
-		ErrorIfMissing: true,
-	})
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = true
+	db, err := openDB(c.String("dbpath"), o)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	if err := db.Put(key, value, nil); err != nil {
+	var value []byte
+	if c.Bool("prefix") {
+		value, err = getByPrefix(db, getPrefixRange(c, key), key)
+	} else {
+		value, err = db.Get(key, nil)
+	}
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return errKeyNotFound
+	} else if err != nil {
 		return err
 	}
+	if spec != nil {
+		value = spec.Apply(value)
+	}
+	if c.Bool("print") {
+		w := format.NewFormatter(color.Output).SetParseJSON(true)
+		if _, err := w.Write(value); err != nil {
+			return err
+		}
+		if _, err := fmt.Println(); err != nil {
+			return err
+		}
+	} else {
+		if !c.Bool("force") && isTerminal(os.Stdout) && isBinary(value) {
+			return errors.New("value contains binary data; redirect the output or use --print or --force to print it")
+		}
+		if _, err := os.Stdout.Write(value); err != nil {
+			return err
+		}
+	}
 
 	if err := db.Close(); err != nil {
 		return err
@@ -268,29 +847,235 @@ func putCmd(c *cli.Context) error {
 	return nil
 }
 
-func deleteCmd(c *cli.Context) error {
-	if !hasKeyRange(c) && c.NArg() == 0 {
-		cli.ShowSubcommandHelpAndExit(c, 2)
+func readStdinValue(c *cli.Context) ([]byte, error) {
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
 	}
+	if c.Bool("trim-newline") {
+		value = trimNewline(value)
+	}
+	return value, nil
+}
 
-	slice, err := getKeyRange(c)
-	if err != nil {
-		return err
+func trimNewline(b []byte) []byte {
+	if bytes.HasSuffix(b, []byte("\r\n")) {
+		return b[:len(b)-2]
+	}
+	return bytes.TrimSuffix(b, []byte("\n"))
+}
+
+func putCmd(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	var keys [][]byte
+	var value []byte
+	if c.IsSet("value-file") {
+		for i := range c.NArg() {
+			key, err := getArg(c, i)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+
+		var err error
+		if name := c.String("value-file"); name == "-" {
+			value, err = readStdinValue(c)
+		} else {
+			value, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return fmt.Errorf("option --value-file: %w", err)
+		}
+		if c.Bool("decode-value") {
+			if value, err = decodeArg(c, value); err != nil {
+				return fmt.Errorf("option --decode-value: %w", err)
+			}
+		}
+	} else {
+		key, err := getArg(c, 0)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+
+		if c.NArg() < 2 {
+			value, err = readStdinValue(c)
+			if err == nil && c.Bool("decode-value") {
+				if value, err = decodeArg(c, value); err != nil {
+					err = fmt.Errorf("option --decode-value: %w", err)
+				}
+			}
+		} else {
+			value, err = getArg(c, 1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	batch := new(leveldb.Batch)
+	for _, key := range keys {
+		batch.Put(key, value)
+	}
+
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	db, err := openDB(c.String("dbpath"), o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Write(batch, getWriteOptions(c)); err != nil {
+		return err
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// readKeyLines reads keys from r, one per line. Empty lines are ignored.
+func readKeyLines(r io.Reader, decode func([]byte) ([]byte, error)) ([][]byte, error) {
+	var keys [][]byte
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64*1024*1024)
+	lineno := 0
+	for sc.Scan() {
+		lineno++
+		line := bytes.TrimSuffix(sc.Bytes(), []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		key, err := decode(bytes.Clone(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func deleteStdinCmd(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return errors.New("option --stdin cannot be used with key arguments")
+	}
+	for _, name := range []string{"regexp", "invert-match", "value-regexp", "after", "before"} {
+		if c.IsSet(name) {
+			return fmt.Errorf("options --stdin and --%s are mutually exclusive", name)
+		}
+	}
+	if hasKeyRange(c) {
+		return errors.New("option --stdin cannot be used with a key range")
+	}
+	dryRun := c.Bool("dry-run")
+	keywriter := format.NewFormatter(color.Output).SetQuoting(true)
+
+	// The keys are read before the database is opened, since the command
+	// producing them usually still holds the database open.
+	keys, err := readKeyLines(os.Stdin, func(b []byte) ([]byte, error) {
+		return decodeArg(c, b)
+	})
+	if err != nil {
+		return err
+	}
+
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = dryRun
+	db, err := openDB(c.String("dbpath"), o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The keys are deleted in batches and in input order, so after an
+	// error or an interrupt, the first ndeleted keys are deleted.
+	ndeleted := 0
+	partial := func(err error) error {
+		if ndeleted == 0 {
+			return err
+		}
+		return fmt.Errorf("%w; the first %d of %d keys were already deleted", err, ndeleted, len(keys))
+	}
+	batch := new(leveldb.Batch)
+	for _, key := range keys {
+		if err := checkContext(c.Context); err != nil {
+			return partial(err)
+		}
+		if dryRun {
+			fmt.Print("Would delete ")
+			keywriter.Write(key)
+			fmt.Println()
+			continue
+		}
+		batch.Delete(key)
+		if batch.Len() >= clearBatchSize {
+			if err := db.Write(batch, getWriteOptions(c)); err != nil {
+				return partial(err)
+			}
+			ndeleted += batch.Len()
+			batch.Reset()
+		}
+	}
+	if !dryRun {
+		// Sync the last batch, so that all the deletions are on disk.
+		if err := db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+			return partial(err)
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func deleteCmd(c *cli.Context) error {
+	if c.Bool("stdin") {
+		return deleteStdinCmd(c)
+	}
+	if !hasKeyRange(c) && !c.IsSet("after") && !c.IsSet("before") && c.NArg() == 0 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	slice, err := getKeyRange(c)
+	if err != nil {
+		return err
+	}
+	inverted := c.Bool("invert-match")
+	dryRun := c.Bool("dry-run")
+	keywriter := format.NewFormatter(color.Output).SetQuoting(true)
+
+	fullMatch, ignoreCase := c.Bool("full-match"), c.Bool("ignore-case")
+	if (fullMatch || ignoreCase) && !c.Bool("regexp") {
+		return errors.New("options --full-match and --ignore-case require --regexp")
 	}
-	inverted := c.Bool("invert-match")
-	dryRun := c.Bool("dry-run")
-	keywriter := newPrettyPrinter(color.Output).SetQuoting(true)
 
 	var m matcher
 	if c.NArg() == 0 {
 		m = constMatcher(true)
 	} else if c.Bool("regexp") {
-		m, err = newRegexpMatcher(c.Args().Slice()...)
+		patterns := make([]string, 0, c.NArg())
+		for _, pattern := range c.Args().Slice() {
+			patterns = append(patterns, wrapPattern(pattern, fullMatch, ignoreCase))
+		}
+		m, err = newRegexpMatcher(patterns...)
 		if err != nil {
 			return err
 		}
 	} else {
-		keys := make([][]byte, c.NArg())
+		keys := make([][]byte, 0, c.NArg())
 		for i := range c.NArg() {
 			key, err := getArg(c, i)
 			if err != nil {
@@ -301,11 +1086,23 @@ func deleteCmd(c *cli.Context) error {
 		m = newLiteralMatcher(keys...)
 	}
 
-	db, err := leveldb.OpenFile(c.String("dbpath"), &opt.Options{
-		Comparer:       getComparer(c),
-		ErrorIfMissing: true,
-		ReadOnly:       dryRun,
-	})
+	tm, err := getTimeMatcher(c)
+	if err != nil {
+		return err
+	}
+
+	var vm matcher = constMatcher(true)
+	if c.IsSet("value-regexp") {
+		vm, err = newRegexpMatcher(c.String("value-regexp"))
+		if err != nil {
+			return fmt.Errorf("option --value-regexp: %w", err)
+		}
+	}
+
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = dryRun
+	db, err := openDB(c.String("dbpath"), o)
 	if err != nil {
 		return err
 	}
@@ -322,7 +1119,10 @@ func deleteCmd(c *cli.Context) error {
 	iter := s.NewIterator(slice, nil)
 	defer iter.Release()
 	for iter.Next() {
-		if m.Match(iter.Key()) != inverted {
+		if err := checkContext(c.Context); err != nil {
+			return err
+		}
+		if m.Match(iter.Key()) != inverted && tm.Match(iter.Key()) && vm.Match(iter.Value()) {
 			if dryRun {
 				fmt.Print("Would delete ")
 				keywriter.Write(iter.Key())
@@ -339,27 +1139,1299 @@ func deleteCmd(c *cli.Context) error {
 	iter.Release()
 	s.Release()
 
+	if !dryRun && c.NArg() == 0 && batch.Len() > 0 && needConfirmation(c) {
+		if ok, err := confirm(fmt.Sprintf("Delete %d entries?", batch.Len())); err != nil {
+			return err
+		} else if !ok {
+			return errAborted
+		}
+	}
+
+	if !dryRun {
+		if err := db.Write(batch, getWriteOptions(c)); err != nil {
+			return err
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const clearBatchSize = 1000
+
+func clearCmd(c *cli.Context) error {
+	slice, err := getKeyRange(c)
+	if err != nil {
+		return err
+	}
+	dryRun := c.Bool("dry-run")
+	keywriter := format.NewFormatter(color.Output).SetQuoting(true)
+
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = dryRun
+	db, err := openDB(c.String("dbpath"), o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s, err := db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer s.Release()
+
+	if !dryRun && needConfirmation(c) {
+		nentries := 0
+		iter := s.NewIterator(slice, nil)
+		for iter.Next() {
+			if err := checkContext(c.Context); err != nil {
+				iter.Release()
+				return err
+			}
+			nentries++
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		if nentries == 0 {
+			return nil
+		}
+		if ok, err := confirm(fmt.Sprintf("Delete %d entries?", nentries)); err != nil {
+			return err
+		} else if !ok {
+			return errAborted
+		}
+	}
+
+	// The entries are deleted in batches, so an error or an interrupt
+	// leaves part of the range deleted.
+	ndeleted := 0
+	partial := func(err error) error {
+		if ndeleted == 0 {
+			return err
+		}
+		return fmt.Errorf("%w; %d entries were already deleted (clear is not atomic; run it again to delete the rest)", err, ndeleted)
+	}
+	batch := new(leveldb.Batch)
+
+	iter := s.NewIterator(slice, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := checkContext(c.Context); err != nil {
+			return partial(err)
+		}
+		if dryRun {
+			fmt.Print("Would delete ")
+			keywriter.Write(iter.Key())
+			fmt.Println()
+			continue
+		}
+		batch.Delete(iter.Key())
+		if batch.Len() >= clearBatchSize {
+			if err := db.Write(batch, nil); err != nil {
+				return partial(err)
+			}
+			ndeleted += batch.Len()
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return partial(err)
+	}
+
+	iter.Release()
+	s.Release()
+
+	if !dryRun {
+		// Sync the last batch, so that the whole clear is on disk.
+		if err := db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+			return partial(err)
+		}
+		if err := db.CompactRange(*slice); err != nil {
+			return err
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func renameCmd(c *cli.Context) error {
+	if c.NArg() != 2 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	oldPrefix, err := getArg(c, 0)
+	if err != nil {
+		return err
+	}
+	newPrefix, err := getArg(c, 1)
+	if err != nil {
+		return err
+	}
+	dryRun, force := c.Bool("dry-run"), c.Bool("force")
+	keywriter := format.NewFormatter(color.Output).SetQuoting(true)
+
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = dryRun
+	db, err := openDB(c.String("dbpath"), o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s, err := db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer s.Release()
+
+	var entries []entry
+	matched := false
+
+	iter := s.NewIterator(getPrefixRange(c, oldPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := checkContext(c.Context); err != nil {
+			return err
+		}
+		key := iter.Key()
+		if !bytes.HasPrefix(key, oldPrefix) {
+			continue
+		}
+		newKey := slices.Concat(newPrefix, key[len(oldPrefix):])
+		// Keys under the old prefix are deleted before the puts, so only
+		// other keys are overwritten.
+		if !force && !bytes.HasPrefix(newKey, oldPrefix) {
+			if ok, err := s.Has(newKey, nil); err != nil {
+				return err
+			} else if ok {
+				return fmt.Errorf("key %q already exists (use --force to overwrite it)", newKey)
+			}
+		}
+		matched = true
+		if dryRun {
+			fmt.Print("Would rename ")
+			keywriter.Write(key)
+			fmt.Print(" to ")
+			keywriter.Write(newKey)
+			fmt.Println()
+		} else {
+			entries = append(entries, entry{
+				Key:   bytes.Clone(key),
+				Value: bytes.Clone(iter.Value()),
+			})
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	iter.Release()
+	s.Release()
+
+	if !matched {
+		return fmt.Errorf("no keys start with %q", oldPrefix)
+	}
+
 	if !dryRun {
+		// Deletions are queued before insertions so that a renamed key is
+		// not removed again when the new prefix overlaps the old one.
+		batch := new(leveldb.Batch)
+		for _, entry := range entries {
+			batch.Delete(entry.Key)
+		}
+		for _, entry := range entries {
+			batch.Put(slices.Concat(newPrefix, entry.Key[len(oldPrefix):]), entry.Value)
+		}
 		if err := db.Write(batch, nil); err != nil {
 			return err
 		}
 	}
-
-	if err := db.Close(); err != nil {
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type dbTarget struct {
+	Path  string
+	Label string
+}
+
+// getDBTargets returns the databases given as arguments along with their
+// labels, or the database given by --dbpath with an empty label.
+func getDBTargets(c *cli.Context) ([]dbTarget, error) {
+	labels := c.StringSlice("label")
+	if c.NArg() == 0 {
+		if len(labels) > 0 {
+			return nil, errors.New("option --label requires database arguments")
+		}
+		return []dbTarget{{Path: c.String("dbpath")}}, nil
+	}
+	if len(labels) > c.NArg() {
+		return nil, errors.New("option --label: more labels than databases")
+	}
+
+	targets := make([]dbTarget, 0, c.NArg())
+	for i, arg := range c.Args().Slice() {
+		label := arg
+		if i < len(labels) {
+			label = labels[i]
+		}
+		path, err := resolveDBPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, dbTarget{Path: path, Label: label})
+	}
+	return targets, nil
+}
+
+// writeLabel writes the label of t before an output line, if any.
+func (t dbTarget) writeLabel() error {
+	if t.Label == "" {
+		return nil
+	}
+	_, err := os.Stdout.WriteString(t.Label + ": ")
+	return err
+}
+
+func keysCmd(c *cli.Context) error {
+	if c.Bool("explain") {
+		return explainKeyRange(c)
+	}
+	style, err := format.ParseEscapeStyle(c.String("escape"))
+	if err != nil {
+		return fmt.Errorf("option --escape: %w", err)
+	}
+
+	keyFormat, err := getOutputFormat(c, "key-format")
+	if err != nil {
+		return err
+	}
+	terminator := "\n"
+	if c.Bool("null") {
+		keyFormat = rawFormat
+		terminator = "\x00"
+	}
+	var decodeKey func([]byte) ([]byte, error)
+	if c.Bool("localstorage") {
+		decodeKey = decodeLocalStorageKey
+	}
+	w := newFormatWriter(keyFormat, format.NewFormatter(os.Stdout).
+		SetUTF16(c.Bool("utf16")).
+		SetEscapeStyle(style), decodeKey)
+	w, err = wrapIntKeyWriter(c, os.Stdout, w)
+	if err != nil {
+		return err
+	}
+
+	tm, err := getTimeMatcher(c)
+	if err != nil {
+		return err
+	}
+
+	ex, err := getExcludeMatcher(c)
+	if err != nil {
+		return err
+	}
+
+	ttl, err := getTTLFilter(c)
+	if err != nil {
+		return err
+	}
+	if ttl != nil && c.IsSet("delimiter") {
+		return errors.New("option --ttl-prefix cannot be used with --delimiter")
+	}
+
+	var delimiter, prefix []byte
+	if c.IsSet("delimiter") {
+		if c.Bool("indexeddb") {
+			return errors.New("option --delimiter cannot be used with --indexeddb")
+		}
+		if delimiter, err = format.Unescape([]byte(c.String("delimiter"))); err != nil {
+			return fmt.Errorf("option --delimiter: %w", err)
+		}
+		if len(delimiter) == 0 {
+			return errors.New("option --delimiter: must not be empty")
+		}
+		if c.IsSet("after") || c.IsSet("before") {
+			return errors.New("option --delimiter cannot be used with --after or --before")
+		}
+		if prefix, err = getPrefix(c); err != nil {
+			return err
+		}
+	}
+	countPerPrefix := c.Bool("count-per-prefix")
+	if countPerPrefix && delimiter == nil {
+		return errors.New("option --count-per-prefix requires --delimiter")
+	}
+
+	stripPrefix, err := getStripPrefix(c)
+	if err != nil {
+		return err
+	}
+
+	slice, err := getKeyRange(c)
+	if err != nil {
+		return err
+	}
+
+	targets, err := getDBTargets(c)
+	if err != nil {
+		return err
+	}
+
+	scanner := newDBScanner(c, slice)
+	if delimiter != nil && !countPerPrefix {
+		scanner.WrapIterator = func(iter iterator.Iterator) iterator.Iterator {
+			return newDelimitedIterator(iter, prefix, delimiter)
+		}
+	}
+
+	nentries := 0
+	scan := func(t dbTarget) error {
+		// With --count-per-prefix every key is visited to be counted, so
+		// the groups are formed here instead of by delimitedIterator.
+		var group []byte
+		ngroup := 0
+		flushGroup := func() error {
+			if ngroup == 0 {
+				return nil
+			}
+			if err := t.writeLabel(); err != nil {
+				return err
+			}
+			if _, err := w.Write(bytes.TrimPrefix(group, stripPrefix)); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(os.Stdout, "\t%d%s", ngroup, terminator); err != nil {
+				return err
+			}
+			ngroup = 0
+			return nil
+		}
+
+		err := scanner.Scan(c.Context, t.Path, func(key, value []byte) error {
+			if !tm.Match(key) || ex.Match(key) {
+				return nil
+			}
+			if ttl != nil && !ttl.Match(value) {
+				return nil
+			}
+			nentries++
+			if countPerPrefix {
+				key, _ := delimitedGroup(key, prefix, delimiter)
+				if ngroup > 0 && bytes.Equal(key, group) {
+					ngroup++
+					return nil
+				}
+				if err := flushGroup(); err != nil {
+					return err
+				}
+				group = append(group[:0], key...)
+				ngroup = 1
+				return nil
+			}
+			if err := t.writeLabel(); err != nil {
+				return err
+			}
+			if _, err := w.Write(bytes.TrimPrefix(key, stripPrefix)); err != nil {
+				return err
+			}
+			_, err := os.Stdout.WriteString(terminator)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return flushGroup()
+	}
+	for _, t := range targets {
+		if err := scan(t); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("stats") {
+		fmt.Fprintf(os.Stderr, "%d entries\n", nentries)
+	}
+
+	return nil
+}
+
+func getOutputFormat(c *cli.Context, name string) (outputFormat, error) {
+	if c.IsSet(name) {
+		of, err := parseOutputFormat(c.String(name))
+		if err != nil {
+			return 0, fmt.Errorf("option --%s: %w", name, err)
+		}
+		return of, nil
+	}
+	switch {
+	case c.Bool("base64url"):
+		return base64URLFormat, nil
+	case c.Bool("base64"):
+		return base64Format, nil
+	case c.Bool("raw"):
+		return rawFormat, nil
+	default:
+		return escapedFormat, nil
+	}
+}
+
+// newFormatWriter returns a writer for the given output format. If decode
+// is not nil, it is applied before the output format, whatever it is.
+func newFormatWriter(of outputFormat, pp *format.Formatter, decode func([]byte) ([]byte, error)) io.Writer {
+	var w io.Writer
+	switch of {
+	case base64Format, base64URLFormat:
+		w = newBase64Writer(os.Stdout).SetURLEncoding(of == base64URLFormat)
+	case hexFormat:
+		w = newHexWriter(os.Stdout)
+	case rawFormat:
+		w = os.Stdout
+	default:
+		w = pp
+	}
+	if decode != nil {
+		w = newDecodingWriter(w, decode)
+	}
+	return w
+}
+
+// getTruncate returns the truncation limit of values and whether it counts
+// input bytes rather than output width.
+func getTruncate(c *cli.Context) (int, bool, error) {
+	if c.IsSet("truncate-bytes") {
+		for _, name := range []string{"truncate", "no-truncate"} {
+			if c.IsSet(name) {
+				return 0, false, fmt.Errorf("options --truncate-bytes and --%s are mutually exclusive", name)
+			}
+		}
+		if c.Int("truncate-bytes") < 0 {
+			return 0, false, errors.New("option --truncate-bytes: must not be negative")
+		}
+		return c.Int("truncate-bytes"), true, nil
+	}
+	truncate := c.Int("truncate")
+	if truncate < 0 {
+		return 0, false, errors.New("option --truncate: must not be negative")
+	}
+	if c.Bool("no-truncate") {
+		truncate = 0
+	}
+	return truncate, false, nil
+}
+
+func getEntryWriters(c *cli.Context) (io.Writer, io.Writer, error) {
+	style, err := format.ParseEscapeStyle(c.String("escape"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("option --escape: %w", err)
+	}
+	truncate, byBytes, err := getTruncate(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Int("json-depth") < 0 {
+		return nil, nil, errors.New("option --json-depth: must not be negative")
+	}
+	keyFormat, err := getOutputFormat(c, "key-format")
+	if err != nil {
+		return nil, nil, err
+	}
+	valueFormat, err := getOutputFormat(c, "value-format")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var decodeKey, decodeValue func([]byte) ([]byte, error)
+	if c.Bool("localstorage") {
+		decodeKey, decodeValue = decodeLocalStorageKey, localstorage.DecodeValue
+	}
+	// Decoded IndexedDB keys quote their strings themselves.
+	idbDecode := c.Bool("idb-decode")
+	if idbDecode {
+		decodeKey = decodeIndexedDBKey
+	}
+	kw := newFormatWriter(keyFormat, format.NewFormatter(color.Output).
+		SetQuoting(!idbDecode).
+		SetUTF16(c.Bool("utf16")).
+		SetEscapeStyle(style), decodeKey)
+	vw := newFormatWriter(valueFormat, format.NewFormatter(color.Output).
+		SetQuoting(true).
+		SetTruncate(truncate).
+		SetTruncateBytes(byBytes).
+		SetParseJSON(!c.Bool("no-json")).
+		SetJSONDepth(c.Int("json-depth")).
+		SetUTF16(c.Bool("utf16")).
+		SetEscapeStyle(style), decodeValue)
+	return kw, vw, nil
+}
+
+// decodeIndexedDBKey renders an IndexedDB object store data, exists,
+// blob or index data key as the ids of its key prefix and its decoded
+// keys:
+//
+//	db1/store2 key=["user",42]
+//	db1/store2/exists key=["user",42]
+//	db1/store2/blob key=["user",42]
+//	db1/store2/index30 key="Alice" seq=1 primary=["user",42]
+//
+// where seq is the sequence number of an index entry. Other keys are not
+// decoded.
+func decodeIndexedDBKey(key []byte) ([]byte, error) {
+	prefix, rest, err := indexeddb.DecodeKeyPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+	ids := fmt.Sprintf("db%d/store%d", prefix.DatabaseId, prefix.ObjectStoreId)
+	switch prefix.Type() {
+	case indexeddb.ObjectStoreData:
+	case indexeddb.ExistsEntry:
+		ids += "/exists"
+	case indexeddb.BlobEntry:
+		ids += "/blob"
+	case indexeddb.IndexData:
+		ids += fmt.Sprintf("/index%d", prefix.IndexId)
+	default:
+		return nil, indexeddb.ErrInvalidKey
+	}
+	if prefix.Type() == indexeddb.IndexData {
+		indexKey, seq, primaryKey, err := indexeddb.DecodeIndexDataKey(rest)
+		if err != nil {
+			return nil, err
+		}
+		if seq < 0 {
+			return []byte(fmt.Sprintf("%s key=%s", ids, indexKey)), nil
+		}
+		return []byte(fmt.Sprintf("%s key=%s seq=%d primary=%s", ids, indexKey, seq, primaryKey)), nil
+	}
+	userKey, _, err := indexeddb.DecodeKey(rest)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%s key=%s", ids, userKey)), nil
+}
+
+// decodeIndexedDBValue decodes the V8 payload of an IndexedDB object
+// store value into JSON. It reports false if the value is not one, or
+// holds a value that cannot be decoded.
+func decodeIndexedDBValue(key, value []byte) ([]byte, bool) {
+	payload, _, ok := stripValueHeader(key, value)
+	if !ok {
+		return value, false
+	}
+	decoded, err := indexeddb.DecodeV8Value(payload)
+	if err != nil {
+		return value, false
+	}
+	return decoded, true
+}
+
+// stripValueHeader returns the V8 payload of an IndexedDB object store
+// value and its offset in value (-1 if the value is compressed). It
+// reports false if the value is not one.
+func stripValueHeader(key, value []byte) ([]byte, int, bool) {
+	prefix, _, err := indexeddb.DecodeKeyPrefix(key)
+	if err != nil || prefix.Type() != indexeddb.ObjectStoreData {
+		return value, 0, false
+	}
+	payload, offset, err := indexeddb.SplitValueHeader(value)
+	if err != nil {
+		return value, 0, false
+	}
+	return payload, offset, true
+}
+
+func showCmd(c *cli.Context) error {
+	if c.Bool("explain") {
+		return explainKeyRange(c)
+	}
+	kw, vw, err := getEntryWriters(c)
+	if err != nil {
+		return err
+	}
+	if kw, err = wrapIntKeyWriter(c, color.Output, kw); err != nil {
+		return err
+	}
+	if c.Bool("hash") {
+		newHash, err := getHashFunc(c)
+		if err != nil {
+			return err
+		}
+		vw = newHashWriter(os.Stdout, newHash)
+	}
+	for _, name := range []string{"strip-value-header", "idb-decode"} {
+		if c.Bool(name) && !c.Bool("indexeddb") {
+			return fmt.Errorf("option --%s requires --indexeddb", name)
+		}
+	}
+	stripHeader, idbDecode := c.Bool("strip-value-header"), c.Bool("idb-decode")
+	separator := ": "
+	if c.Bool("hexdump") {
+		for _, name := range []string{"base64", "base64url", "value-format", "no-json", "hash"} {
+			if c.IsSet(name) {
+				return fmt.Errorf("options --hexdump and --%s are mutually exclusive", name)
+			}
+		}
+		// Hex dumps are always truncated by bytes.
+		truncate, _, err := getTruncate(c)
+		if err != nil {
+			return err
+		}
+		vw = newHexDumpWriter(os.Stdout).SetTruncate(truncate)
+		separator = ":"
+	}
+	terminator := "\n"
+	if c.Bool("null") {
+		for _, name := range []string{"hexdump", "hash", "key-int"} {
+			if c.IsSet(name) {
+				return fmt.Errorf("options --null and --%s are mutually exclusive", name)
+			}
+		}
+		if c.NArg() > 0 {
+			return errors.New("option --null cannot be used with database arguments")
+		}
+		kw, vw = os.Stdout, os.Stdout
+		separator, terminator = "\x00", "\x00"
+	}
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{
+		{"separator", &separator},
+		{"record-separator", &terminator},
+	} {
+		if !c.IsSet(f.name) {
+			continue
+		}
+		if c.Bool("null") {
+			return fmt.Errorf("options --null and --%s are mutually exclusive", f.name)
+		}
+		b, err := format.Unescape([]byte(c.String(f.name)))
+		if err != nil {
+			return fmt.Errorf("option --%s: %w", f.name, err)
+		}
+		*f.dst = string(b)
+	}
+	if c.IsSet("byte-swap") {
+		switch n := c.Int("byte-swap"); n {
+		case 2, 4, 8:
+			for _, name := range []string{"hash", "null"} {
+				if c.IsSet(name) {
+					return fmt.Errorf("options --byte-swap and --%s are mutually exclusive", name)
+				}
+			}
+			vw = newByteSwapWriter(vw, n)
+		default:
+			return errors.New("option --byte-swap: must be 2, 4 or 8")
+		}
+	}
+	spec, err := getValueSlice(c)
+	if err != nil {
+		return err
+	}
+
+	tm, err := getTimeMatcher(c)
+	if err != nil {
+		return err
+	}
+
+	ex, err := getExcludeMatcher(c)
+	if err != nil {
+		return err
+	}
+
+	ttl, err := getTTLFilter(c)
+	if err != nil {
+		return err
+	}
+	dimmed := color.New(color.Faint).FprintfFunc()
+
+	stripPrefix, err := getStripPrefix(c)
+	if err != nil {
+		return err
+	}
+
+	slice, err := getKeyRange(c)
+	if err != nil {
+		return err
+	}
+
+	targets, err := getDBTargets(c)
+	if err != nil {
+		return err
+	}
+
+	limit := c.Int("limit")
+	if limit < 0 {
+		return errors.New("option --limit: must not be negative")
+	}
+	var sorter *entrySorter
+	if c.IsSet("sort") {
+		if limit == 0 {
+			return errors.New("option --sort requires --limit, since the sorted entries are held in memory")
+		}
+		if sorter, err = newEntrySorter(c.String("sort"), limit); err != nil {
+			return fmt.Errorf("option --sort: %w", err)
+		}
+	}
+
+	nentries, nbytes := 0, 0
+	printEntry := func(t dbTarget, key, value []byte) error {
+		nentries++
+		nbytes += len(key) + len(value)
+		if err := t.writeLabel(); err != nil {
+			return err
+		}
+		if _, err := kw.Write(bytes.TrimPrefix(key, stripPrefix)); err != nil {
+			return err
+		}
+		if _, err := os.Stdout.WriteString(separator); err != nil {
+			return err
+		}
+		if ttl != nil && !c.Bool("null") {
+			if expiry, rest, ok := ttlExpiry(value); ok {
+				dimmed(color.Output, "(expires %s) ", expiry.Format(time.RFC3339))
+				value = rest
+			}
+		}
+		decoded := false
+		if idbDecode {
+			value, decoded = decodeIndexedDBValue(key, value)
+		}
+		if stripHeader && !decoded {
+			if payload, offset, ok := stripValueHeader(key, value); ok {
+				switch {
+				case c.Bool("null"):
+				case offset < 0:
+					dimmed(color.Output, "(compressed payload) ")
+				default:
+					dimmed(color.Output, "(payload at offset %d) ", offset)
+				}
+				value = payload
+			}
+		}
+		if spec != nil {
+			value = spec.Apply(value)
+		}
+		if _, err := vw.Write(value); err != nil {
+			return err
+		}
+		_, err := os.Stdout.WriteString(terminator)
+		return err
+	}
+
+	scanner := newDBScanner(c, slice)
+	scan := func(t dbTarget) error {
+		return scanner.Scan(c.Context, t.Path, func(key, value []byte) error {
+			if !tm.Match(key) || ex.Match(key) {
+				return nil
+			}
+			if ttl != nil && !ttl.Match(value) {
+				return nil
+			}
+			if sorter != nil {
+				sorter.Add(t, key, value)
+				return nil
+			}
+			if limit > 0 && nentries >= limit {
+				return errStopScan
+			}
+			return printEntry(t, key, value)
+		})
+	}
+	for _, t := range targets {
+		if err := scan(t); err != nil {
+			return err
+		}
+	}
+	if sorter != nil {
+		for _, e := range sorter.Entries() {
+			if err := printEntry(e.Target, e.Key, e.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.Bool("stats") {
+		fmt.Fprintf(os.Stderr, "%d entries, %d bytes\n", nentries, nbytes)
+	}
+
+	return nil
+}
+
+func diskUsage(dbpath string, pattern *regexp.Regexp) (int64, error) {
+	entries, err := os.ReadDir(dbpath)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !pattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+// tableSize estimates the size of the tables occupied by slice.
+func tableSize(db *leveldb.DB, dbpath string, slice *util.Range) (int64, error) {
+	if slice != nil && slice.Limit != nil {
+		sizes, err := db.SizeOf([]util.Range{*slice})
+		if err != nil {
+			return 0, err
+		}
+		return sizes.Sum(), nil
+	}
+
+	// SizeOf measures up to the limit key, so an unbounded range is
+	// measured as the size of all live tables minus the part before the
+	// start.
+	info, err := readManifest(dbpath)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, li := range info.Levels {
+		total += li.Size
+	}
+	if slice != nil && slice.Start != nil {
+		sizes, err := db.SizeOf([]util.Range{{Limit: slice.Start}})
+		if err != nil {
+			return 0, err
+		}
+		total = max(total-sizes.Sum(), 0)
+	}
+	return total, nil
+}
+
+const countSampleSize = 1000
+
+// estimateCount estimates the number of entries in slice from the table
+// space occupied by the first countSampleSize entries. It returns the exact
+// count if there are fewer entries than that.
+func estimateCount(ctx context.Context, db *leveldb.DB, dbpath string, slice *util.Range) (n int64, exact bool, err error) {
+	var sampleEnd []byte
+	iter := db.NewIterator(slice, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := checkContext(ctx); err != nil {
+			return 0, false, err
+		}
+		n++
+		if n == countSampleSize {
+			if key := bytes.Clone(iter.Key()); iter.Next() {
+				sampleEnd = key
+			}
+			break
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return 0, false, err
+	}
+	iter.Release()
+	if sampleEnd == nil {
+		return n, true, nil
+	}
+
+	sample := &util.Range{Limit: sampleEnd}
+	if slice != nil {
+		sample.Start = slice.Start
+	}
+	sizes, err := db.SizeOf([]util.Range{*sample})
+	if err != nil {
+		return 0, false, err
+	}
+	total, err := tableSize(db, dbpath, slice)
+	if err != nil {
+		return 0, false, err
+	}
+	if sampled := sizes.Sum(); sampled > 0 && total > sampled {
+		n = int64(float64(n) * float64(total) / float64(sampled))
+	}
+	return n, false, nil
+}
+
+// printJSON writes v to stdout as a single line of JSON.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+type countResult struct {
+	Count int64 `json:"count"`
+	Exact bool  `json:"exact"`
+}
+
+type sizeResult struct {
+	Entries      int64 `json:"entries"`
+	KeyBytes     int64 `json:"key_bytes"`
+	ValueBytes   int64 `json:"value_bytes"`
+	LogicalBytes int64 `json:"logical_bytes"`
+	TableBytes   int64 `json:"table_bytes"`
+	// TotalBytes is only reported for the whole database.
+	TotalBytes *int64 `json:"total_bytes,omitempty"`
+}
+
+type describeLevel struct {
+	Level int   `json:"level"`
+	Files int   `json:"files"`
+	Size  int64 `json:"size"`
+}
+
+type describeResult struct {
+	Manifest     string          `json:"manifest"`
+	Comparer     string          `json:"comparer"`
+	LastSequence uint64          `json:"last_sequence"`
+	Journal      string          `json:"journal"`
+	NextFile     uint64          `json:"next_file"`
+	TotalBytes   int64           `json:"total_bytes"`
+	Levels       []describeLevel `json:"levels"`
+	TotalFiles   int             `json:"total_files"`
+	TotalSize    int64           `json:"total_size"`
+	Runtime      []dbProperty    `json:"runtime,omitempty"`
+}
+
+type dbProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// runtimePropertyNames are the goleveldb properties describing the
+// memory and file handles held by an open database.
+var runtimePropertyNames = []string{
+	"leveldb.blockpool",
+	"leveldb.cachedblock",
+	"leveldb.openedtables",
+	"leveldb.alivesnaps",
+	"leveldb.aliveiters",
+}
+
+func runtimeProperties(db *leveldb.DB) ([]dbProperty, error) {
+	props := make([]dbProperty, 0, len(runtimePropertyNames))
+	for _, name := range runtimePropertyNames {
+		value, err := db.GetProperty(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		props = append(props, dbProperty{Name: name, Value: value})
+	}
+	return props, nil
+}
+
+func countCmd(c *cli.Context) error {
+	if c.NArg() != 0 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	slice, err := getKeyRange(c)
+	if err != nil {
+		return err
+	}
+	dbpath := c.String("dbpath")
+
+	if c.Bool("estimate") {
+		o := getOptions(c)
+		o.ErrorIfMissing = true
+		o.ReadOnly = true
+		db, err := openDB(dbpath, o)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		n, exact, err := estimateCount(c.Context, db, dbpath, slice)
+		if err != nil {
+			return err
+		}
+		if err := db.Close(); err != nil {
+			return err
+		}
+		if c.Bool("json") {
+			return printJSON(countResult{Count: n, Exact: exact})
+		}
+		if exact {
+			fmt.Println(n)
+		} else {
+			fmt.Printf("~%d (estimate)\n", n)
+		}
+		return nil
+	}
+
+	var n int64
+	err = newDBScanner(c, slice).Scan(c.Context, dbpath, func(key, value []byte) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		return printJSON(countResult{Count: n, Exact: true})
+	}
+	fmt.Println(n)
+
+	return nil
+}
+
+func sizeCmd(c *cli.Context) error {
+	if c.NArg() != 0 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	slice, err := getKeyRange(c)
+	if err != nil {
+		return err
+	}
+	dbpath := c.String("dbpath")
+
+	var nentries, keyBytes, valueBytes, tableBytes int64
+	scanner := newDBScanner(c, slice)
+	scanner.Finish = func(db *leveldb.DB) (err error) {
+		tableBytes, err = tableSize(db, dbpath, slice)
+		return err
+	}
+	err = scanner.Scan(c.Context, dbpath, func(key, value []byte) error {
+		nentries++
+		keyBytes += int64(len(key))
+		valueBytes += int64(len(value))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	result := sizeResult{
+		Entries:      nentries,
+		KeyBytes:     keyBytes,
+		ValueBytes:   valueBytes,
+		LogicalBytes: keyBytes + valueBytes,
+		TableBytes:   tableBytes,
+	}
+	if !hasKeyRange(c) {
+		totalBytes, err := diskUsage(dbpath, leveldbFilenamePattern)
+		if err != nil {
+			return err
+		}
+		result.TotalBytes = &totalBytes
+	}
+	if c.Bool("json") {
+		return printJSON(result)
+	}
+
+	fmt.Printf("Entries:       %d\n", result.Entries)
+	fmt.Printf("Logical size:  %d bytes (keys %d, values %d)\n", result.LogicalBytes, result.KeyBytes, result.ValueBytes)
+	fmt.Printf("Table size:    %d bytes (estimated)\n", result.TableBytes)
+	if result.TotalBytes != nil {
+		fmt.Printf("Total on disk: %d bytes\n", *result.TotalBytes)
+	}
+
+	return nil
+}
+
+func describeCmd(c *cli.Context) error {
+	if c.NArg() != 0 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	dbpath := c.String("dbpath")
+	info, err := readManifest(dbpath)
+	if err != nil {
+		return err
+	}
+	totalBytes, err := diskUsage(dbpath, leveldbFilenamePattern)
+	if err != nil {
+		return err
+	}
+
+	result := describeResult{
+		Manifest:     info.Name,
+		Comparer:     info.Comparer,
+		LastSequence: info.LastSequence,
+		Journal:      fmt.Sprintf("%06d.log", info.JournalNum),
+		NextFile:     info.NextFileNum,
+		TotalBytes:   totalBytes,
+		Levels:       []describeLevel{},
+	}
+	for level, li := range info.Levels {
+		if li.Files == 0 {
+			continue
+		}
+		result.Levels = append(result.Levels, describeLevel{Level: level, Files: li.Files, Size: li.Size})
+		result.TotalFiles += li.Files
+		result.TotalSize += li.Size
+	}
+	// The properties of a database that has just been opened mostly show
+	// empty caches; scans log them at their end with --verbose.
+	if c.Bool("runtime") {
+		o := getOptions(c)
+		o.ErrorIfMissing = true
+		o.ReadOnly = true
+		db, err := openDB(dbpath, o)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if result.Runtime, err = runtimeProperties(db); err != nil {
+			return err
+		}
+		if err := db.Close(); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("json") {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Manifest:       %s\n", result.Manifest)
+		fmt.Printf("Comparer:       %s\n", result.Comparer)
+		fmt.Printf("Last sequence:  %d\n", result.LastSequence)
+		fmt.Printf("Journal:        %s\n", result.Journal)
+		fmt.Printf("Next file:      %d\n", result.NextFile)
+		fmt.Printf("Total on disk:  %d bytes\n", result.TotalBytes)
+		fmt.Println()
+		fmt.Println("Level  Files  Size")
+		for _, l := range result.Levels {
+			fmt.Printf("%5d  %5d  %d\n", l.Level, l.Files, l.Size)
+		}
+		fmt.Printf("Total  %5d  %d\n", result.TotalFiles, result.TotalSize)
+		if result.Runtime != nil {
+			fmt.Println()
+			fmt.Println("Runtime properties after opening (use --verbose with a scan to see them after it):")
+			for _, p := range result.Runtime {
+				fmt.Printf("%-22s %s\n", p.Name+":", p.Value)
+			}
+		}
+	}
+
+	if name := getComparer(c).Name(); info.Comparer != "" && info.Comparer != name {
+		fmt.Fprintf(os.Stderr, "leveldb: warning: the database uses the %q comparer, but %q is selected\n", info.Comparer, name)
+	}
+
+	return nil
+}
+
+func openTable(name string, o *opt.Options) (*table.Reader, error) {
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+
+	fd := storage.FileDesc{Type: storage.TypeTable}
+	fmt.Sscanf(path.Base(name), "%d", &fd.Num)
+
+	// The returned reader closes fh when released.
+	r, err := table.NewReader(fh, fi.Size(), fd, nil, nil, o)
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func sstCmd(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	kw, vw, err := getEntryWriters(c)
+	if err != nil {
+		return err
+	}
+	keysOnly, allVersions := c.Bool("keys-only"), c.Bool("all-versions")
+	minSeq := c.Uint64("min-sequence")
+
+	r, err := openTable(c.Args().Get(0), getOptions(c))
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+
+	var prevKey []byte
+	seen := false
+	iter := r.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := checkContext(c.Context); err != nil {
+			return err
+		}
+		// Keys in a table are internal keys: the user key followed by
+		// a 56-bit sequence number and an 8-bit value type. Versions of
+		// a key are ordered from the newest.
+		ikey := iter.Key()
+		if len(ikey) < 8 {
+			return fmt.Errorf("%s: invalid internal key %q", c.Args().Get(0), ikey)
+		}
+		key := ikey[:len(ikey)-8]
+		if !allVersions {
+			if seen && bytes.Equal(key, prevKey) {
+				continue
+			}
+			prevKey, seen = append(prevKey[:0], key...), true
+		}
+		if ikey[len(ikey)-8] != 1 {
+			continue
+		}
+		if binary.LittleEndian.Uint64(ikey[len(ikey)-8:])>>8 < minSeq {
+			continue
+		}
+
+		if _, err := kw.Write(key); err != nil {
+			return err
+		}
+		if !keysOnly {
+			if _, err := os.Stdout.WriteString(": "); err != nil {
+				return err
+			}
+			if _, err := vw.Write(iter.Value()); err != nil {
+				return err
+			}
+		}
+		if _, err := os.Stdout.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func keysCmd(c *cli.Context) error {
-	var w io.Writer
-	if c.Bool("base64") {
-		w = newBase64Writer(os.Stdout)
-	} else if c.Bool("raw") {
-		w = os.Stdout
-	} else {
-		w = newPrettyPrinter(os.Stdout)
+func hashCmd(c *cli.Context) error {
+	newHash, err := getHashFunc(c)
+	if err != nil {
+		return err
 	}
 
 	slice, err := getKeyRange(c)
@@ -367,117 +2439,161 @@ func keysCmd(c *cli.Context) error {
 		return err
 	}
 
-	db, err := leveldb.OpenFile(c.String("dbpath"), &opt.Options{
-		Comparer:       getComparer(c),
-		ErrorIfMissing: true,
-		ReadOnly:       true,
+	h := newHash()
+	var buf []byte
+	err = newDBScanner(c, slice).Scan(c.Context, c.String("dbpath"), func(key, value []byte) error {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(key)))
+		buf = append(buf, key...)
+		buf = binary.AppendUvarint(buf, uint64(len(value)))
+		buf = append(buf, value...)
+		h.Write(buf)
+		return nil
 	})
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
-	s, err := db.GetSnapshot()
-	if err != nil {
+	if _, err := fmt.Println(hex.EncodeToString(h.Sum(nil))); err != nil {
 		return err
 	}
-	defer s.Release()
 
-	iter := s.NewIterator(slice, nil)
-	defer iter.Release()
-	for iter.Next() {
-		if _, err := w.Write(iter.Key()); err != nil {
-			return err
-		}
-		if _, err := os.Stdout.WriteString("\n"); err != nil {
-			return err
-		}
-	}
-	if err := iter.Error(); err != nil {
-		return err
-	}
+	return nil
+}
 
-	iter.Release()
-	s.Release()
-	if err := db.Close(); err != nil {
-		return err
+func firstTableKey(name string, o *opt.Options) ([]byte, error) {
+	r, err := openTable(name, o)
+	if err != nil {
+		return nil, err
 	}
+	defer r.Release()
 
-	return nil
+	iter := r.NewIterator(nil, nil)
+	defer iter.Release()
+	if !iter.First() {
+		return nil, iter.Error()
+	}
+	ikey := iter.Key()
+	if len(ikey) < 8 {
+		return nil, fmt.Errorf("%s: invalid internal key %q", name, ikey)
+	}
+	return bytes.Clone(ikey[:len(ikey)-8]), nil
 }
 
-func showCmd(c *cli.Context) error {
-	var kw, vw io.Writer
-	if c.Bool("base64") {
-		kw = newBase64Writer(os.Stdout)
-		vw = newBase64Writer(os.Stdout)
-	} else if c.Bool("raw") {
-		kw = os.Stdout
-		vw = os.Stdout
-	} else {
-		kw = newPrettyPrinter(color.Output).SetQuoting(true)
-		vw = newPrettyPrinter(color.Output).
-			SetQuoting(true).
-			SetTruncate(!c.Bool("no-truncate")).
-			SetParseJSON(!c.Bool("no-json"))
+// sampleSplitKeys returns at most n-1 keys that partition the key space into
+// ranges of similar size. The keys are sampled from the first key of each
+// table file.
+func sampleSplitKeys(dbpath string, o *opt.Options, n int) ([][]byte, error) {
+	dir, err := os.Open(dbpath)
+	if err != nil {
+		return nil, err
 	}
+	defer dir.Close()
 
-	slice, err := getKeyRange(c)
+	names, err := dir.Readdirnames(0)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	db, err := leveldb.OpenFile(c.String("dbpath"), &opt.Options{
-		Comparer:       getComparer(c),
-		ErrorIfMissing: true,
-		ReadOnly:       true,
-	})
-	if err != nil {
-		return err
+	var keys [][]byte
+	for _, filename := range names {
+		if !tableFilenamePattern.MatchString(filename) {
+			continue
+		}
+		key, err := firstTableKey(path.Join(dbpath, filename), o)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			keys = append(keys, key)
+		}
 	}
-	defer db.Close()
 
-	s, err := db.GetSnapshot()
-	if err != nil {
-		return err
+	cmp := o.GetComparer()
+	slices.SortFunc(keys, cmp.Compare)
+	keys = slices.CompactFunc(keys, func(a, b []byte) bool {
+		return cmp.Compare(a, b) == 0
+	})
+
+	var splitKeys [][]byte
+	last := 0
+	for i := 1; i < n; i++ {
+		if j := i * len(keys) / n; j > last {
+			splitKeys = append(splitKeys, keys[j])
+			last = j
+		}
 	}
-	defer s.Release()
+	return splitKeys, nil
+}
+
+// readEntries reads up to limit entries in slice, or all of them if limit
+// is 0.
+func readEntries(ctx context.Context, s *leveldb.Snapshot, slice *util.Range, limit int) ([]entry, error) {
+	var entries []entry
 
 	iter := s.NewIterator(slice, nil)
 	defer iter.Release()
 	for iter.Next() {
-		if _, err := kw.Write(iter.Key()); err != nil {
-			return err
+		if limit > 0 && len(entries) >= limit {
+			break
 		}
-		if _, err := os.Stdout.WriteString(": "); err != nil {
-			return err
-		}
-		if _, err := vw.Write(iter.Value()); err != nil {
-			return err
-		}
-		if _, err := os.Stdout.WriteString("\n"); err != nil {
-			return err
+		if err := checkContext(ctx); err != nil {
+			return nil, err
 		}
+		entries = append(entries, entry{
+			Key:   bytes.Clone(iter.Key()),
+			Value: bytes.Clone(iter.Value()),
+		})
 	}
 	if err := iter.Error(); err != nil {
-		return err
+		return nil, err
 	}
 
-	iter.Release()
-	s.Release()
-	if err := db.Close(); err != nil {
-		return err
+	return entries, nil
+}
+
+// splitRanges returns the ranges that splitKeys divide the key space into.
+func splitRanges(splitKeys [][]byte) []*util.Range {
+	ranges := make([]*util.Range, len(splitKeys)+1)
+	for i := range ranges {
+		ranges[i] = &util.Range{}
+		if i > 0 {
+			ranges[i].Start = splitKeys[i-1]
+		}
+		if i < len(splitKeys) {
+			ranges[i].Limit = splitKeys[i]
+		}
 	}
+	return ranges
+}
 
-	return nil
+func readEntriesParallel(ctx context.Context, s *leveldb.Snapshot, splitKeys [][]byte) ([]entry, error) {
+	ranges := splitRanges(splitKeys)
+	results := make([][]entry, len(ranges))
+	errs := make([]error, len(ranges))
+
+	var wg sync.WaitGroup
+	for i, slice := range ranges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = readEntries(ctx, s, slice, 0)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return slices.Concat(results...), nil
 }
 
-func dumpDB(dbpath string, cmp comparer.Comparer, w io.Writer) error {
-	db, err := leveldb.OpenFile(dbpath, &opt.Options{
-		Comparer:       cmp,
-		ErrorIfMissing: true,
-		ReadOnly:       true,
-	})
+func dumpDB(ctx context.Context, dbpath string, o *opt.Options, w io.Writer, do *dumpOptions) error {
+	ro := *o
+	ro.ErrorIfMissing = true
+	ro.ReadOnly = true
+	db, err := openDB(dbpath, &ro)
 	if err != nil {
 		return err
 	}
@@ -490,67 +2606,107 @@ func dumpDB(dbpath string, cmp comparer.Comparer, w io.Writer) error {
 	defer s.Release()
 
 	var entries []entry
-
-	iter := s.NewIterator(nil, nil)
-	defer iter.Release()
-	for iter.Next() {
-		entries = append(entries, entry{
-			Key:   bytes.Clone(iter.Key()),
-			Value: bytes.Clone(iter.Value()),
-		})
-	}
-	if err := iter.Error(); err != nil {
-		return err
+	if do.Parallel > 1 {
+		splitKeys, err := sampleSplitKeys(dbpath, &ro, do.Parallel)
+		if err != nil {
+			return err
+		}
+		entries, err = readEntriesParallel(ctx, s, splitKeys)
+		if err != nil {
+			return err
+		}
+	} else {
+		entries, err = readEntries(ctx, s, do.Range, do.MaxEntries)
+		if err != nil {
+			return err
+		}
 	}
 
-	iter.Release()
 	s.Release()
 	if err := db.Close(); err != nil {
 		return err
 	}
 
-	enc := msgpack.NewEncoder(w)
-	enc.UseCompactInts(true)
-	if err := enc.EncodeMapLen(len(entries)); err != nil {
+	hdr := dumpHeader{Comparer: ro.GetComparer().Name(), Columns: do.Columns, NoHeader: do.NoHeader}
+	var enc dumpEncoder
+	if do.Format == "archive" {
+		enc, err = newArchiveEncoder(w, do.ArchiveFormat, len(entries), hdr)
+	} else {
+		enc, err = newDumpEncoder(do.Format, w, len(entries), hdr)
+	}
+	if err != nil {
 		return err
 	}
-	for _, entry := range entries {
-		if err := enc.EncodeBytes(entry.Key); err != nil {
+	for i, entry := range entries {
+		if err := checkContext(ctx); err != nil {
 			return err
 		}
-		if err := enc.EncodeBytes(entry.Value); err != nil {
+		if err := enc.Encode(entry.Key, entry.Value); err != nil {
 			return err
 		}
+		if do.Preview != nil && i < do.PreviewEntries {
+			if err := do.Preview(entry.Key, entry.Value); err != nil {
+				return err
+			}
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func loadDB(dbpath string, cmp comparer.Comparer, r io.Reader) error {
-	dec := msgpack.NewDecoder(r)
-
-	nentries, err := dec.DecodeMapLen()
-	if err != nil {
+func loadDB(ctx context.Context, dbpath string, o *opt.Options, r io.Reader, lo *loadOptions) error {
+	var dec dumpDecoder
+	var err error
+	if lo.NoHeader {
+		if lo.Format != "csv" {
+			return errors.New("option --no-header requires --format csv")
+		}
+		dec = newHeaderlessCSVDecoder(r)
+	} else if dec, err = newDumpDecoder(lo.Format, r); err != nil {
 		return err
 	}
-
-	entries := make([]entry, nentries)
-	for i := 0; i < nentries; i++ {
-		key, err := dec.DecodeBytes()
+	if cn, ok := dec.(comparerNamer); ok && cn.ComparerName() != "" && cn.ComparerName() != o.GetComparer().Name() {
+		if lo.StrictComparer {
+			return fmt.Errorf("%w: the dump was made with comparer %s, but %s is selected", errComparerMismatch, cn.ComparerName(), o.GetComparer().Name())
+		}
+		cmp, err := comparerByName(cn.ComparerName())
 		if err != nil {
 			return err
 		}
-		value, err := dec.DecodeBytes()
-		if err != nil {
+		co := *o
+		co.Comparer = cmp
+		o = &co
+	}
+
+	var entries []entry
+	for {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		key, value, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
 			return err
 		}
-		entries[i].Key = key
-		entries[i].Value = value
+		if key == nil {
+			return errors.New("the dump has no keys (was it made with --values-only?)")
+		}
+		entries = append(entries, entry{Key: key, Value: value})
 	}
 
-	db, err := leveldb.OpenFile(dbpath, &opt.Options{
-		Comparer: cmp,
+	// Write in comparer order so that the batch lands in the memtable and
+	// flushes as sequential tables. The sort is stable so that the last of
+	// duplicate keys still wins.
+	cmp := o.GetComparer()
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return cmp.Compare(a.Key, b.Key)
 	})
+
+	db, err := openDB(dbpath, o)
 	if err != nil {
 		return err
 	}
@@ -558,9 +2714,29 @@ func loadDB(dbpath string, cmp comparer.Comparer, r io.Reader) error {
 
 	batch := new(leveldb.Batch)
 	for _, entry := range entries {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		switch lo.Conflict {
+		case skipOnConflict:
+			found, err := db.Has(entry.Key, nil)
+			if err != nil {
+				return err
+			}
+			if found {
+				continue
+			}
+		case errorOnConflict:
+			value, err := db.Get(entry.Key, nil)
+			if err == nil && !bytes.Equal(value, entry.Value) {
+				return fmt.Errorf("key %q already exists with a different value", entry.Key)
+			} else if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+				return err
+			}
+		}
 		batch.Put(entry.Key, entry.Value)
 	}
-	if err := db.Write(batch, nil); err != nil {
+	if err := db.Write(batch, &opt.WriteOptions{Sync: lo.Sync}); err != nil {
 		return err
 	}
 
@@ -604,7 +2780,110 @@ func destroyDB(dbpath string, dryRun bool) error {
 	return nil
 }
 
+// destroyDBParallel is like destroyDB, but removes the files with up to
+// workers goroutines and returns the number of files removed. CURRENT is
+// removed first, so the database cannot be opened even if the removal is
+// interrupted.
+func destroyDBParallel(ctx context.Context, dbpath string, workers int) (int, error) {
+	dir, err := os.Open(dbpath)
+	if err != nil {
+		return 0, err
+	}
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(0)
+	if err != nil {
+		return 0, err
+	}
+
+	var nremoved atomic.Int64
+	if i := slices.Index(names, "CURRENT"); i >= 0 {
+		if err := os.Remove(path.Join(dbpath, "CURRENT")); err != nil {
+			return 0, err
+		}
+		nremoved.Add(1)
+		names = slices.Delete(names, i, i+1)
+	}
+
+	ch := make(chan string)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range ch {
+				if errs[i] != nil {
+					continue
+				}
+				if errs[i] = os.Remove(target); errs[i] == nil {
+					nremoved.Add(1)
+				}
+			}
+		}()
+	}
+	for _, filename := range names {
+		if checkContext(ctx) != nil {
+			break
+		}
+		if leveldbFilenamePattern.MatchString(filename) {
+			ch <- path.Join(dbpath, filename)
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	if err := checkContext(ctx); err != nil {
+		return int(nremoved.Load()), err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return int(nremoved.Load()), err
+	}
+	return int(nremoved.Load()), dir.Close()
+}
+
+// newDumpPreview returns a function that prints an entry to w in the
+// format of show.
+func newDumpPreview(w io.Writer, useColor bool) func(key, value []byte) error {
+	kf := format.NewFormatter(w).SetQuoting(true).SetColor(useColor)
+	vf := format.NewFormatter(w).SetQuoting(true).SetTruncate(format.DefaultTruncate).SetParseJSON(true).SetColor(useColor)
+	return func(key, value []byte) error {
+		if _, err := kf.Write(key); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, ": "); err != nil {
+			return err
+		}
+		if _, err := vf.Write(value); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+}
+
 func dumpCmd(c *cli.Context) error {
+	if c.Int("parallel") < 1 {
+		return fmt.Errorf("option --parallel: must be a positive integer")
+	}
+	if c.Int("max-entries") < 0 {
+		return errors.New("option --max-entries: must not be negative")
+	}
+	if c.Int("parallel") > 1 && (hasKeyRange(c) || c.Int("max-entries") > 0) {
+		return errors.New("option --parallel cannot be used with a key range or --max-entries")
+	}
+	columns := keyValueColumns
+	if c.Bool("keys-only") && c.Bool("values-only") {
+		return errors.New("options --keys-only and --values-only are mutually exclusive")
+	} else if c.Bool("keys-only") {
+		columns = keyColumn
+	} else if c.Bool("values-only") {
+		columns = valueColumn
+	}
+	if c.Bool("no-header") && c.String("format") != "csv" {
+		return errors.New("option --no-header requires --format csv")
+	}
+
 	var w io.Writer = os.Stdout
 	if c.NArg() >= 1 && c.Args().Get(0) != "-" {
 		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
@@ -619,10 +2898,59 @@ func dumpCmd(c *cli.Context) error {
 		w = fh
 	}
 
-	return dumpDB(c.String("dbpath"), getComparer(c), w)
+	cw, err := newCompressWriter(w, c.String("compress"))
+	if err != nil {
+		return fmt.Errorf("option --compress: %w", err)
+	}
+	defer cw.Close()
+
+	slice, err := getKeyRange(c)
+	if err != nil {
+		return err
+	}
+	do := &dumpOptions{
+		Format:        c.String("format"),
+		ArchiveFormat: c.String("archive-format"),
+		Columns:       columns,
+		NoHeader:      c.Bool("no-header"),
+		Parallel:      c.Int("parallel"),
+		Range:         slice,
+		MaxEntries:    c.Int("max-entries"),
+	}
+	if c.IsSet("tee") {
+		if c.Int("tee") < 0 {
+			return errors.New("option --tee: must not be negative")
+		}
+		do.Preview = newDumpPreview(color.Error, isTerminal(os.Stderr))
+		do.PreviewEntries = c.Int("tee")
+	}
+	if err := dumpDB(c.Context, c.String("dbpath"), getOptions(c), cw, do); err != nil {
+		return err
+	}
+
+	if err := cw.Close(); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func loadCmd(c *cli.Context) error {
+	lo := &loadOptions{
+		Format:         c.String("format"),
+		Conflict:       overwriteOnConflict,
+		Sync:           c.Bool("sync"),
+		StrictComparer: c.IsSet("indexeddb"),
+		NoHeader:       c.Bool("no-header"),
+	}
+	if c.Bool("no-overwrite") && c.Bool("error-on-conflict") {
+		return errors.New("options --no-overwrite and --error-on-conflict are mutually exclusive")
+	} else if c.Bool("no-overwrite") {
+		lo.Conflict = skipOnConflict
+	} else if c.Bool("error-on-conflict") {
+		lo.Conflict = errorOnConflict
+	}
+
 	var r io.Reader = os.Stdin
 	if c.NArg() >= 1 && c.Args().Get(0) != "-" {
 		fh, err := os.Open(c.Args().Get(0))
@@ -633,11 +2961,40 @@ func loadCmd(c *cli.Context) error {
 		r = fh
 	}
 
-	return loadDB(c.String("dbpath"), getComparer(c), r)
+	o := getOptions(c)
+	if c.IsSet("write-buffer") {
+		if c.Int("write-buffer") <= 0 {
+			return errors.New("option --write-buffer: must be a positive integer")
+		}
+		o.WriteBuffer = c.Int("write-buffer") * opt.MiB
+	}
+	if c.IsSet("block-size") {
+		if c.Int("block-size") <= 0 {
+			return errors.New("option --block-size: must be a positive integer")
+		}
+		o.BlockSize = c.Int("block-size") * opt.KiB
+	}
+
+	dr, err := newDecompressReader(r)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	return loadDB(c.Context, c.String("dbpath"), o, dr, lo)
 }
 
 func repairCmd(c *cli.Context) (err error) {
-	db, err := leveldb.RecoverFile(c.String("dbpath"), nil)
+	// RecoverFile creates an empty database if there is nothing to recover.
+	dbpath := c.String("dbpath")
+	ok, err := hasDBFiles(dbpath)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("%s contains no LevelDB files to repair", dbpath)
+	}
+
+	db, err := leveldb.RecoverFile(dbpath, getOptions(c))
 	if err != nil {
 		return err
 	}
@@ -647,9 +3004,60 @@ func repairCmd(c *cli.Context) (err error) {
 	return nil
 }
 
-func compactCmd(c *cli.Context) error {
+// compactDryRun prints the sizes compactCmd would start from and an
+// estimate of the space it would reclaim. Compaction drops obsolete
+// entries but compresses no better than the current tables, so the
+// compacted tables are estimated at the smaller of the logical size and
+// the size of the current tables.
+func compactDryRun(c *cli.Context) error {
 	dbpath := c.String("dbpath")
-	cmp := getComparer(c)
+
+	var nentries, logicalBytes int64
+	err := newDBScanner(c, nil).Scan(c.Context, dbpath, func(key, value []byte) error {
+		nentries++
+		logicalBytes += int64(len(key) + len(value))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err := readManifest(dbpath)
+	if err != nil {
+		return err
+	}
+	totalBytes, err := diskUsage(dbpath, leveldbFilenamePattern)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Entries:       %d\n", nentries)
+	fmt.Printf("Logical size:  %d bytes\n", logicalBytes)
+	fmt.Printf("Total on disk: %d bytes\n", totalBytes)
+	var tableBytes int64
+	for level, li := range info.Levels {
+		if li.Files > 0 {
+			fmt.Printf("Level %d:       %d tables, %d bytes\n", level, li.Files, li.Size)
+		}
+		tableBytes += li.Size
+	}
+	fmt.Printf("Reclaimable:   about %d bytes (estimated)\n", max(totalBytes-min(logicalBytes, tableBytes), 0))
+
+	return nil
+}
+
+func compactCmd(c *cli.Context) error {
+	if c.Bool("dry-run") {
+		return compactDryRun(c)
+	}
+	return compactDB(c.Context, c.String("dbpath"), getOptions(c))
+}
+
+// compactDB rewrites the database at dbpath by dumping it to a backup file,
+// destroying it and loading the backup. Once the database has been
+// destroyed, the reload ignores ctx, since interrupting it would leave the
+// data only in the backup file.
+func compactDB(ctx context.Context, dbpath string, o *opt.Options) error {
 	bakfile := path.Join(dbpath, "leveldb.bak")
 
 	bak, err := os.OpenFile(bakfile, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
@@ -658,7 +3066,7 @@ func compactCmd(c *cli.Context) error {
 	}
 	defer bak.Close()
 
-	if err := dumpDB(dbpath, cmp, bak); err != nil {
+	if err := dumpDB(ctx, dbpath, o, bak, &dumpOptions{Format: "msgpack"}); err != nil {
 		bak.Close()
 		os.Remove(bakfile)
 		return err
@@ -669,11 +3077,15 @@ func compactCmd(c *cli.Context) error {
 	if err := bak.Sync(); err != nil {
 		return err
 	}
+
+	kept := func(err error) error {
+		return fmt.Errorf("%w; the data is kept in %s (restore it with: leveldb -d %s load %s)", err, bakfile, dbpath, bakfile)
+	}
 	if err := destroyDB(dbpath, false); err != nil {
-		return err
+		return kept(err)
 	}
-	if err := loadDB(dbpath, cmp, bak); err != nil {
-		return err
+	if err := loadDB(context.Background(), dbpath, o, bak, &loadOptions{Format: "msgpack"}); err != nil {
+		return kept(err)
 	}
 	if err := bak.Close(); err != nil {
 		return err
@@ -686,5 +3098,19 @@ func compactCmd(c *cli.Context) error {
 }
 
 func destroyCmd(c *cli.Context) error {
-	return destroyDB(c.String("dbpath"), c.Bool("dry-run"))
+	dbpath := c.String("dbpath")
+	dryRun := c.Bool("dry-run")
+	if !dryRun && needConfirmation(c) {
+		if ok, err := confirm(fmt.Sprintf("Destroy the database at %s?", dbpath)); err != nil {
+			return err
+		} else if !ok {
+			return errAborted
+		}
+	}
+	if c.Bool("fast") && !dryRun {
+		n, err := destroyDBParallel(c.Context, dbpath, destroyWorkers)
+		fmt.Fprintf(os.Stderr, "Removed %d files\n", n)
+		return err
+	}
+	return destroyDB(dbpath, dryRun)
 }
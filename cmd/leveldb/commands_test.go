@@ -4,7 +4,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/cions/leveldb-cli/format"
+	"github.com/cions/leveldb-cli/indexeddb"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/urfave/cli/v2"
 )
 
 func TestLevelDBFilenamePattern(t *testing.T) {
@@ -55,3 +76,679 @@ func TestLevelDBFilenamePattern(t *testing.T) {
 		}
 	}
 }
+
+func TestDumpDBParallel(t *testing.T) {
+	dbpath := t.TempDir()
+
+	db, err := leveldb.OpenFile(dbpath, &opt.Options{
+		WriteBuffer: 64 * opt.KiB,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := bytes.Repeat([]byte("v"), 1000)
+	for i := range 1000 {
+		if err := db.Put([]byte(fmt.Sprintf("key%04d", i)), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen to settle the journals left by background compactions.
+	db, err = leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &opt.Options{}
+	splitKeys, err := sampleSplitKeys(dbpath, o, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(splitKeys) == 0 {
+		t.Fatal("sampleSplitKeys: no split keys found")
+	}
+
+	serial := new(bytes.Buffer)
+	if err := dumpDB(context.Background(), dbpath, o, serial, &dumpOptions{Format: "msgpack"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, parallel := range []int{2, 4, 16} {
+		buf := new(bytes.Buffer)
+		if err := dumpDB(context.Background(), dbpath, o, buf, &dumpOptions{Format: "msgpack", Parallel: parallel}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), serial.Bytes()) {
+			t.Errorf("dumpDB(parallel=%d) differs from the serial dump", parallel)
+		}
+	}
+	limited := new(bytes.Buffer)
+	if err := dumpDB(context.Background(), dbpath, o, limited, &dumpOptions{Format: "msgpack", Range: &util.Range{Start: []byte("key0500")}, MaxEntries: 10}); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := newDumpDecoder("msgpack", limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		key, _, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("limited dump: entry %d: unexpected error: %v", i, err)
+		}
+		if want := fmt.Sprintf("key%04d", 500+i); string(key) != want {
+			t.Errorf("limited dump: entry %d = %q, want %q", i, key, want)
+		}
+	}
+	if _, _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("limited dump: expected io.EOF, got %v", err)
+	}
+}
+
+// idbNumberKey returns an IndexedDB object store data key with a number
+// primary key, which idb_cmp1 orders numerically rather than bytewise.
+func idbNumberKey(f float64) []byte {
+	key := []byte{0x00, 0x01, 0x01, 0x01, 0x03}
+	return binary.LittleEndian.AppendUint64(key, math.Float64bits(f))
+}
+
+func TestDumpLoadIndexedDBOrder(t *testing.T) {
+	o := &opt.Options{Comparer: indexeddb.Comparer}
+
+	var keys [][]byte
+	for i := -50; i < 50; i++ {
+		keys = append(keys, idbNumberKey(float64(i)))
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	want := slices.Clone(keys)
+	slices.SortFunc(want, indexeddb.Comparer.Compare)
+
+	iterKeys := func(dbpath string) [][]byte {
+		db, err := leveldb.OpenFile(dbpath, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var got [][]byte
+		iter := db.NewIterator(nil, nil)
+		defer iter.Release()
+		for iter.Next() {
+			got = append(got, bytes.Clone(iter.Key()))
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// A dump written in arbitrary order loads in comparer order.
+	shuffled := new(bytes.Buffer)
+	enc, err := newDumpEncoder("msgpack", shuffled, len(keys), dumpHeader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := enc.Encode(key, []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	if err := loadDB(context.Background(), src, o, shuffled, &loadOptions{Format: "msgpack"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := iterKeys(src); !slices.EqualFunc(got, want, bytes.Equal) {
+		t.Errorf("load: iteration order = %x, want %x", got, want)
+	}
+
+	// A dump of that database is in comparer order and round-trips.
+	dump := new(bytes.Buffer)
+	if err := dumpDB(context.Background(), src, o, dump, &dumpOptions{Format: "msgpack"}); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := newDumpDecoder("msgpack", bytes.NewReader(dump.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dumped [][]byte
+	for {
+		key, _, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		dumped = append(dumped, key)
+	}
+	if !slices.EqualFunc(dumped, want, bytes.Equal) {
+		t.Errorf("dump: key order = %x, want %x", dumped, want)
+	}
+
+	dst := t.TempDir()
+	if err := loadDB(context.Background(), dst, o, dump, &loadOptions{Format: "msgpack"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := iterKeys(dst); !slices.EqualFunc(got, want, bytes.Equal) {
+		t.Errorf("dump and load: iteration order = %x, want %x", got, want)
+	}
+}
+
+func TestIntersectRange(t *testing.T) {
+	cases := []struct {
+		a, b, want util.Range
+	}{
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{}, util.Range{Start: []byte("b"), Limit: []byte("c")}},
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{Start: []byte("a")}, util.Range{Start: []byte("b"), Limit: []byte("c")}},
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{Start: []byte("bb")}, util.Range{Start: []byte("bb"), Limit: []byte("c")}},
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{Limit: []byte("bz")}, util.Range{Start: []byte("b"), Limit: []byte("bz")}},
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{Limit: []byte("d")}, util.Range{Start: []byte("b"), Limit: []byte("c")}},
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{Start: []byte("bb"), Limit: []byte("bc")}, util.Range{Start: []byte("bb"), Limit: []byte("bc")}},
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{Start: []byte("a"), Limit: []byte("d")}, util.Range{Start: []byte("b"), Limit: []byte("c")}},
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{Start: []byte("d")}, util.Range{Start: []byte("d"), Limit: []byte("c")}},
+		{util.Range{Start: []byte("b"), Limit: []byte("c")}, util.Range{Limit: []byte("a")}, util.Range{Start: []byte("b"), Limit: []byte("a")}},
+		{util.Range{Start: []byte("\xff")}, util.Range{Limit: []byte("\xff\x01")}, util.Range{Start: []byte("\xff"), Limit: []byte("\xff\x01")}},
+	}
+
+	for _, tc := range cases {
+		got := intersectRange(comparer.DefaultComparer, &tc.a, &tc.b)
+		if !bytes.Equal(got.Start, tc.want.Start) || !bytes.Equal(got.Limit, tc.want.Limit) {
+			t.Errorf("intersectRange(%q, %q) = %q, want %q", tc.a, tc.b, *got, tc.want)
+		}
+	}
+
+	// 0.5 sorts after 2 bytewise, but before it under idb_cmp1.
+	a := &util.Range{Start: idbNumberKey(2), Limit: idbNumberKey(10)}
+	b := &util.Range{Start: idbNumberKey(0.5), Limit: idbNumberKey(5)}
+	got := intersectRange(indexeddb.Comparer, a, b)
+	if !bytes.Equal(got.Start, idbNumberKey(2)) || !bytes.Equal(got.Limit, idbNumberKey(5)) {
+		t.Errorf("intersectRange(idb_cmp1) = [%x, %x), want [%x, %x)", got.Start, got.Limit, idbNumberKey(2), idbNumberKey(5))
+	}
+}
+
+// newTestContext returns a context with flags parsed from args.
+func newTestContext(t *testing.T, flags []cli.Flag, args ...string) *cli.Context {
+	t.Helper()
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range flags {
+		if err := f.Apply(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestGetOptionsCache(t *testing.T) {
+	flags := []cli.Flag{&cli.IntFlag{Name: "block-cache-size"}, &cli.IntFlag{Name: "bloom-filter-bits"}}
+	cases := []struct {
+		args     []string
+		capacity int
+		filter   string
+	}{
+		{nil, opt.DefaultBlockCacheCapacity, ""},
+		{[]string{"--block-cache-size", "64"}, 64 * opt.MiB, ""},
+		{[]string{"--block-cache-size", "0"}, 0, ""},
+		{[]string{"--bloom-filter-bits", "10"}, opt.DefaultBlockCacheCapacity, "leveldb.BuiltinBloomFilter"},
+	}
+	for _, tc := range cases {
+		o := getOptions(newTestContext(t, flags, tc.args...))
+		if got := o.GetBlockCacheCapacity(); got != tc.capacity {
+			t.Errorf("%q: block cache capacity = %d, want %d", tc.args, got, tc.capacity)
+		}
+		filter := ""
+		if f := o.GetFilter(); f != nil {
+			filter = f.Name()
+		}
+		if filter != tc.filter {
+			t.Errorf("%q: filter = %q, want %q", tc.args, filter, tc.filter)
+		}
+	}
+}
+
+func TestGetKeyRangeEndInclusive(t *testing.T) {
+	flags := append(keyRangeFlags(), &cli.BoolFlag{Name: "indexeddb"})
+	cases := []struct {
+		args       []string
+		start, end []byte
+	}{
+		{[]string{"--end", "b"}, nil, []byte("b")},
+		{[]string{"--end", "b", "--end-inclusive"}, nil, []byte("b\x00")},
+		{[]string{"--end", "", "--end-inclusive"}, nil, []byte("\x00")},
+		{[]string{"--start", "a", "--end-inclusive"}, []byte("a"), nil},
+	}
+
+	for _, tc := range cases {
+		slice, err := getKeyRange(newTestContext(t, flags, tc.args...))
+		if err != nil {
+			t.Errorf("getKeyRange(%q): unexpected error: %v", tc.args, err)
+			continue
+		}
+		if !bytes.Equal(slice.Start, tc.start) || !bytes.Equal(slice.Limit, tc.end) || (tc.end == nil) != (slice.Limit == nil) {
+			t.Errorf("getKeyRange(%q) = [%q, %q), want [%q, %q)", tc.args, slice.Start, slice.Limit, tc.start, tc.end)
+		}
+	}
+
+	if _, err := getKeyRange(newTestContext(t, flags, "--indexeddb", "--end", "\\x00\\x01\\x02\\x01", "--end-inclusive")); err == nil {
+		t.Error("getKeyRange: --end-inclusive with --indexeddb should fail")
+	}
+}
+
+func TestOpenDBErrors(t *testing.T) {
+	dbpath := t.TempDir()
+	db, err := openDB(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openDB(dbpath, nil); !errors.Is(err, errDatabaseLocked) {
+		t.Errorf("opening a locked database: got %v, want errDatabaseLocked", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = openDB(dbpath, &opt.Options{Comparer: indexeddb.Comparer})
+	if !errors.Is(err, errComparerMismatch) {
+		t.Errorf("opening with another comparer: got %v, want errComparerMismatch", err)
+	}
+}
+
+func TestForceComparer(t *testing.T) {
+	dbpath := t.TempDir()
+	custom := &forcedComparer{Comparer: comparer.DefaultComparer, name: "custom.Bytewise"}
+	db, err := leveldb.OpenFile(dbpath, &opt.Options{Comparer: custom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put([]byte("key"), []byte("value"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openDB(dbpath, &opt.Options{ErrorIfMissing: true}); !errors.Is(err, errComparerMismatch) {
+		t.Fatalf("opening without --force-comparer: got %v, want errComparerMismatch", err)
+	}
+	db, err = openDB(dbpath, &opt.Options{
+		Comparer:       &forcedComparer{Comparer: comparer.DefaultComparer},
+		ErrorIfMissing: true,
+	})
+	if err != nil {
+		t.Fatalf("opening with --force-comparer: unexpected error: %v", err)
+	}
+	defer db.Close()
+	if value, err := db.Get([]byte("key"), nil); err != nil || string(value) != "value" {
+		t.Errorf("Get(%q) = %q, %v, want %q", "key", value, err, "value")
+	}
+}
+
+func TestGetByPrefix(t *testing.T) {
+	db, err := leveldb.OpenFile(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, key := range []string{"a/1", "a/2", "b/1"} {
+		if err := db.Put([]byte(key), []byte("v"+key), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if value, err := getByPrefix(db, util.BytesPrefix([]byte("b")), []byte("b")); err != nil {
+		t.Errorf("getByPrefix(%q): unexpected error: %v", "b", err)
+	} else if string(value) != "vb/1" {
+		t.Errorf("getByPrefix(%q) = %q, want %q", "b", value, "vb/1")
+	}
+	if _, err := getByPrefix(db, util.BytesPrefix([]byte("a/")), []byte("a/")); err == nil {
+		t.Errorf("getByPrefix(%q): expected an error for multiple matches", "a/")
+	}
+	if _, err := getByPrefix(db, util.BytesPrefix([]byte("c")), []byte("c")); !errors.Is(err, leveldb.ErrNotFound) {
+		t.Errorf("getByPrefix(%q): got %v, want leveldb.ErrNotFound", "c", err)
+	}
+}
+
+func TestDestroyDBParallel(t *testing.T) {
+	dbpath := t.TempDir()
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put([]byte("key"), []byte("value"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dbpath, "notes.txt")
+	if err := os.WriteFile(other, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := destroyDBParallel(context.Background(), dbpath, 4)
+	if err != nil {
+		t.Fatalf("destroyDBParallel: unexpected error: %v", err)
+	}
+	if n == 0 {
+		t.Error("destroyDBParallel removed no files")
+	}
+	if ok, err := hasDBFiles(dbpath); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("destroyDBParallel left LevelDB files behind")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("destroyDBParallel removed an unrelated file: %v", err)
+	}
+}
+
+// destroyedContext is cancelled once the database at dbpath has been
+// destroyed, i.e. in the middle of compactDB.
+type destroyedContext struct {
+	context.Context
+	dbpath string
+}
+
+func (ctx destroyedContext) Err() error {
+	if _, err := os.Stat(filepath.Join(ctx.dbpath, "CURRENT")); err != nil {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestCompactDBCancel(t *testing.T) {
+	dbpath := t.TempDir()
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 100 {
+		if err := db.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("value"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int {
+		t.Helper()
+		db, err := leveldb.OpenFile(dbpath, &opt.Options{ErrorIfMissing: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		n := 0
+		iter := db.NewIterator(nil, nil)
+		for iter.Next() {
+			n++
+		}
+		iter.Release()
+		return n
+	}
+	bakfile := filepath.Join(dbpath, "leveldb.bak")
+
+	// Cancelled before the destroy: nothing is changed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := compactDB(ctx, dbpath, &opt.Options{}); err == nil {
+		t.Error("compactDB(cancelled): expected an error")
+	}
+	if n := count(); n != 100 {
+		t.Errorf("after a cancelled compaction: %d entries, want 100", n)
+	}
+	if _, err := os.Stat(bakfile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("backup file left behind: %v", err)
+	}
+
+	// Cancelled after the destroy: the reload still completes.
+	ctx = destroyedContext{context.Background(), dbpath}
+	if err := compactDB(ctx, dbpath, &opt.Options{}); err != nil {
+		t.Errorf("compactDB(cancelled after destroy): unexpected error: %v", err)
+	}
+	if n := count(); n != 100 {
+		t.Errorf("after compaction: %d entries, want 100", n)
+	}
+	if _, err := os.Stat(bakfile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("backup file left behind: %v", err)
+	}
+}
+
+func TestReadKeyLines(t *testing.T) {
+	r := strings.NewReader("a\\x00\r\n\nb\nc")
+	keys, err := readKeyLines(r, format.Unescape)
+	if err != nil {
+		t.Fatalf("readKeyLines: unexpected error: %v", err)
+	}
+	want := [][]byte{[]byte("a\x00"), []byte("b"), []byte("c")}
+	if !slices.EqualFunc(keys, want, bytes.Equal) {
+		t.Errorf("readKeyLines = %q, want %q", keys, want)
+	}
+
+	if _, err := readKeyLines(strings.NewReader("ok\n\\xZZ\n"), format.Unescape); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("readKeyLines: got %v, want an error on line 2", err)
+	}
+}
+
+func TestSliceSpec(t *testing.T) {
+	input := []byte("0123456789")
+	cases := []struct {
+		spec string
+		want []byte
+	}{
+		{":", []byte("0123456789")},
+		{"2:", []byte("23456789")},
+		{":3", []byte("012")},
+		{"2:5", []byte("234")},
+		{"-3:", []byte("789")},
+		{":-3", []byte("0123456")},
+		{"-5:-2", []byte("567")},
+		{"5:2", []byte("")},
+		{"-100:100", []byte("0123456789")},
+		{"100:", []byte("")},
+	}
+
+	for _, tc := range cases {
+		spec, err := parseSliceSpec(tc.spec)
+		if err != nil {
+			t.Errorf("parseSliceSpec(%q): unexpected error: %v", tc.spec, err)
+			continue
+		}
+		if got := spec.Apply(input); !bytes.Equal(got, tc.want) {
+			t.Errorf("%q: Apply(%q) = %q, want %q", tc.spec, input, got, tc.want)
+		}
+	}
+
+	for _, s := range []string{"", "1", "a:", ":b", "1:2:3"} {
+		if _, err := parseSliceSpec(s); err == nil {
+			t.Errorf("parseSliceSpec(%q) should fail", s)
+		}
+	}
+}
+
+func TestTrimNewline(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"value", "value"},
+		{"value\n", "value"},
+		{"value\r\n", "value"},
+		{"value\n\n", "value\n"},
+		{"value\r", "value\r"},
+		{"\n", ""},
+	}
+
+	for _, tc := range cases {
+		if got := trimNewline([]byte(tc.input)); string(got) != tc.want {
+			t.Errorf("trimNewline(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestWrapPattern(t *testing.T) {
+	cases := []struct {
+		pattern    string
+		fullMatch  bool
+		ignoreCase bool
+		matches    []string
+		dontMatch  []string
+	}{
+		{"ab|cd", false, false, []string{"ab", "xabx", "cdx"}, []string{"AB", "ac"}},
+		{"ab|cd", true, false, []string{"ab", "cd"}, []string{"xab", "abx", "abcd", "AB"}},
+		{"ab|cd", false, true, []string{"AB", "xCdx"}, []string{"ac"}},
+		{"ab|cd", true, true, []string{"aB", "CD"}, []string{"xab", "ABX"}},
+	}
+
+	for _, tc := range cases {
+		m, err := newRegexpMatcher(wrapPattern(tc.pattern, tc.fullMatch, tc.ignoreCase))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.pattern, err)
+			continue
+		}
+		for _, key := range tc.matches {
+			if !m.Match([]byte(key)) {
+				t.Errorf("%q (fullMatch=%v, ignoreCase=%v) should match %q", tc.pattern, tc.fullMatch, tc.ignoreCase, key)
+			}
+		}
+		for _, key := range tc.dontMatch {
+			if m.Match([]byte(key)) {
+				t.Errorf("%q (fullMatch=%v, ignoreCase=%v) should not match %q", tc.pattern, tc.fullMatch, tc.ignoreCase, key)
+			}
+		}
+	}
+}
+
+func TestTimeMatcher(t *testing.T) {
+	after, before := int64(1000), int64(2000)
+	m := &timeMatcher{offset: 1, size: 4, after: &after, before: &before}
+
+	cases := []struct {
+		key  []byte
+		want bool
+	}{
+		{[]byte("k\x00\x00\x03\xe8"), true},
+		{[]byte("k\x00\x00\x07\xcf rest"), true},
+		{[]byte("k\x00\x00\x03\xe7"), false},
+		{[]byte("k\x00\x00\x07\xd0"), false},
+		{[]byte("k\x00\x00\x03"), false},
+		{[]byte(""), false},
+	}
+
+	for _, tc := range cases {
+		if got := m.Match(tc.key); got != tc.want {
+			t.Errorf("Match(%q) = %v, want %v", tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestTTLFilter(t *testing.T) {
+	expired := []byte("\x00\x00\x00\x00\x00\x00\x03\xe8value")
+	fresh := []byte("\x00\x00\x00\x00\x00\x00\x07\xd0")
+	short := []byte("short")
+
+	cases := []struct {
+		f     ttlFilter
+		value []byte
+		want  bool
+	}{
+		{ttlFilter{now: 1000, expired: true}, expired, true},
+		{ttlFilter{now: 1000, expired: true}, fresh, false},
+		{ttlFilter{now: 1000, unexpired: true}, expired, false},
+		{ttlFilter{now: 1000, unexpired: true}, fresh, true},
+		{ttlFilter{now: 1000, expired: true}, short, false},
+		{ttlFilter{now: 1000, unexpired: true}, short, false},
+		{ttlFilter{now: 1000}, short, true},
+	}
+	for _, tc := range cases {
+		if got := tc.f.Match(tc.value); got != tc.want {
+			t.Errorf("%+v: Match(%q) = %v, want %v", tc.f, tc.value, got, tc.want)
+		}
+	}
+
+	if expiry, rest, ok := ttlExpiry(expired); !ok || expiry.UnixMilli() != 1000 || string(rest) != "value" {
+		t.Errorf("ttlExpiry(%q) = (%v, %q, %v), want (1000ms, \"value\", true)", expired, expiry, rest, ok)
+	}
+	if _, _, ok := ttlExpiry(short); ok {
+		t.Errorf("ttlExpiry(%q): expected no expiry", short)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	cases := []struct {
+		input string
+		want  int64
+	}{
+		{"0", 0},
+		{"1700000000", 1700000000},
+		{"2023-11-14T22:13:20Z", 1700000000},
+		{"2023-11-14T23:13:20+01:00", 1700000000},
+	}
+
+	for _, tc := range cases {
+		got, err := parseTime(tc.input)
+		if err != nil {
+			t.Errorf("parseTime(%q): unexpected error: %v", tc.input, err)
+		} else if got != tc.want {
+			t.Errorf("parseTime(%q) = %d, want %d", tc.input, got, tc.want)
+		}
+	}
+
+	for _, s := range []string{"", "yesterday", "2023-13-01"} {
+		if _, err := parseTime(s); err == nil {
+			t.Errorf("parseTime(%q) should fail", s)
+		}
+	}
+}
+
+func TestEstimateCount(t *testing.T) {
+	dbpath := t.TempDir()
+
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	value := bytes.Repeat([]byte("v"), 100)
+	for i := range 500 {
+		if err := db.Put([]byte(fmt.Sprintf("key%05d", i)), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, exact, err := estimateCount(context.Background(), db, dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exact || n != 500 {
+		t.Errorf("estimateCount() = %d (exact=%v), want 500 (exact=true)", n, exact)
+	}
+
+	for i := 500; i < 20000; i++ {
+		if err := db.Put([]byte(fmt.Sprintf("key%05d", i)), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.CompactRange(util.Range{}); err != nil {
+		t.Fatal(err)
+	}
+	n, exact, err = estimateCount(context.Background(), db, dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exact || n < 15000 || n > 25000 {
+		t.Errorf("estimateCount() = %d (exact=%v), want about 20000 (exact=false)", n, exact)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+func flagOptions(flags []cli.Flag) []string {
+	var opts []string
+	for _, flag := range flags {
+		for _, name := range flag.Names() {
+			if len(name) == 1 {
+				opts = append(opts, "-"+name)
+			} else {
+				opts = append(opts, "--"+name)
+			}
+		}
+	}
+	return opts
+}
+
+func valueOptions(flags []cli.Flag) []string {
+	var opts []cli.Flag
+	for _, flag := range flags {
+		if f, ok := flag.(cli.DocGenerationFlag); ok && f.TakesValue() {
+			opts = append(opts, flag)
+		}
+	}
+	return flagOptions(opts)
+}
+
+func writeBashCompletion(w io.Writer, app *cli.App) {
+	var commands []string
+	for _, cmd := range app.VisibleCommands() {
+		commands = append(commands, cmd.Names()...)
+	}
+
+	fmt.Fprintf(w, "# bash completion for %s\n\n", app.Name)
+	fmt.Fprintf(w, "_%s() {\n", app.Name)
+	fmt.Fprintf(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} cmd= opts i\n")
+	fmt.Fprintf(w, "\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "\t\tcase ${COMP_WORDS[i]} in\n")
+	fmt.Fprintf(w, "\t\t%s) ((i++)) ;;\n", strings.Join(valueOptions(app.VisibleFlags()), "|"))
+	fmt.Fprintf(w, "\t\t-*) ;;\n")
+	fmt.Fprintf(w, "\t\t*) cmd=${COMP_WORDS[i]}; break ;;\n")
+	fmt.Fprintf(w, "\t\tesac\n")
+	fmt.Fprintf(w, "\tdone\n")
+	fmt.Fprintf(w, "\tcase $cmd in\n")
+	fmt.Fprintf(w, "\t'') opts=%q ;;\n", strings.Join(append(flagOptions(app.VisibleFlags()), commands...), " "))
+	for _, cmd := range app.VisibleCommands() {
+		fmt.Fprintf(w, "\t%s) opts=%q ;;\n", strings.Join(cmd.Names(), "|"), strings.Join(flagOptions(cmd.VisibleFlags()), " "))
+	}
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tif [[ -n $cmd && $cur != -* ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	fmt.Fprintf(w, "\telse\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W \"$opts\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "\tfi\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "complete -o default -F _%s %s\n", app.Name, app.Name)
+}
+
+func writeZshCompletion(w io.Writer, app *cli.App) {
+	fmt.Fprintf(w, "#compdef %s\n\n", app.Name)
+	fmt.Fprintf(w, "autoload -U +X bashcompinit && bashcompinit\n\n")
+	writeBashCompletion(w, app)
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func writeFishFlags(w io.Writer, name, condition string, flags []cli.Flag) {
+	for _, flag := range flags {
+		fmt.Fprintf(w, "complete -c %s -n %s", name, fishQuote(condition))
+		for _, n := range flag.Names() {
+			if len(n) == 1 {
+				fmt.Fprintf(w, " -s %s", n)
+			} else {
+				fmt.Fprintf(w, " -l %s", n)
+			}
+		}
+		if f, ok := flag.(cli.DocGenerationFlag); ok {
+			if f.TakesValue() {
+				fmt.Fprintf(w, " -r")
+			}
+			if usage := f.GetUsage(); usage != "" {
+				fmt.Fprintf(w, " -d %s", fishQuote(strings.ReplaceAll(usage, "`", "")))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func writeFishCompletion(w io.Writer, app *cli.App) {
+	fmt.Fprintf(w, "# fish completion for %s\n\n", app.Name)
+	writeFishFlags(w, app.Name, "__fish_use_subcommand", app.VisibleFlags())
+	for _, cmd := range app.VisibleCommands() {
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", app.Name, cmd.Name, fishQuote(cmd.Usage))
+	}
+	for _, cmd := range app.VisibleCommands() {
+		condition := "__fish_seen_subcommand_from " + strings.Join(cmd.Names(), " ")
+		writeFishFlags(w, app.Name, condition, cmd.VisibleFlags())
+	}
+}
+
+func completionCmd(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	switch shell := c.Args().First(); shell {
+	case "bash":
+		writeBashCompletion(os.Stdout, c.App)
+	case "zsh":
+		writeZshCompletion(os.Stdout, c.App)
+	case "fish":
+		writeFishCompletion(os.Stdout, c.App)
+	default:
+		return fmt.Errorf("unsupported shell %q (bash, zsh, fish)", shell)
+	}
+	return nil
+}
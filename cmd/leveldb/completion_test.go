@@ -0,0 +1,65 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestCompletion(t *testing.T) {
+	app := &cli.App{
+		Name: "leveldb",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "dbpath", Aliases: []string{"d"}},
+			&cli.BoolFlag{Name: "indexeddb", Aliases: []string{"i"}},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:    "get",
+				Aliases: []string{"g"},
+				Usage:   "print the value for the given key",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "raw", Aliases: []string{"r"}, Usage: "don't escape"},
+				},
+			},
+		},
+	}
+	app.Setup()
+
+	cases := []struct {
+		shell string
+		write func(*bytes.Buffer)
+		want  []string
+	}{
+		{"bash", func(b *bytes.Buffer) { writeBashCompletion(b, app) }, []string{
+			`--dbpath|-d) ((i++)) ;;`,
+			`'') opts="--dbpath -d --indexeddb -i --help -h get g help h" ;;`,
+			`get|g) opts="--raw -r" ;;`,
+			`complete -o default -F _leveldb leveldb`,
+		}},
+		{"zsh", func(b *bytes.Buffer) { writeZshCompletion(b, app) }, []string{
+			`#compdef leveldb`,
+			`complete -o default -F _leveldb leveldb`,
+		}},
+		{"fish", func(b *bytes.Buffer) { writeFishCompletion(b, app) }, []string{
+			`complete -c leveldb -n '__fish_use_subcommand' -l dbpath -s d -r`,
+			`complete -c leveldb -n __fish_use_subcommand -a get -d 'print the value for the given key'`,
+			`complete -c leveldb -n '__fish_seen_subcommand_from get g' -l raw -s r -d 'don\'t escape'`,
+		}},
+	}
+
+	for _, tc := range cases {
+		buf := new(bytes.Buffer)
+		tc.write(buf)
+		for _, want := range tc.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s completion should contain %q, got:\n%s", tc.shell, want, buf.String())
+			}
+		}
+	}
+}
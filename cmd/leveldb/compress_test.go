@@ -0,0 +1,50 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		[]byte(""),
+		[]byte("\x80"),
+		bytes.Repeat([]byte("abc"), 1000),
+	}
+
+	for _, method := range []string{"none", "gzip", "zstd"} {
+		for _, input := range inputs {
+			buf := new(bytes.Buffer)
+			w, err := newCompressWriter(buf, method)
+			if err != nil {
+				t.Fatalf("newCompressWriter(%q): unexpected error: %v", method, err)
+			}
+			if _, err := w.Write(input); err != nil {
+				t.Fatalf("Write(%q): unexpected error: %v", method, err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close(%q): unexpected error: %v", method, err)
+			}
+
+			r, err := newDecompressReader(buf)
+			if err != nil {
+				t.Fatalf("newDecompressReader(%q): unexpected error: %v", method, err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", method, err)
+			} else if !bytes.Equal(got, input) {
+				t.Errorf("%s: round trip of %q = %q", method, input, got)
+			}
+			r.Close()
+		}
+	}
+
+	if _, err := newCompressWriter(io.Discard, "lzma"); err == nil {
+		t.Errorf("newCompressWriter(%q) should fail", "lzma")
+	}
+}
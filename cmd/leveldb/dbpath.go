@@ -0,0 +1,105 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveDBPath expands a leading ~ in p and resolves symbolic links. A
+// path that does not exist yet is returned as is, since some commands
+// create the database.
+func resolveDBPath(p string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(home, p[1:])
+	}
+
+	resolved, err := filepath.EvalSymlinks(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	fi, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", p)
+	}
+	return resolved, nil
+}
+
+// hasCurrentFile reports whether dir contains a CURRENT file, or one of
+// the CURRENT.bak and CURRENT.<n> files goleveldb falls back to.
+func hasCurrentFile(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "CURRENT") && !e.IsDir() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasDBFiles reports whether dir contains any file that goleveldb may
+// have written.
+func hasDBFiles(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if leveldbFilenamePattern.MatchString(e.Name()) && !e.IsDir() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkDBPath returns an error if dir does not look like a LevelDB
+// database. If a subdirectory of dir does, the error suggests it.
+func checkDBPath(dir string) error {
+	ok, err := hasCurrentFile(dir)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	var candidates []string
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			sub := filepath.Join(dir, e.Name())
+			if ok, _ := hasCurrentFile(sub); ok {
+				candidates = append(candidates, sub)
+			}
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return fmt.Errorf("%s does not look like a LevelDB database: no CURRENT file", dir)
+	case 1:
+		return fmt.Errorf("%s does not look like a LevelDB database: no CURRENT file (did you mean -d %s?)", dir, candidates[0])
+	default:
+		return fmt.Errorf("%s does not look like a LevelDB database: no CURRENT file (did you mean one of -d %s?)", dir, strings.Join(candidates, ", "))
+	}
+}
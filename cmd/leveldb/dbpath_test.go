@@ -0,0 +1,92 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+func TestResolveDBPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	home, err := filepath.EvalSymlinks(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbdir := filepath.Join(home, "db")
+	if err := os.Mkdir(dbdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(home, "link")
+	if err := os.Symlink(dbdir, link); err != nil {
+		t.Skipf("cannot create a symlink: %v", err)
+	}
+	file := filepath.Join(home, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"~/db", dbdir},
+		{link, dbdir},
+		{"~/missing", filepath.Join(home, "missing")},
+	} {
+		got, err := resolveDBPath(tc.in)
+		if err != nil {
+			t.Errorf("resolveDBPath(%q): unexpected error: %v", tc.in, err)
+		} else if got != tc.want {
+			t.Errorf("resolveDBPath(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	if _, err := resolveDBPath(file); err == nil {
+		t.Errorf("resolveDBPath(%q): expected an error for a regular file", file)
+	}
+	if err := checkDBPath(dbdir); err == nil {
+		t.Errorf("checkDBPath(%q): expected an error for a directory without CURRENT", dbdir)
+	}
+}
+
+func TestCheckDBPathSuggestion(t *testing.T) {
+	parent := t.TempDir()
+	dbdir := filepath.Join(parent, "leveldb")
+	if err := os.Mkdir(dbdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dbdir, "CURRENT"), []byte("MANIFEST-000001\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkDBPath(dbdir); err != nil {
+		t.Errorf("checkDBPath(%q): unexpected error: %v", dbdir, err)
+	}
+	err := checkDBPath(parent)
+	if err == nil {
+		t.Fatalf("checkDBPath(%q): expected an error", parent)
+	}
+	if want := "did you mean -d " + dbdir + "?"; !strings.Contains(err.Error(), want) {
+		t.Errorf("checkDBPath(%q) = %q, want it to contain %q", parent, err, want)
+	}
+}
+
+func TestOpenDBDoesNotCreate(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := openDB(dir, &opt.Options{ErrorIfMissing: true}); err == nil {
+		t.Error("openDB: expected an error for an empty directory")
+	}
+	if ok, err := hasDBFiles(dir); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("openDB created a database despite ErrorIfMissing")
+	}
+}
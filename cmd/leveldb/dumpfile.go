@@ -0,0 +1,388 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+type dumpEncoder interface {
+	Encode(key, value []byte) error
+	Close() error
+}
+
+type dumpDecoder interface {
+	// Decode returns io.EOF when there are no more entries.
+	Decode() (key, value []byte, err error)
+}
+
+// comparerNamer is implemented by dump decoders whose input may record the
+// comparer of the dumped database. ComparerName returns "" if it does not.
+type comparerNamer interface {
+	ComparerName() string
+}
+
+// msgpackHeaderExtID is the MessagePack extension type of the optional
+// header that precedes the entries and holds the comparer name.
+const msgpackHeaderExtID = 1
+
+type dumpColumns int
+
+const (
+	keyValueColumns dumpColumns = iota
+	keyColumn
+	valueColumn
+)
+
+// dumpHeader describes a dump.
+type dumpHeader struct {
+	// Comparer is the comparer of the dumped database. It is recorded in
+	// the dump unless it is empty.
+	Comparer string
+	// Columns selects which of keys and values are dumped. In MessagePack
+	// dumps the omitted side is encoded as nil.
+	Columns dumpColumns
+	// NoHeader omits the comparer record and the header row of CSV dumps.
+	NoHeader bool
+}
+
+// newDumpEncoder returns an encoder for format.
+func newDumpEncoder(format string, w io.Writer, nentries int, hdr dumpHeader) (dumpEncoder, error) {
+	switch format {
+	case "msgpack":
+		return newMessagePackEncoder(w, nentries, hdr)
+	case "csv":
+		return newCSVEncoder(w, hdr)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// detectDumpFormat guesses the format of a dump file from its first bytes.
+func detectDumpFormat(br *bufio.Reader) (string, error) {
+	magic, err := br.Peek(262)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	switch {
+	case len(magic) == 262 && bytes.Equal(magic[257:262], []byte("ustar")):
+		return "archive", nil
+	case len(magic) == 0:
+		return "msgpack", nil
+	case magic[0]&0xf0 == 0x80 || magic[0] == 0xde || magic[0] == 0xdf || msgpcode.IsExt(magic[0]):
+		return "msgpack", nil
+	case bytes.HasPrefix(magic, []byte("key,")) || bytes.HasPrefix(magic, []byte("comparer,")),
+		bytes.HasPrefix(magic, []byte("key\n")) || bytes.HasPrefix(magic, []byte("value\n")):
+		return "csv", nil
+	default:
+		return "", errors.New("cannot detect the dump file format; specify --format")
+	}
+}
+
+// newDumpDecoder returns a decoder for format, or for the format detected
+// from the input if format is "auto".
+func newDumpDecoder(format string, r io.Reader) (dumpDecoder, error) {
+	if format == "auto" {
+		br := bufio.NewReader(r)
+		detected, err := detectDumpFormat(br)
+		if err != nil {
+			return nil, err
+		}
+		format, r = detected, br
+	}
+
+	switch format {
+	case "msgpack":
+		return newMessagePackDecoder(r), nil
+	case "csv":
+		return newCSVDecoder(r), nil
+	case "archive":
+		return newArchiveDecoder(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type messagePackEncoder struct {
+	enc     *msgpack.Encoder
+	columns dumpColumns
+}
+
+func newMessagePackEncoder(w io.Writer, nentries int, hdr dumpHeader) (*messagePackEncoder, error) {
+	enc := msgpack.NewEncoder(w)
+	enc.UseCompactInts(true)
+	if hdr.Comparer != "" {
+		if err := enc.EncodeExtHeader(msgpackHeaderExtID, len(hdr.Comparer)); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(enc.Writer(), hdr.Comparer); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.EncodeMapLen(nentries); err != nil {
+		return nil, err
+	}
+	return &messagePackEncoder{enc, hdr.Columns}, nil
+}
+
+func (e *messagePackEncoder) Encode(key, value []byte) error {
+	switch e.columns {
+	case keyColumn:
+		value = nil
+	case valueColumn:
+		key = nil
+	}
+	if err := e.enc.EncodeBytes(key); err != nil {
+		return err
+	}
+	if err := e.enc.EncodeBytes(value); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e *messagePackEncoder) Close() error {
+	return nil
+}
+
+type messagePackDecoder struct {
+	dec      *msgpack.Decoder
+	comparer string
+	nentries int
+	read     int
+	started  bool
+	err      error
+}
+
+func newMessagePackDecoder(r io.Reader) *messagePackDecoder {
+	return &messagePackDecoder{dec: msgpack.NewDecoder(r)}
+}
+
+func (d *messagePackDecoder) truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("dump truncated: expected %d entries, got %d", d.nentries, d.read)
+	}
+	return err
+}
+
+// start reads the optional header and the number of entries.
+func (d *messagePackDecoder) start() error {
+	if d.started {
+		return d.err
+	}
+	d.started = true
+
+	code, err := d.dec.PeekCode()
+	if err != nil {
+		d.err = err
+		return err
+	}
+	if msgpcode.IsExt(code) {
+		extID, extLen, err := d.dec.DecodeExtHeader()
+		if err != nil {
+			d.err = err
+			return err
+		}
+		if extID != msgpackHeaderExtID {
+			d.err = fmt.Errorf("msgpack: unknown header type %d", extID)
+			return d.err
+		}
+		name := make([]byte, extLen)
+		if err := d.dec.ReadFull(name); err != nil {
+			d.err = err
+			return err
+		}
+		d.comparer = string(name)
+	}
+
+	d.nentries, d.err = d.dec.DecodeMapLen()
+	return d.err
+}
+
+// ComparerName returns the comparer recorded in the header, if any.
+func (d *messagePackDecoder) ComparerName() string {
+	d.start()
+	return d.comparer
+}
+
+func (d *messagePackDecoder) Decode() ([]byte, []byte, error) {
+	if err := d.start(); err != nil {
+		return nil, nil, err
+	}
+	if d.read >= d.nentries {
+		return nil, nil, io.EOF
+	}
+
+	key, err := d.dec.DecodeBytes()
+	if err != nil {
+		return nil, nil, d.truncated(err)
+	}
+	value, err := d.dec.DecodeBytes()
+	if err != nil {
+		return nil, nil, d.truncated(err)
+	}
+	d.read++
+	return key, value, nil
+}
+
+type csvEncoder struct {
+	w       *csv.Writer
+	out     io.Writer
+	columns dumpColumns
+}
+
+func newCSVEncoder(w io.Writer, hdr dumpHeader) (*csvEncoder, error) {
+	cw := csv.NewWriter(w)
+	if hdr.NoHeader {
+		return &csvEncoder{cw, w, hdr.Columns}, nil
+	}
+	if hdr.Comparer != "" {
+		if err := cw.Write([]string{"comparer", hdr.Comparer}); err != nil {
+			return nil, err
+		}
+	}
+	header := []string{"key", "value"}
+	switch hdr.Columns {
+	case keyColumn:
+		header = header[:1]
+	case valueColumn:
+		header = header[1:]
+	}
+	if err := cw.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvEncoder{cw, w, hdr.Columns}, nil
+}
+
+func (e *csvEncoder) Encode(key, value []byte) error {
+	record := []string{
+		base64.StdEncoding.EncodeToString(key),
+		base64.StdEncoding.EncodeToString(value),
+	}
+	switch e.columns {
+	case keyColumn:
+		record = record[:1]
+	case valueColumn:
+		record = record[1:]
+	}
+	if len(record) == 1 && record[0] == "" {
+		// csv.Writer writes a blank line, which csv.Reader skips.
+		e.w.Flush()
+		if err := e.w.Error(); err != nil {
+			return err
+		}
+		_, err := io.WriteString(e.out, "\"\"\n")
+		return err
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type csvDecoder struct {
+	r        *csv.Reader
+	comparer string
+	columns  dumpColumns
+	started  bool
+	err      error
+}
+
+func newCSVDecoder(r io.Reader) *csvDecoder {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return &csvDecoder{r: cr}
+}
+
+// newHeaderlessCSVDecoder returns a decoder for CSV dumps written with
+// --no-header, whose records are all keys and values.
+func newHeaderlessCSVDecoder(r io.Reader) *csvDecoder {
+	d := newCSVDecoder(r)
+	d.started = true
+	return d
+}
+
+// start reads the optional comparer record and the header.
+func (d *csvDecoder) start() error {
+	if d.started {
+		return d.err
+	}
+	d.started = true
+
+	header, err := d.r.Read()
+	if err == nil && len(header) == 2 && header[0] == "comparer" {
+		d.comparer = header[1]
+		header, err = d.r.Read()
+	}
+	if err == io.EOF {
+		d.err = errors.New("csv: missing header")
+		return d.err
+	} else if err != nil {
+		d.err = err
+		return d.err
+	}
+	switch strings.Join(header, ",") {
+	case "key,value":
+		d.columns = keyValueColumns
+	case "key":
+		d.columns = keyColumn
+	case "value":
+		d.columns = valueColumn
+	default:
+		d.err = errors.New("csv: invalid header")
+	}
+	return d.err
+}
+
+// ComparerName returns the comparer recorded before the header, if any.
+func (d *csvDecoder) ComparerName() string {
+	d.start()
+	return d.comparer
+}
+
+func (d *csvDecoder) Decode() ([]byte, []byte, error) {
+	if err := d.start(); err != nil {
+		return nil, nil, err
+	}
+
+	record, err := d.r.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	line, _ := d.r.FieldPos(0)
+	nfields := 2
+	if d.columns != keyValueColumns {
+		nfields = 1
+	}
+	if len(record) != nfields {
+		return nil, nil, fmt.Errorf("csv: line %d: wrong number of fields", line)
+	}
+
+	var key, value []byte
+	if d.columns != valueColumn {
+		if key, err = base64.StdEncoding.DecodeString(record[0]); err != nil {
+			return nil, nil, fmt.Errorf("csv: line %d: key: %w", line, err)
+		}
+	}
+	if d.columns != keyColumn {
+		if value, err = base64.StdEncoding.DecodeString(record[nfields-1]); err != nil {
+			return nil, nil, fmt.Errorf("csv: line %d: value: %w", line, err)
+		}
+	}
+	return key, value, nil
+}
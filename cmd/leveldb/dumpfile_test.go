@@ -0,0 +1,241 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDumpFileRoundTrip(t *testing.T) {
+	entries := []entry{
+		{Key: []byte(""), Value: []byte("")},
+		{Key: []byte("a,b"), Value: []byte("\"quoted\"\r\n")},
+		{Key: []byte("\x00\xff"), Value: bytes.Repeat([]byte("\x80"), 100)},
+	}
+
+	for _, format := range []string{"msgpack", "csv"} {
+		buf := new(bytes.Buffer)
+		enc, err := newDumpEncoder(format, buf, len(entries), dumpHeader{})
+		if err != nil {
+			t.Fatalf("newDumpEncoder(%q): unexpected error: %v", format, err)
+		}
+		for _, e := range entries {
+			if err := enc.Encode(e.Key, e.Value); err != nil {
+				t.Fatalf("%s: Encode: unexpected error: %v", format, err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("%s: Close: unexpected error: %v", format, err)
+		}
+
+		dec, err := newDumpDecoder(format, buf)
+		if err != nil {
+			t.Fatalf("newDumpDecoder(%q): unexpected error: %v", format, err)
+		}
+		for i, e := range entries {
+			key, value, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("%s: entry %d: unexpected error: %v", format, i, err)
+			}
+			if !bytes.Equal(key, e.Key) || !bytes.Equal(value, e.Value) {
+				t.Errorf("%s: entry %d = (%q, %q), want (%q, %q)", format, i, key, value, e.Key, e.Value)
+			}
+		}
+		if _, _, err := dec.Decode(); err != io.EOF {
+			t.Errorf("%s: expected io.EOF, got %v", format, err)
+		}
+	}
+}
+
+func TestDumpFileComparer(t *testing.T) {
+	for _, format := range []string{"msgpack", "csv"} {
+		for _, name := range []string{"", "idb_cmp1"} {
+			buf := new(bytes.Buffer)
+			enc, err := newDumpEncoder(format, buf, 1, dumpHeader{Comparer: name})
+			if err != nil {
+				t.Fatalf("newDumpEncoder(%q): unexpected error: %v", format, err)
+			}
+			if err := enc.Encode([]byte("k"), []byte("v")); err != nil {
+				t.Fatalf("%s: Encode: unexpected error: %v", format, err)
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatalf("%s: Close: unexpected error: %v", format, err)
+			}
+
+			dec, err := newDumpDecoder("auto", buf)
+			if err != nil {
+				t.Fatalf("%s (comparer %q): newDumpDecoder: unexpected error: %v", format, name, err)
+			}
+			if got := dec.(comparerNamer).ComparerName(); got != name {
+				t.Errorf("%s: ComparerName() = %q, want %q", format, got, name)
+			}
+			if key, value, err := dec.Decode(); err != nil {
+				t.Errorf("%s (comparer %q): unexpected error: %v", format, name, err)
+			} else if string(key) != "k" || string(value) != "v" {
+				t.Errorf("%s (comparer %q): entry = (%q, %q), want (\"k\", \"v\")", format, name, key, value)
+			}
+		}
+	}
+}
+
+func TestDumpFileColumns(t *testing.T) {
+	entries := []entry{
+		{Key: []byte(""), Value: []byte("")},
+		{Key: []byte("a"), Value: []byte("b")},
+	}
+
+	for _, format := range []string{"msgpack", "csv"} {
+		for _, columns := range []dumpColumns{keyColumn, valueColumn} {
+			buf := new(bytes.Buffer)
+			enc, err := newDumpEncoder(format, buf, len(entries), dumpHeader{Columns: columns})
+			if err != nil {
+				t.Fatalf("newDumpEncoder(%q): unexpected error: %v", format, err)
+			}
+			for _, e := range entries {
+				if err := enc.Encode(e.Key, e.Value); err != nil {
+					t.Fatalf("%s: Encode: unexpected error: %v", format, err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatalf("%s: Close: unexpected error: %v", format, err)
+			}
+
+			dec, err := newDumpDecoder("auto", buf)
+			if err != nil {
+				t.Fatalf("%s (columns %d): newDumpDecoder: unexpected error: %v", format, columns, err)
+			}
+			for i, e := range entries {
+				key, value, err := dec.Decode()
+				if err != nil {
+					t.Fatalf("%s (columns %d): entry %d: unexpected error: %v", format, columns, i, err)
+				}
+				wantKey, wantValue := e.Key, []byte(nil)
+				if columns == valueColumn {
+					wantKey, wantValue = nil, e.Value
+				}
+				if (key == nil) != (wantKey == nil) || (value == nil) != (wantValue == nil) ||
+					!bytes.Equal(key, wantKey) || !bytes.Equal(value, wantValue) {
+					t.Errorf("%s (columns %d): entry %d = (%q, %q), want (%q, %q)", format, columns, i, key, value, wantKey, wantValue)
+				}
+			}
+			if _, _, err := dec.Decode(); err != io.EOF {
+				t.Errorf("%s (columns %d): expected io.EOF, got %v", format, columns, err)
+			}
+		}
+	}
+}
+
+func TestCSVNoHeader(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc, err := newDumpEncoder("csv", buf, 1, dumpHeader{Comparer: "idb_cmp1", NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode([]byte("a"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "YQ==,Yg==\n"; got != want {
+		t.Errorf("headerless CSV = %q, want %q", got, want)
+	}
+
+	dec := newHeaderlessCSVDecoder(buf)
+	if key, value, err := dec.Decode(); err != nil {
+		t.Errorf("Decode: unexpected error: %v", err)
+	} else if string(key) != "a" || string(value) != "b" {
+		t.Errorf("Decode = (%q, %q), want (\"a\", \"b\")", key, value)
+	}
+	if _, _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestCSVDecoderErrors(t *testing.T) {
+	inputs := []string{
+		"",
+		"k,v\n",
+		"key,value\nYQ==\n",
+		"key,value\n!!,YQ==\n",
+		"comparer,idb_cmp1\n",
+		"comparer,idb_cmp1\nk,v\n",
+		"key\nYQ==,YQ==\n",
+	}
+
+	for _, input := range inputs {
+		dec := newCSVDecoder(strings.NewReader(input))
+		if _, _, err := dec.Decode(); err == nil || err == io.EOF {
+			t.Errorf("Decode(%q): expected error, got %v", input, err)
+		}
+	}
+}
+
+func TestDetectDumpFormat(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		nentries int
+	}{
+		{"msgpack", 0},
+		{"msgpack", 3},
+		{"msgpack", 100},
+		{"msgpack", 100000},
+		{"csv", 3},
+	} {
+		buf := new(bytes.Buffer)
+		enc, err := newDumpEncoder(tc.format, buf, tc.nentries, dumpHeader{})
+		if err != nil {
+			t.Fatalf("newDumpEncoder(%q): unexpected error: %v", tc.format, err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("%s: Close: unexpected error: %v", tc.format, err)
+		}
+		got, err := detectDumpFormat(bufio.NewReader(buf))
+		if err != nil {
+			t.Errorf("%s (%d entries): unexpected error: %v", tc.format, tc.nentries, err)
+		} else if got != tc.format {
+			t.Errorf("%s (%d entries): detected %q", tc.format, tc.nentries, got)
+		}
+	}
+
+	for _, input := range []string{"\xc4\x01a", "value,key\n", "{}"} {
+		if _, err := detectDumpFormat(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("detectDumpFormat(%q) should fail", input)
+		}
+	}
+}
+
+func TestMessagePackDecoderTruncated(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc, err := newDumpEncoder("msgpack", buf, 3, dumpHeader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := enc.Encode([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full := buf.Bytes()
+
+	for _, n := range []int{len(full) - 1, len(full) - 7, len(full) - 8, 2} {
+		dec, err := newDumpDecoder("msgpack", bytes.NewReader(full[:n]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			_, _, err = dec.Decode()
+			if err != nil {
+				break
+			}
+		}
+		if err == io.EOF || !strings.HasPrefix(err.Error(), "dump truncated: expected 3 entries, got ") {
+			t.Errorf("truncated at %d/%d: got %v, want a truncation error", n, len(full), err)
+		}
+	}
+}
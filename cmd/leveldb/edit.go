@@ -0,0 +1,160 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/urfave/cli/v2"
+)
+
+// editorCommand returns the command line of $VISUAL or $EDITOR, falling
+// back to a platform default.
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if args := strings.Fields(os.Getenv(name)); len(args) > 0 {
+			return args
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// editBytes writes b to a temporary file, runs the editor on it and
+// returns the saved contents.
+func editBytes(b []byte, suffix string) ([]byte, error) {
+	fh, err := os.CreateTemp("", "leveldb-edit-*"+suffix)
+	if err != nil {
+		return nil, err
+	}
+	name := fh.Name()
+	defer os.Remove(name)
+	if _, err := fh.Write(b); err != nil {
+		fh.Close()
+		return nil, err
+	}
+	if err := fh.Close(); err != nil {
+		return nil, err
+	}
+
+	args := append(editorCommand(), name)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor: %w", err)
+	}
+	return os.ReadFile(name)
+}
+
+func editCmd(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowSubcommandHelpAndExit(c, 2)
+	}
+
+	key, err := getArg(c, 0)
+	if err != nil {
+		return err
+	}
+	dbpath := c.String("dbpath")
+
+	// The database is closed while the editor runs so that other
+	// processes can use it in the meantime.
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = true
+	db, err := openDB(dbpath, o)
+	if err != nil {
+		return err
+	}
+	orig, err := db.Get(key, nil)
+	exists := err == nil
+	if errors.Is(err, leveldb.ErrNotFound) && c.Bool("create") {
+		err = nil
+	} else if errors.Is(err, leveldb.ErrNotFound) {
+		err = errors.New("key not found (use --create to add it)")
+	}
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	text, suffix := orig, ""
+	if c.Bool("json") {
+		suffix = ".json"
+		if exists {
+			buf := new(bytes.Buffer)
+			if err := json.Indent(buf, orig, "", "  "); err != nil {
+				return fmt.Errorf("the value is not valid JSON: %w", err)
+			}
+			buf.WriteByte('\n')
+			text = buf.Bytes()
+		}
+	}
+
+	edited, err := editBytes(text, suffix)
+	if err != nil {
+		return err
+	}
+	unchanged := exists && bytes.Equal(edited, orig)
+	if c.Bool("json") {
+		buf := new(bytes.Buffer)
+		if err := json.Compact(buf, edited); err != nil {
+			return fmt.Errorf("the edited value is not valid JSON: %w", err)
+		}
+		edited = buf.Bytes()
+		// Compare compacted forms, so that reformatting by the editor
+		// alone does not rewrite the value.
+		if exists {
+			buf := new(bytes.Buffer)
+			if err := json.Compact(buf, orig); err != nil {
+				return err
+			}
+			unchanged = bytes.Equal(edited, buf.Bytes())
+		}
+	}
+	if unchanged {
+		fmt.Fprintln(os.Stderr, "leveldb: the value is unchanged")
+		return nil
+	}
+
+	o = getOptions(c)
+	o.ErrorIfMissing = true
+	db, err = openDB(dbpath, o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	current, err := db.Get(key, nil)
+	switch {
+	case err == nil && (!exists || !bytes.Equal(current, orig)),
+		errors.Is(err, leveldb.ErrNotFound) && exists:
+		return errors.New("the value was modified while editing; not written")
+	case err != nil && !errors.Is(err, leveldb.ErrNotFound):
+		return err
+	}
+
+	if err := db.Put(key, edited, getWriteOptions(c)); err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"slices"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "code --wait")
+	if got, want := editorCommand(), []string{"code", "--wait"}; !slices.Equal(got, want) {
+		t.Errorf("editorCommand() = %q, want %q", got, want)
+	}
+	t.Setenv("VISUAL", "nano")
+	if got, want := editorCommand(), []string{"nano"}; !slices.Equal(got, want) {
+		t.Errorf("editorCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestEditBytes(t *testing.T) {
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true is not available")
+	}
+	t.Setenv("VISUAL", "true")
+
+	in := []byte("\x00value\n")
+	out, err := editBytes(in, ".txt")
+	if err != nil {
+		t.Fatalf("editBytes: unexpected error: %v", err)
+	}
+	if !bytes.Equal(out, in) {
+		t.Errorf("editBytes = %q, want %q", out, in)
+	}
+}
+
+func TestEditJSONUnchanged(t *testing.T) {
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true is not available")
+	}
+	t.Setenv("VISUAL", "true")
+
+	dbpath := t.TempDir()
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := []byte(`{ "a": [1, 2] }`)
+	if err := db.Put([]byte("k"), value, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runApp(t, "-d", dbpath, "edit", "--json", "k"); err != nil {
+		t.Fatalf("edit --json: unexpected error: %v", err)
+	}
+	out, err := runApp(t, "-d", dbpath, "get", "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != string(value) {
+		t.Errorf("value after an unchanged edit = %q, want %q", out, value)
+	}
+}
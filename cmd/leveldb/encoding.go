@@ -6,14 +6,20 @@ package main
 import (
 	"bytes"
 	"encoding/base64"
-	"encoding/json"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
 func init() {
@@ -23,232 +29,226 @@ func init() {
 }
 
 type base64Writer struct {
-	w io.Writer
+	w   io.Writer
+	enc *base64.Encoding
 }
 
 func newBase64Writer(w io.Writer) *base64Writer {
-	return &base64Writer{w}
+	return &base64Writer{w, base64.StdEncoding}
+}
+
+func (w *base64Writer) SetURLEncoding(b bool) *base64Writer {
+	if b {
+		w.enc = base64.URLEncoding
+	} else {
+		w.enc = base64.StdEncoding
+	}
+	return w
 }
 
 func (w *base64Writer) Write(b []byte) (int, error) {
-	enc := base64.NewEncoder(base64.StdEncoding, w.w)
+	enc := base64.NewEncoder(w.enc, w.w)
 	if _, err := enc.Write(b); err != nil {
 		return 0, err
 	}
 	if err := enc.Close(); err != nil {
 		return 0, err
 	}
-	return base64.StdEncoding.EncodedLen(len(b)), nil
+	return w.enc.EncodedLen(len(b)), nil
 }
 
-type prettyPrinter struct {
-	w         io.Writer
-	quoting   bool
-	truncate  bool
-	parseJSON bool
+type hexWriter struct {
+	w io.Writer
 }
 
-func newPrettyPrinter(w io.Writer) *prettyPrinter {
-	return &prettyPrinter{w: w}
+func newHexWriter(w io.Writer) *hexWriter {
+	return &hexWriter{w}
 }
 
-func (w *prettyPrinter) SetQuoting(b bool) *prettyPrinter {
-	w.quoting = b
-	return w
+func (w *hexWriter) Write(b []byte) (int, error) {
+	return io.WriteString(w.w, hex.EncodeToString(b))
 }
 
-func (w *prettyPrinter) SetTruncate(b bool) *prettyPrinter {
-	w.truncate = b
-	return w
+type hexDumpWriter struct {
+	w        io.Writer
+	truncate int
 }
 
-func (w *prettyPrinter) SetParseJSON(b bool) *prettyPrinter {
-	w.parseJSON = b
+func newHexDumpWriter(w io.Writer) *hexDumpWriter {
+	return &hexDumpWriter{w, 0}
+}
+
+func (w *hexDumpWriter) SetTruncate(n int) *hexDumpWriter {
+	w.truncate = n
 	return w
 }
 
-func (w *prettyPrinter) Write(b []byte) (int, error) {
-	dimmed := color.New(color.Faint).FprintfFunc()
+// Write writes b as a canonical hex dump on the lines following the
+// current one, without a trailing newline.
+func (w *hexDumpWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	if w.truncate > 0 && len(b) > w.truncate {
+		b = b[:w.truncate]
+	}
+	s := "\n" + strings.TrimSuffix(hex.Dump(b), "\n")
+	if len(b) < n {
+		s += fmt.Sprintf("\n... (%d bytes)", n)
+	}
+	if _, err := io.WriteString(w.w, s); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
 
-	if w.parseJSON {
-		for {
-			var s *string
-			if err := json.Unmarshal(b, &s); err != nil || s == nil {
-				break
-			}
-			b = []byte(*s)
-		}
+type hashWriter struct {
+	w       io.Writer
+	newHash func() hash.Hash
+}
 
-		var obj interface{}
-		if err := json.Unmarshal(b, &obj); err == nil {
-			buf := new(bytes.Buffer)
-			enc := json.NewEncoder(buf)
-			enc.SetEscapeHTML(false)
-			enc.SetIndent("", "  ")
-			if err := enc.Encode(obj); err != nil {
-				return 0, err
-			}
-			buf.Truncate(buf.Len() - 1)
-			n, err := buf.WriteTo(w.w)
-			return int(n), err
-		}
-	}
+func newHashWriter(w io.Writer, newHash func() hash.Hash) *hashWriter {
+	return &hashWriter{w, newHash}
+}
+
+func (w *hashWriter) Write(b []byte) (int, error) {
+	h := w.newHash()
+	h.Write(b)
+	return io.WriteString(w.w, hex.EncodeToString(h.Sum(nil)))
+}
+
+// intKeyWriter writes keys of a fixed width as decimal integers and hands
+// any other key to a fallback writer.
+type intKeyWriter struct {
+	w        io.Writer
+	fallback io.Writer
+	size     int
+	order    binary.ByteOrder
+}
 
-	buf := new(bytes.Buffer)
-	if !w.truncate {
-		buf.Grow(len(b))
+func newIntKeyWriter(w, fallback io.Writer, size int, order binary.ByteOrder) *intKeyWriter {
+	return &intKeyWriter{w, fallback, size, order}
+}
+
+func (w *intKeyWriter) Write(b []byte) (int, error) {
+	switch {
+	case len(b) == 4 && w.size == 4:
+		return io.WriteString(w.w, strconv.FormatUint(uint64(w.order.Uint32(b)), 10))
+	case len(b) == 8 && w.size == 8:
+		return io.WriteString(w.w, strconv.FormatUint(w.order.Uint64(b), 10))
+	default:
+		return w.fallback.Write(b)
 	}
-	if w.quoting {
-		buf.WriteByte('"')
+}
+
+// parseIntKeyFormat parses a --key-int value: be32, be64, le32 or le64.
+func parseIntKeyFormat(s string) (int, binary.ByteOrder, error) {
+	switch s {
+	case "be32":
+		return 4, binary.BigEndian, nil
+	case "be64":
+		return 8, binary.BigEndian, nil
+	case "le32":
+		return 4, binary.LittleEndian, nil
+	case "le64":
+		return 8, binary.LittleEndian, nil
+	default:
+		return 0, nil, fmt.Errorf("unknown integer format %q", s)
 	}
-	nwritten := 0
-	for len(b) > 0 {
-		r, size := utf8.DecodeRune(b)
-		switch {
-		case r == utf8.RuneError:
-			dimmed(buf, "\\x%02x", b[0])
-			nwritten += 4
-		case r == 0:
-			dimmed(buf, "\\0")
-			nwritten += 2
-		case r == '"' && w.quoting:
-			dimmed(buf, "\\\"")
-			nwritten += 2
-		case r == '\\':
-			dimmed(buf, "\\\\")
-			nwritten += 2
-		case r == '\a':
-			dimmed(buf, "\\a")
-			nwritten += 2
-		case r == '\b':
-			dimmed(buf, "\\b")
-			nwritten += 2
-		case r == '\f':
-			dimmed(buf, "\\f")
-			nwritten += 2
-		case r == '\n':
-			dimmed(buf, "\\n")
-			nwritten += 2
-		case r == '\r':
-			dimmed(buf, "\\r")
-			nwritten += 2
-		case r == '\t':
-			dimmed(buf, "\\t")
-			nwritten += 2
-		case r == '\v':
-			dimmed(buf, "\\v")
-			nwritten += 2
-		case unicode.IsPrint(r):
-			buf.WriteRune(r)
-			nwritten += 1
-		case r <= 0x7f:
-			dimmed(buf, "\\x%02x", r)
-			nwritten += 4
-		case r <= 0xffff:
-			dimmed(buf, "\\u%04x", r)
-			nwritten += 6
-		default:
-			dimmed(buf, "\\U%08x", r)
-			nwritten += 8
-		}
-		b = b[size:]
-		if w.truncate && nwritten >= 250 {
-			dimmed(buf, "...")
-			break
-		}
+}
+
+// byteSwapWriter reverses the byte order of each group of size bytes
+// before passing them on. A trailing partial group is left as is.
+type byteSwapWriter struct {
+	w    io.Writer
+	size int
+}
+
+func newByteSwapWriter(w io.Writer, size int) *byteSwapWriter {
+	return &byteSwapWriter{w, size}
+}
+
+func (w *byteSwapWriter) Write(b []byte) (int, error) {
+	swapped := bytes.Clone(b)
+	for i := 0; i+w.size <= len(swapped); i += w.size {
+		slices.Reverse(swapped[i : i+w.size])
 	}
-	if w.quoting {
-		buf.WriteByte('"')
+	if _, err := w.w.Write(swapped); err != nil {
+		return 0, err
 	}
-	n, err := buf.WriteTo(w.w)
-	return int(n), err
+	return len(b), nil
 }
 
-func decodeBase64(b []byte) ([]byte, error) {
-	b = bytes.TrimRight(b, "=")
-	n, err := base64.RawStdEncoding.Decode(b, b)
-	if err != nil {
-		return nil, err
+type decodingWriter struct {
+	w      io.Writer
+	decode func([]byte) ([]byte, error)
+}
+
+func newDecodingWriter(w io.Writer, decode func([]byte) ([]byte, error)) *decodingWriter {
+	return &decodingWriter{w, decode}
+}
+
+func (w *decodingWriter) Write(b []byte) (int, error) {
+	if decoded, err := w.decode(b); err == nil {
+		b = decoded
 	}
-	return b[:n], nil
+	return w.w.Write(b)
 }
 
-func parseHex(b []byte, n int) (uint32, bool) {
-	if len(b) < n {
-		return 0, false
-	}
-	x := uint32(0)
-	for i := 0; i < n; i++ {
-		x <<= 4
-		switch {
-		case '0' <= b[i] && b[i] <= '9':
-			x |= uint32(b[i] - '0')
-		case 'A' <= b[i] && b[i] <= 'F':
-			x |= uint32(b[i] - 'A' + 10)
-		case 'a' <= b[i] && b[i] <= 'f':
-			x |= uint32(b[i] - 'a' + 10)
-		default:
-			return 0, false
-		}
+type outputFormat int
+
+const (
+	escapedFormat outputFormat = iota
+	rawFormat
+	base64Format
+	base64URLFormat
+	hexFormat
+)
+
+func parseOutputFormat(s string) (outputFormat, error) {
+	switch s {
+	case "escaped":
+		return escapedFormat, nil
+	case "raw":
+		return rawFormat, nil
+	case "base64":
+		return base64Format, nil
+	case "base64url":
+		return base64URLFormat, nil
+	case "hex":
+		return hexFormat, nil
+	default:
+		return 0, fmt.Errorf("unknown format %q", s)
 	}
-	return x, true
 }
 
-func unescape(b []byte) ([]byte, error) {
-	dst := b[:0]
-	i := 0
-	for i < len(b) {
-		if b[i] != '\\' {
-			dst = append(dst, b[i])
-			i += 1
-			continue
-		}
-		if i+1 == len(b) {
-			return nil, fmt.Errorf("truncated backslash escape at position %d", i)
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// isBinary reports whether b contains bytes that are unsafe to write to a terminal.
+func isBinary(b []byte) bool {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			return true
 		}
-		advance := 2
-		switch b[i+1] {
-		case '0':
-			dst = append(dst, '\x00')
-		case 'a':
-			dst = append(dst, '\a')
-		case 'b':
-			dst = append(dst, '\b')
-		case 'f':
-			dst = append(dst, '\f')
-		case 'n':
-			dst = append(dst, '\n')
-		case 'r':
-			dst = append(dst, '\r')
-		case 't':
-			dst = append(dst, '\t')
-		case 'v':
-			dst = append(dst, '\v')
-		case 'x':
-			cp, ok := parseHex(b[i+2:], 2)
-			if !ok {
-				return nil, fmt.Errorf("truncated \\x escape at position %d", i)
-			}
-			dst = append(dst, byte(cp))
-			advance = 4
-		case 'u':
-			cp, ok := parseHex(b[i+2:], 4)
-			if !ok {
-				return nil, fmt.Errorf("truncated \\u escape at position %d", i)
-			}
-			dst = utf8.AppendRune(dst, rune(cp))
-			advance = 6
-		case 'U':
-			cp, ok := parseHex(b[i+2:], 8)
-			if !ok {
-				return nil, fmt.Errorf("truncated \\U escape at position %d", i)
-			}
-			dst = utf8.AppendRune(dst, rune(cp))
-			advance = 10
-		default:
-			dst = append(dst, b[i+1])
+		if !unicode.IsPrint(r) && r != '\n' && r != '\t' && r != '\r' {
+			return true
 		}
-		i += advance
+		b = b[size:]
+	}
+	return false
+}
+
+func decodeBase64(b []byte) ([]byte, error) {
+	b = bytes.TrimRight(bytes.TrimRight(b, "\r\n"), "=")
+	enc := base64.RawStdEncoding
+	if bytes.ContainsAny(b, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	dst := make([]byte, enc.DecodedLen(len(b)))
+	n, err := enc.Decode(dst, b)
+	if err != nil {
+		return nil, err
 	}
-	return dst, nil
+	return dst[:n], nil
 }
@@ -5,9 +5,8 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"testing"
-
-	"github.com/fatih/color"
 )
 
 func TestBase64Writer(t *testing.T) {
@@ -34,35 +33,95 @@ func TestBase64Writer(t *testing.T) {
 	}
 }
 
-func TestPrettyPrinter(t *testing.T) {
+func TestHexWriter(t *testing.T) {
 	cases := []struct {
-		input, want                  []byte
-		quoting, truncate, parseJSON bool
+		input, want []byte
 	}{
-		{[]byte(""), []byte(``), false, false, false},
-		{[]byte(""), []byte(`""`), true, false, false},
-		{[]byte("Hello, 世界！"), []byte(`Hello, 世界！`), false, false, false},
-		{[]byte("Hello, 世界！"), []byte(`"Hello, 世界！"`), true, false, false},
-		{[]byte("\"\x00\x01\a\b\f\n\r\t\v\\\""), []byte(`"\0\x01\a\b\f\n\r\t\v\\"`), false, false, false},
-		{[]byte("\"\x00\x01\a\b\f\n\r\t\v\\\""), []byte(`"\"\0\x01\a\b\f\n\r\t\v\\\""`), true, false, false},
-		{[]byte("\x80\u0080\U0001d53a"), []byte(`\x80\u0080\U0001d53a`), false, false, false},
-		{[]byte("\x80\u0080\U0001d53a"), []byte(`"\x80\u0080\U0001d53a"`), true, false, false},
-		{[]byte(`null`), []byte(`null`), false, false, true},
-		{[]byte(`"string"`), []byte(`string`), false, false, true},
-		{[]byte(`{"key":"value"}`), []byte("{\n  \"key\": \"value\"\n}"), false, false, true},
-		{[]byte(`"{\"key\":\"value\"}"`), []byte("{\n  \"key\": \"value\"\n}"), false, false, true},
-		{bytes.Repeat([]byte("a\x80"), 100), bytes.Repeat([]byte(`a\x80`), 100), false, false, false},
-		{bytes.Repeat([]byte("a\x80"), 100), append(bytes.Repeat([]byte(`a\x80`), 50), '.', '.', '.'), false, true, false},
-	}
-
-	color.NoColor = true
+		{[]byte(""), []byte("")},
+		{[]byte("abc"), []byte("616263")},
+		{[]byte("\x00\xff"), []byte("00ff")},
+	}
+
 	buf := new(bytes.Buffer)
-	w := newPrettyPrinter(buf)
+	w := newHexWriter(buf)
+	for _, tc := range cases {
+		buf.Reset()
+		if _, err := w.Write(tc.input); err != nil {
+			t.Errorf("Write(%q): unexpected error: %v", tc.input, err)
+		} else if !bytes.Equal(buf.Bytes(), tc.want) {
+			t.Errorf("Write(%q) = %q, want %q", tc.input, buf.Bytes(), tc.want)
+		}
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    outputFormat
+		wantErr bool
+	}{
+		{"escaped", escapedFormat, false},
+		{"raw", rawFormat, false},
+		{"base64", base64Format, false},
+		{"base64url", base64URLFormat, false},
+		{"hex", hexFormat, false},
+		{"", 0, true},
+		{"json", 0, true},
+		{"HEX", 0, true},
+	}
+
+	for _, tc := range cases {
+		got, err := parseOutputFormat(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseOutputFormat(%q) should fail", tc.input)
+			}
+		} else if err != nil {
+			t.Errorf("parseOutputFormat(%q): unexpected error: %v", tc.input, err)
+		} else if got != tc.want {
+			t.Errorf("parseOutputFormat(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		[]byte(""),
+		[]byte("\xfb\xff\xbf"),
+		[]byte("\xfb\xff\xbf\xfe"),
+		bytes.Repeat([]byte("\xff\xfe\x00"), 10),
+	}
+
+	buf := new(bytes.Buffer)
+	for _, url := range []bool{false, true} {
+		w := newBase64Writer(buf).SetURLEncoding(url)
+		for _, input := range inputs {
+			buf.Reset()
+			if _, err := w.Write(input); err != nil {
+				t.Fatalf("Write(%q): unexpected error: %v", input, err)
+			}
+			got, err := decodeBase64(bytes.Clone(buf.Bytes()))
+			if err != nil {
+				t.Errorf("decodeBase64(%q): unexpected error: %v", buf.Bytes(), err)
+			} else if !bytes.Equal(got, input) {
+				t.Errorf("round trip of %q (url=%v) = %q", input, url, got)
+			}
+		}
+	}
+}
+
+func TestHashWriter(t *testing.T) {
+	cases := []struct {
+		input, want []byte
+	}{
+		{[]byte(""), []byte("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")},
+		{[]byte("abc"), []byte("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")},
+	}
+
+	buf := new(bytes.Buffer)
+	w := newHashWriter(buf, sha256.New)
 	for _, tc := range cases {
 		buf.Reset()
-		w.SetQuoting(tc.quoting)
-		w.SetTruncate(tc.truncate)
-		w.SetParseJSON(tc.parseJSON)
 		n, err := w.Write(tc.input)
 		if err != nil {
 			t.Errorf("Write(%q): unexpected error: %v", tc.input, err)
@@ -85,7 +144,11 @@ func TestDecodeBase64(t *testing.T) {
 		{[]byte("YWJj"), []byte("abc")},
 		{[]byte("YWJjZA"), []byte("abcd")},
 		{[]byte("YWJjZA=="), []byte("abcd")},
+		{[]byte("YWJjZA==\n"), []byte("abcd")},
 		{[]byte("YWJjZA@@"), nil},
+		{[]byte("-_-_"), []byte("\xfb\xff\xbf")},
+		{[]byte("+/+/"), []byte("\xfb\xff\xbf")},
+		{[]byte("-/+_"), nil},
 	}
 
 	for _, tc := range cases {
@@ -100,29 +163,114 @@ func TestDecodeBase64(t *testing.T) {
 	}
 }
 
-func TestUnescape(t *testing.T) {
+func TestIsBinary(t *testing.T) {
+	cases := []struct {
+		input []byte
+		want  bool
+	}{
+		{[]byte(""), false},
+		{[]byte("Hello, 世界！\r\n\t"), false},
+		{[]byte("\x00"), true},
+		{[]byte("\x1b[31m"), true},
+		{[]byte("\x80"), true},
+		{[]byte("�"), false},
+	}
+
+	for _, tc := range cases {
+		if got := isBinary(tc.input); got != tc.want {
+			t.Errorf("isBinary(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestHexDumpWriter(t *testing.T) {
+	cases := []struct {
+		input    []byte
+		truncate int
+		want     string
+	}{
+		{[]byte(""), 0, "\n"},
+		{[]byte("abc"), 0, "\n00000000  61 62 63                                          |abc|"},
+		{[]byte("abcdef"), 4, "\n00000000  61 62 63 64                                       |abcd|\n... (6 bytes)"},
+	}
+
+	for _, tc := range cases {
+		buf := new(bytes.Buffer)
+		n, err := newHexDumpWriter(buf).SetTruncate(tc.truncate).Write(tc.input)
+		if err != nil {
+			t.Errorf("Write(%q): unexpected error: %v", tc.input, err)
+			continue
+		}
+		if n != len(tc.input) {
+			t.Errorf("Write(%q) = %d, want %d", tc.input, n, len(tc.input))
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("Write(%q) wrote %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestIntKeyWriter(t *testing.T) {
 	cases := []struct {
+		format string
+		input  []byte
+		want   string
+	}{
+		{"be32", []byte("\x00\x00\x01\x02"), "258"},
+		{"le32", []byte("\x00\x00\x01\x02"), "33619968"},
+		{"be64", []byte("\xff\xff\xff\xff\xff\xff\xff\xff"), "18446744073709551615"},
+		{"le64", []byte("\x2a\x00\x00\x00\x00\x00\x00\x00"), "42"},
+		{"be32", []byte("\x00\x00\x00\x00\x00\x00\x00\x2a"), "<000000000000002a>"},
+		{"be64", []byte("\x00\x00\x00\x2a"), "<0000002a>"},
+		{"be32", []byte(""), "<>"},
+	}
+
+	for _, tc := range cases {
+		size, order, err := parseIntKeyFormat(tc.format)
+		if err != nil {
+			t.Fatalf("parseIntKeyFormat(%q): unexpected error: %v", tc.format, err)
+		}
+		buf := new(bytes.Buffer)
+		fallback := new(bytes.Buffer)
+		w := newIntKeyWriter(buf, newHexWriter(fallback), size, order)
+		if _, err := w.Write(tc.input); err != nil {
+			t.Errorf("%s: Write(%x): unexpected error: %v", tc.format, tc.input, err)
+			continue
+		}
+		got := buf.String()
+		if fallback.Len() > 0 || got == "" {
+			got = "<" + fallback.String() + ">"
+		}
+		if got != tc.want {
+			t.Errorf("%s: Write(%x) = %q, want %q", tc.format, tc.input, got, tc.want)
+		}
+	}
+
+	if _, _, err := parseIntKeyFormat("be16"); err == nil {
+		t.Errorf("parseIntKeyFormat(%q) should fail", "be16")
+	}
+}
+
+func TestByteSwapWriter(t *testing.T) {
+	cases := []struct {
+		size        int
 		input, want []byte
 	}{
-		{[]byte(``), []byte{}},
-		{[]byte(`abc`), []byte{'a', 'b', 'c'}},
-		{[]byte(`\\\"\0\x01\a\b\f\n\r\t\v`), []byte{'\\', '"', 0, 1, '\a', '\b', '\f', '\n', '\r', '\t', '\v'}},
-		{[]byte(`\x80\u0080\U0001d53Aa`), []byte{0x80, 0xc2, 0x80, 0xf0, 0x9d, 0x94, 0xba, 'a'}},
-		{[]byte(`\`), nil},
-		{[]byte(`\x`), nil},
-		{[]byte(`\xXX`), nil},
-		{[]byte(`\uXXXX`), nil},
-		{[]byte(`\UXXXXXXXX`), nil},
+		{2, []byte("\x01\x02\x03\x04"), []byte("\x02\x01\x04\x03")},
+		{4, []byte("\x01\x02\x03\x04\x05"), []byte("\x04\x03\x02\x01\x05")},
+		{8, []byte("\x01\x02\x03"), []byte("\x01\x02\x03")},
 	}
 
 	for _, tc := range cases {
-		got, err := unescape(tc.input)
-		if tc.want == nil && err == nil {
-			t.Errorf("unescape(%q) should fail", tc.input)
-		} else if tc.want != nil && err != nil {
-			t.Errorf("unescape(%q): unexpected error: %v", tc.input, err)
-		} else if tc.want != nil && !bytes.Equal(got, tc.want) {
-			t.Errorf("unescape(%q) = %q, want %q", tc.input, got, tc.want)
+		buf := new(bytes.Buffer)
+		input := bytes.Clone(tc.input)
+		if _, err := newByteSwapWriter(buf, tc.size).Write(input); err != nil {
+			t.Errorf("%d: Write(%x): unexpected error: %v", tc.size, tc.input, err)
+		} else if !bytes.Equal(buf.Bytes(), tc.want) {
+			t.Errorf("%d: Write(%x) = %x, want %x", tc.size, tc.input, buf.Bytes(), tc.want)
+		}
+		if !bytes.Equal(input, tc.input) {
+			t.Errorf("%d: Write modified its input", tc.size)
 		}
 	}
 }
@@ -0,0 +1,73 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// delimitedIterator groups keys that share the same part up to the first
+// delimiter after prefix, like a delimited listing of S3. Key returns the
+// common part including the delimiter for grouped keys, and Next seeks
+// past the rest of the group instead of stepping through it.
+type delimitedIterator struct {
+	iterator.Iterator
+	prefix    []byte
+	delimiter []byte
+	key       []byte
+	grouped   bool
+}
+
+func newDelimitedIterator(iter iterator.Iterator, prefix, delimiter []byte) *delimitedIterator {
+	return &delimitedIterator{Iterator: iter, prefix: prefix, delimiter: delimiter}
+}
+
+func (it *delimitedIterator) update(ok bool) bool {
+	it.key, it.grouped = nil, false
+	if !ok {
+		return false
+	}
+	it.key, it.grouped = delimitedGroup(it.Iterator.Key(), it.prefix, it.delimiter)
+	return true
+}
+
+// delimitedGroup returns the part of key up to and including the first
+// delimiter after prefix, and whether there is one. Otherwise it returns
+// key itself.
+func delimitedGroup(key, prefix, delimiter []byte) ([]byte, bool) {
+	if bytes.HasPrefix(key, prefix) {
+		if n := bytes.Index(key[len(prefix):], delimiter); n >= 0 {
+			return key[:len(prefix)+n+len(delimiter)], true
+		}
+	}
+	return key, false
+}
+
+func (it *delimitedIterator) First() bool {
+	return it.update(it.Iterator.First())
+}
+
+func (it *delimitedIterator) Seek(key []byte) bool {
+	return it.update(it.Iterator.Seek(key))
+}
+
+func (it *delimitedIterator) Next() bool {
+	if !it.grouped {
+		return it.update(it.Iterator.Next())
+	}
+	next := util.BytesPrefix(it.key).Limit
+	if next == nil {
+		// No key can follow a group consisting only of 0xff bytes.
+		it.Iterator.Last()
+		return it.update(it.Iterator.Next())
+	}
+	return it.update(it.Iterator.Seek(next))
+}
+
+func (it *delimitedIterator) Key() []byte {
+	return it.key
+}
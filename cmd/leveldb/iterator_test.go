@@ -0,0 +1,45 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/memdb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestDelimitedIterator(t *testing.T) {
+	db := memdb.New(comparer.DefaultComparer, 0)
+	for _, key := range []string{"a/1", "a/2", "a/b/3", "ab", "b/1", "c", "d/x/y", "\xff/1", "\xff/2"} {
+		db.Put([]byte(key), nil)
+	}
+
+	cases := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"a/", "ab", "b/", "c", "d/", "\xff/"}},
+		{"a/", []string{"a/1", "a/2", "a/b/"}},
+		{"d/", []string{"d/x/"}},
+		{"z", nil},
+	}
+
+	for _, tc := range cases {
+		iter := newDelimitedIterator(db.NewIterator(util.BytesPrefix([]byte(tc.prefix))), []byte(tc.prefix), []byte("/"))
+		var got []string
+		for iter.Next() {
+			got = append(got, string(iter.Key()))
+		}
+		if err := iter.Error(); err != nil {
+			t.Errorf("prefix %q: unexpected error: %v", tc.prefix, err)
+		}
+		iter.Release()
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("prefix %q: got %q, want %q", tc.prefix, got, tc.want)
+		}
+	}
+}
@@ -4,14 +4,19 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
+	"os/signal"
 	"path"
 	"runtime/debug"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/cions/leveldb-cli/format"
 	"github.com/urfave/cli/v2"
 )
 
@@ -22,10 +27,109 @@ func getVersion() string {
 	return "(devel)"
 }
 
-func main() {
-	var lockFile string
+func keyRangeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "start",
+			Aliases: []string{"s"},
+			Usage:   "start of the `key` range (inclusive)",
+		},
+		&cli.StringFlag{
+			Name:    "start-raw",
+			Aliases: []string{"S"},
+			Usage:   "start of the `key` range (no backslash escapes, inclusive)",
+		},
+		&cli.StringFlag{
+			Name:  "start-base64",
+			Usage: "start of the `key` range (base64, inclusive)",
+		},
+		&cli.StringFlag{
+			Name:    "end",
+			Aliases: []string{"e"},
+			Usage:   "end of the `key` range (exclusive)",
+		},
+		&cli.StringFlag{
+			Name:    "end-raw",
+			Aliases: []string{"E"},
+			Usage:   "end of the `key` range (no backslash escapes, exclusive)",
+		},
+		&cli.StringFlag{
+			Name:  "end-base64",
+			Usage: "end of the `key` range (base64, exclusive)",
+		},
+		&cli.BoolFlag{
+			Name:  "end-inclusive",
+			Usage: "include the end key in the key range, but not longer keys beginning with it (not supported with --indexeddb)",
+		},
+		&cli.StringFlag{
+			Name:    "prefix",
+			Aliases: []string{"p"},
+			Usage:   "limit the key range to a range that satisfy the given `prefix`",
+		},
+		&cli.StringFlag{
+			Name:    "prefix-raw",
+			Aliases: []string{"P"},
+			Usage:   "limit the key range to a range that satisfy the given `prefix` (no backslash escapes)",
+		},
+		&cli.StringFlag{
+			Name:  "prefix-base64",
+			Usage: "limit the key range to a range that satisfy the given `prefix` (base64)",
+		},
+		&cli.StringFlag{
+			Name:  "prefix-from",
+			Usage: "limit the key range to the keys with the same IndexedDB database, object store and index IDs as `key` (requires --indexeddb)",
+		},
+	}
+}
+
+func timeFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "after",
+			Usage: "only include keys whose embedded timestamp is at or after `time` (unix seconds, RFC 3339 or YYYY-MM-DD)",
+		},
+		&cli.StringFlag{
+			Name:  "before",
+			Usage: "only include keys whose embedded timestamp is before `time` (unix seconds, RFC 3339 or YYYY-MM-DD)",
+		},
+		&cli.IntFlag{
+			Name:  "key-time-offset",
+			Usage: "byte `offset` of the big-endian unix timestamp in keys",
+		},
+		&cli.IntFlag{
+			Name:  "key-time-size",
+			Value: 8,
+			Usage: "size of the big-endian unix timestamp in keys in `bytes`",
+		},
+	}
+}
 
-	app := &cli.App{
+func ttlFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "ttl-prefix",
+			Usage: "treat the first 8 bytes of values as an expiry time in `format` be64 (big-endian unix milliseconds)",
+		},
+		&cli.BoolFlag{
+			Name:  "expired-only",
+			Usage: "only include entries whose expiry time has passed (requires --ttl-prefix)",
+		},
+		&cli.BoolFlag{
+			Name:  "unexpired-only",
+			Usage: "only include entries whose expiry time has not passed (requires --ttl-prefix)",
+		},
+	}
+}
+
+// newApp returns the command-line application. Its Before hook sets
+// *lockFile to the LOCK file that the command will create, so that the
+// caller can remove it if the command fails.
+func newApp(lockFile *string) *cli.App {
+	var cancel context.CancelFunc
+	var stopProfiling func() error
+	var startTime time.Time
+
+	return &cli.App{
 		Name:    "leveldb",
 		Usage:   "A command-line interface for LevelDB",
 		Version: getVersion(),
@@ -42,12 +146,84 @@ func main() {
 				Aliases: []string{"i"},
 				Usage:   "open Chromium's IndexedDB database",
 			},
+			&cli.BoolFlag{
+				Name:    "localstorage",
+				Aliases: []string{"l"},
+				Usage:   "decode keys and values of Chromium's Local Storage database",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "abort the operation after `duration` (e.g. 30s, 5m); in repl, each command",
+			},
+			&cli.IntFlag{
+				Name:  "block-cache-size",
+				Usage: "capacity of the block cache in `MiB` (0 disables the cache)",
+			},
+			&cli.IntFlag{
+				Name:  "bloom-filter-bits",
+				Usage: "use a bloom filter with the given number of `bits` per key",
+			},
+			&cli.BoolFlag{
+				Name:  "read-ahead",
+				Usage: "read the table files in the background during scans to warm the OS page cache",
+			},
+			&cli.BoolFlag{
+				Name:  "verbose",
+				Usage: "log the options, key ranges and timings of database operations to stderr",
+			},
+			&cli.BoolFlag{
+				Name:  "force-comparer",
+				Usage: "open databases made with another comparer using the selected one (keys may be misordered)",
+			},
+			&cli.StringFlag{
+				Name:   "cpuprofile",
+				Usage:  "write a CPU profile to `file`",
+				Hidden: true,
+			},
+			&cli.StringFlag{
+				Name:   "memprofile",
+				Usage:  "write a heap profile to `file` on exit",
+				Hidden: true,
+			},
 		},
 		UseShortOptionHandling: true,
 		Before: func(c *cli.Context) error {
-			p := path.Join(c.String("dbpath"), "LOCK")
+			startTime = time.Now()
+			if c.Bool("verbose") {
+				setVerbose(os.Stderr)
+			}
+			if c.Bool("indexeddb") && c.Bool("localstorage") {
+				return errors.New("options --indexeddb and --localstorage are mutually exclusive")
+			}
+			dbpath, err := resolveDBPath(c.String("dbpath"))
+			if err != nil {
+				return err
+			}
+			if err := c.Set("dbpath", dbpath); err != nil {
+				return err
+			}
+			p := path.Join(dbpath, "LOCK")
 			if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
-				lockFile = p
+				*lockFile = p
+			}
+			if timeout := c.Duration("timeout"); timeout > 0 {
+				c.Context, cancel = context.WithTimeout(c.Context, timeout)
+			}
+			if c.IsSet("cpuprofile") || c.IsSet("memprofile") {
+				stopProfiling, err = startProfiling(c.String("cpuprofile"), c.String("memprofile"))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		After: func(c *cli.Context) error {
+			if cancel != nil {
+				cancel()
+			}
+			logPhase("the command", startTime)
+			if stopProfiling != nil {
+				return stopProfiling()
 			}
 			return nil
 		},
@@ -65,6 +241,8 @@ func main() {
 				Aliases:   []string{"g"},
 				Usage:     "get the value for the given key",
 				ArgsUsage: "<key>",
+				Description: "Print the value for the given key. The exit status is 4 if the key is\n" +
+					"not found, 2 on usage errors, and 1 on other errors.",
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:    "raw",
@@ -74,7 +252,25 @@ func main() {
 					&cli.BoolFlag{
 						Name:    "base64",
 						Aliases: []string{"b"},
-						Usage:   "interpret arguments as base64-encoded",
+						Usage:   "interpret arguments as base64-encoded (standard or URL-safe)",
+					},
+					&cli.BoolFlag{
+						Name:    "force",
+						Aliases: []string{"f"},
+						Usage:   "print the value even if it is binary and stdout is a terminal",
+					},
+					&cli.BoolFlag{
+						Name:    "print",
+						Aliases: []string{"p"},
+						Usage:   "print the value escaped like show, followed by a newline",
+					},
+					&cli.StringFlag{
+						Name:  "value-slice",
+						Usage: "only show the bytes of values in `START:END` (negative indices count from the end)",
+					},
+					&cli.BoolFlag{
+						Name:  "prefix",
+						Usage: "print the value of the only key that starts with the given key",
 					},
 				},
 				Action: getCmd,
@@ -83,8 +279,12 @@ func main() {
 				Name:      "put",
 				Aliases:   []string{"p"},
 				Usage:     "set the value for the given key",
-				ArgsUsage: "<key> [<value>]",
+				ArgsUsage: "<key> [<value>] | --value-file <file> <key>...",
 				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "sync",
+						Usage: "wait for the write to reach stable storage",
+					},
 					&cli.BoolFlag{
 						Name:    "raw",
 						Aliases: []string{"r"},
@@ -93,17 +293,65 @@ func main() {
 					&cli.BoolFlag{
 						Name:    "base64",
 						Aliases: []string{"b"},
-						Usage:   "interpret arguments as base64-encoded",
+						Usage:   "interpret arguments as base64-encoded (standard or URL-safe)",
+					},
+					&cli.StringFlag{
+						Name:    "value-file",
+						Aliases: []string{"f"},
+						Usage:   "read the value from `file` (- for stdin) and set it for all given keys",
+					},
+					&cli.BoolFlag{
+						Name:    "trim-newline",
+						Aliases: []string{"chomp"},
+						Usage:   "strip a single trailing newline from a value read from stdin",
+					},
+					&cli.BoolFlag{
+						Name:  "decode-value",
+						Usage: "decode a value read from a file or stdin like a value argument (backslash escapes, or base64 with -b)",
 					},
 				},
 				Action: putCmd,
 			},
+			{
+				Name:      "edit",
+				Usage:     "edit the value for the given key in $EDITOR",
+				ArgsUsage: "<key>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "sync",
+						Usage: "wait for the write to reach stable storage",
+					},
+					&cli.BoolFlag{
+						Name:    "raw",
+						Aliases: []string{"r"},
+						Usage:   "do not interpret backslash escapes",
+					},
+					&cli.BoolFlag{
+						Name:    "base64",
+						Aliases: []string{"b"},
+						Usage:   "interpret arguments as base64-encoded (standard or URL-safe)",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "pretty-print the value before editing and minify it afterwards",
+					},
+					&cli.BoolFlag{
+						Name:  "create",
+						Usage: "edit an empty value if the key does not exist",
+					},
+				},
+				Action: editCmd,
+			},
 			{
 				Name:      "delete",
 				Aliases:   []string{"d"},
 				Usage:     "delete the value for the given key",
-				ArgsUsage: "<key>...",
-				Flags: []cli.Flag{
+				ArgsUsage: "<key>... | --stdin",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "sync",
+						Usage: "wait for the write to reach stable storage",
+					},
 					&cli.BoolFlag{
 						Name:    "raw",
 						Aliases: []string{"r"},
@@ -112,75 +360,167 @@ func main() {
 					&cli.BoolFlag{
 						Name:    "base64",
 						Aliases: []string{"b"},
-						Usage:   "interpret arguments as base64-encoded",
+						Usage:   "interpret arguments as base64-encoded (standard or URL-safe)",
 					},
 					&cli.BoolFlag{
 						Name:    "regexp",
 						Aliases: []string{"R"},
 						Usage:   "treat arguments as regular expressions",
 					},
+					&cli.BoolFlag{
+						Name:    "full-match",
+						Aliases: []string{"x"},
+						Usage:   "require regular expressions to match the entire key",
+					},
+					&cli.BoolFlag{
+						Name:  "ignore-case",
+						Usage: "match regular expressions case-insensitively",
+					},
 					&cli.BoolFlag{
 						Name:    "invert-match",
 						Aliases: []string{"v"},
 						Usage:   "invert the sense of matching; delete non-matching keys",
 					},
 					&cli.StringFlag{
-						Name:    "start",
-						Aliases: []string{"s"},
-						Usage:   "start of the `key` range (inclusive)",
+						Name:  "value-regexp",
+						Usage: "only delete entries whose value matches `pattern`",
 					},
-					&cli.StringFlag{
-						Name:    "start-raw",
-						Aliases: []string{"S"},
-						Usage:   "start of the `key` range (no backslash escapes, inclusive)",
+					&cli.BoolFlag{
+						Name:  "stdin",
+						Usage: "read keys to delete from stdin, one per line (deleted in batches, not atomically)",
 					},
-					&cli.StringFlag{
-						Name:  "start-base64",
-						Usage: "start of the `key` range (base64, inclusive)",
+					&cli.BoolFlag{
+						Name:    "dry-run",
+						Aliases: []string{"n"},
+						Usage:   "do not actually delete; just show what would be deleted",
 					},
-					&cli.StringFlag{
-						Name:    "end",
-						Aliases: []string{"e"},
-						Usage:   "end of the `key` range (exclusive)",
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
 					},
-					&cli.StringFlag{
-						Name:    "end-raw",
-						Aliases: []string{"E"},
-						Usage:   "end of the `key` range (no backslash escapes, exclusive)",
+				}, slices.Concat(timeFilterFlags(), keyRangeFlags())...),
+				UseShortOptionHandling: true,
+				Action:                 deleteCmd,
+			},
+			{
+				Name:      "clear",
+				Usage:     "delete all entries but keep the database (not atomic)",
+				ArgsUsage: " ",
+				Description: "The entries are deleted in batches of 1000, so the clear is not atomic: if it\n" +
+					"fails or is interrupted, part of the range is already deleted. Running it\n" +
+					"again deletes the rest.",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:    "dry-run",
+						Aliases: []string{"n"},
+						Usage:   "do not actually delete; just show what would be deleted",
 					},
-					&cli.StringFlag{
-						Name:  "end-base64",
-						Usage: "end of the `key` range (base64, exclusive)",
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
 					},
-					&cli.StringFlag{
-						Name:    "prefix",
-						Aliases: []string{"p"},
-						Usage:   "limit the key range to a range that satisfy the given `prefix`",
+				}, keyRangeFlags()...),
+				UseShortOptionHandling: true,
+				Action:                 clearCmd,
+			},
+			{
+				Name:      "rename",
+				Usage:     "replace the key prefix of all matching entries",
+				ArgsUsage: "<old-prefix> <new-prefix>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "raw",
+						Aliases: []string{"r"},
+						Usage:   "do not interpret backslash escapes",
 					},
-					&cli.StringFlag{
-						Name:    "prefix-raw",
-						Aliases: []string{"P"},
-						Usage:   "limit the key range to a range that satisfy the given `prefix` (no backslash escapes)",
+					&cli.BoolFlag{
+						Name:    "base64",
+						Aliases: []string{"b"},
+						Usage:   "interpret arguments as base64-encoded (standard or URL-safe)",
 					},
-					&cli.StringFlag{
-						Name:  "prefix-base64",
-						Usage: "limit the key range to a range that satisfy the given `prefix` (base64)",
+					&cli.BoolFlag{
+						Name:    "dry-run",
+						Aliases: []string{"n"},
+						Usage:   "do not actually rename; just show what would be renamed",
+					},
+					&cli.BoolFlag{
+						Name:    "force",
+						Aliases: []string{"f"},
+						Usage:   "overwrite existing keys under the new prefix",
+					},
+				},
+				UseShortOptionHandling: true,
+				Action:                 renameCmd,
+			},
+			{
+				Name:      "batch",
+				Usage:     "apply puts and deletes from a script atomically",
+				ArgsUsage: "[<file>]",
+				Description: "Reads operations from <file> (or stdin), one per line:\n\n" +
+					"   put <key> <value>\n" +
+					"   delete <key>\n\n" +
+					"Arguments may be quoted with '' or \"\". Blank lines and lines starting with # are ignored.\n" +
+					"All operations are written as a single batch, so either all of them are applied or none.",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "sync",
+						Usage: "wait for the write to reach stable storage",
+					},
+					&cli.BoolFlag{
+						Name:    "raw",
+						Aliases: []string{"r"},
+						Usage:   "do not interpret backslash escapes",
+					},
+					&cli.BoolFlag{
+						Name:    "base64",
+						Aliases: []string{"b"},
+						Usage:   "interpret arguments as base64-encoded (standard or URL-safe)",
 					},
 					&cli.BoolFlag{
 						Name:    "dry-run",
 						Aliases: []string{"n"},
-						Usage:   "do not actually delete; just show what would be deleted",
+						Usage:   "do not actually write; just show the operations",
 					},
 				},
 				UseShortOptionHandling: true,
-				Action:                 deleteCmd,
+				Action:                 batchCmd,
 			},
 			{
 				Name:      "keys",
 				Aliases:   []string{"k"},
 				Usage:     "list all keys",
-				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				ArgsUsage: "[<dbpath>...]",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "pager",
+						Usage: "pipe the output through $PAGER (or less) when stdout is a terminal",
+					},
+					&cli.BoolFlag{
+						Name:  "explain",
+						Usage: "print the key range that would be scanned and exit",
+					},
+					&cli.BoolFlag{
+						Name:  "strip-prefix",
+						Usage: "remove the prefix given by --prefix from the printed keys",
+					},
+					&cli.StringFlag{
+						Name:  "key-int",
+						Usage: "print keys of the given integer `format` (be32, be64, le32, le64) in decimal",
+					},
+					&cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "`name` shown for the corresponding database argument (may be repeated)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-snapshot",
+						Usage: "iterate without a snapshot (the output may not be consistent if the database is modified)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "skip keys matching the regular expression `pattern` (may be repeated)",
+					},
 					&cli.BoolFlag{
 						Name:    "raw",
 						Aliases: []string{"r"},
@@ -191,57 +531,194 @@ func main() {
 						Aliases: []string{"b"},
 						Usage:   "show keys in base64 encoding",
 					},
+					&cli.BoolFlag{
+						Name:  "base64url",
+						Usage: "show keys in URL-safe base64 encoding",
+					},
+					&cli.BoolFlag{
+						Name:    "null",
+						Aliases: []string{"0"},
+						Usage:   "terminate each key with a NUL character instead of a newline (implies --raw)",
+					},
+					&cli.StringFlag{
+						Name:  "escape",
+						Value: "go",
+						Usage: "escape `style` for special characters (go, json, c)",
+					},
+					&cli.StringFlag{
+						Name:  "key-format",
+						Usage: "output `format` for keys (escaped, raw, base64, base64url, hex); overrides -r and -b",
+					},
+					&cli.BoolFlag{
+						Name:  "utf16",
+						Usage: "decode keys as UTF-16LE where possible",
+					},
+					&cli.StringFlag{
+						Name:  "delimiter",
+						Usage: "group keys by the part up to the first `delimiter` after the prefix and list each group once",
+					},
+					&cli.BoolFlag{
+						Name:  "count-per-prefix",
+						Usage: "print the number of keys in each group after a tab (requires --delimiter)",
+					},
+					&cli.BoolFlag{
+						Name:  "stats",
+						Usage: "print the number of entries to stderr",
+					},
+				}, slices.Concat(timeFilterFlags(), ttlFlags(), keyRangeFlags())...),
+				UseShortOptionHandling: true,
+				Action:                 pagedAction(keysCmd),
+			},
+			{
+				Name:      "show",
+				Aliases:   []string{"s"},
+				Usage:     "show all entries",
+				ArgsUsage: "[<dbpath>...]",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "pager",
+						Usage: "pipe the output through $PAGER (or less) when stdout is a terminal",
+					},
+					&cli.BoolFlag{
+						Name:  "explain",
+						Usage: "print the key range that would be scanned and exit",
+					},
+					&cli.BoolFlag{
+						Name:    "null",
+						Aliases: []string{"0"},
+						Usage:   "separate keys and values with NUL and terminate each entry with NUL, without escaping",
+					},
 					&cli.StringFlag{
-						Name:    "start",
-						Aliases: []string{"s"},
-						Usage:   "start of the `key` range (inclusive)",
+						Name:  "separator",
+						Usage: "print `string` between keys and values instead of \": \" (backslash escapes are interpreted)",
 					},
 					&cli.StringFlag{
-						Name:    "start-raw",
-						Aliases: []string{"S"},
-						Usage:   "start of the `key` range (no backslash escapes, inclusive)",
+						Name:  "record-separator",
+						Usage: "print `string` after each entry instead of a newline (backslash escapes are interpreted)",
+					},
+					&cli.IntFlag{
+						Name:  "byte-swap",
+						Usage: "reverse each group of `n` bytes (2, 4 or 8) of values before display",
+					},
+					&cli.BoolFlag{
+						Name:  "strip-prefix",
+						Usage: "remove the prefix given by --prefix from the printed keys",
 					},
 					&cli.StringFlag{
-						Name:  "start-base64",
-						Usage: "start of the `key` range (base64, inclusive)",
+						Name:  "key-int",
+						Usage: "print keys of the given integer `format` (be32, be64, le32, le64) in decimal",
+					},
+					&cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "`name` shown for the corresponding database argument (may be repeated)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-snapshot",
+						Usage: "iterate without a snapshot (the output may not be consistent if the database is modified)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "skip keys matching the regular expression `pattern` (may be repeated)",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "show at most `n` entries",
 					},
 					&cli.StringFlag{
-						Name:    "end",
-						Aliases: []string{"e"},
-						Usage:   "end of the `key` range (exclusive)",
+						Name:  "sort",
+						Usage: "show entries in `order` value, value-length or key-length (longest first) instead of key order (requires --limit)",
+					},
+					&cli.BoolFlag{
+						Name:    "raw",
+						Aliases: []string{"r"},
+						Usage:   "do not escape special characters",
+					},
+					&cli.BoolFlag{
+						Name:    "base64",
+						Aliases: []string{"b"},
+						Usage:   "show keys and values in base64 encoding",
+					},
+					&cli.BoolFlag{
+						Name:  "base64url",
+						Usage: "show keys and values in URL-safe base64 encoding",
 					},
 					&cli.StringFlag{
-						Name:    "end-raw",
-						Aliases: []string{"E"},
-						Usage:   "end of the `key` range (no backslash escapes, exclusive)",
+						Name:  "value-slice",
+						Usage: "only show the bytes of values in `START:END` (negative indices count from the end)",
+					},
+					&cli.BoolFlag{
+						Name:    "no-json",
+						Aliases: []string{"J"},
+						Usage:   "do not pretty-print JSON values",
+					},
+					&cli.IntFlag{
+						Name:  "json-depth",
+						Usage: "collapse JSON objects and arrays nested deeper than `depth` (0 means no limit)",
+					},
+					&cli.BoolFlag{
+						Name:    "no-truncate",
+						Aliases: []string{"w"},
+						Usage:   "do not truncate output",
+					},
+					&cli.IntFlag{
+						Name:  "truncate",
+						Value: format.DefaultTruncate,
+						Usage: "truncate values longer than `width` (0 means no limit)",
+					},
+					&cli.IntFlag{
+						Name:  "truncate-bytes",
+						Usage: "truncate values after the first `n` bytes of input instead of by output width",
 					},
 					&cli.StringFlag{
-						Name:  "end-base64",
-						Usage: "end of the `key` range (base64, exclusive)",
+						Name:  "escape",
+						Value: "go",
+						Usage: "escape `style` for special characters (go, json, c)",
 					},
 					&cli.StringFlag{
-						Name:    "prefix",
-						Aliases: []string{"p"},
-						Usage:   "limit the key range to a range that satisfy the given `prefix`",
+						Name:  "key-format",
+						Usage: "output `format` for keys (escaped, raw, base64, base64url, hex); overrides -r and -b",
 					},
 					&cli.StringFlag{
-						Name:    "prefix-raw",
-						Aliases: []string{"P"},
-						Usage:   "limit the key range to a range that satisfy the given `prefix` (no backslash escapes)",
+						Name:  "value-format",
+						Usage: "output `format` for values (escaped, raw, base64, base64url, hex); overrides -r and -b",
+					},
+					&cli.BoolFlag{
+						Name:  "utf16",
+						Usage: "decode keys and values as UTF-16LE where possible",
+					},
+					&cli.BoolFlag{
+						Name:  "strip-value-header",
+						Usage: "strip the IndexedDB value header and show the V8 payload of object store values and its offset (requires --indexeddb)",
+					},
+					&cli.BoolFlag{
+						Name:  "idb-decode",
+						Usage: "decode the keys of IndexedDB object store and index data, and object store values into JSON (requires --indexeddb)",
+					},
+					&cli.BoolFlag{
+						Name:  "hexdump",
+						Usage: "show values as canonical hex dumps (limited by --truncate in bytes)",
+					},
+					&cli.BoolFlag{
+						Name:  "stats",
+						Usage: "print the number of entries and their total size to stderr",
+					},
+					&cli.BoolFlag{
+						Name:  "hash",
+						Usage: "show digests of values instead of values",
 					},
 					&cli.StringFlag{
-						Name:  "prefix-base64",
-						Usage: "limit the key range to a range that satisfy the given `prefix` (base64)",
+						Name:  "hash-algo",
+						Value: "sha256",
+						Usage: "hash `algorithm` to use (sha256, sha1, md5, crc32)",
 					},
-				},
+				}, slices.Concat(timeFilterFlags(), ttlFlags(), keyRangeFlags())...),
 				UseShortOptionHandling: true,
-				Action:                 keysCmd,
+				Action:                 pagedAction(showCmd),
 			},
 			{
-				Name:      "show",
-				Aliases:   []string{"s"},
-				Usage:     "show all entries",
-				ArgsUsage: " ",
+				Name:      "sst",
+				Usage:     "show the newest version of the entries in an SSTable file",
+				ArgsUsage: "<file>",
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:    "raw",
@@ -253,6 +730,10 @@ func main() {
 						Aliases: []string{"b"},
 						Usage:   "show keys and values in base64 encoding",
 					},
+					&cli.BoolFlag{
+						Name:  "base64url",
+						Usage: "show keys and values in URL-safe base64 encoding",
+					},
 					&cli.BoolFlag{
 						Name:    "no-json",
 						Aliases: []string{"J"},
@@ -263,70 +744,279 @@ func main() {
 						Aliases: []string{"w"},
 						Usage:   "do not truncate output",
 					},
-					&cli.StringFlag{
-						Name:    "start",
-						Aliases: []string{"s"},
-						Usage:   "start of the `key` range (inclusive)",
+					&cli.IntFlag{
+						Name:  "truncate",
+						Value: format.DefaultTruncate,
+						Usage: "truncate values longer than `width` (0 means no limit)",
 					},
-					&cli.StringFlag{
-						Name:    "start-raw",
-						Aliases: []string{"S"},
-						Usage:   "start of the `key` range (no backslash escapes, inclusive)",
+					&cli.IntFlag{
+						Name:  "truncate-bytes",
+						Usage: "truncate values after the first `n` bytes of input instead of by output width",
 					},
 					&cli.StringFlag{
-						Name:  "start-base64",
-						Usage: "start of the `key` range (base64, inclusive)",
+						Name:  "escape",
+						Value: "go",
+						Usage: "escape `style` for special characters (go, json, c)",
 					},
 					&cli.StringFlag{
-						Name:    "end",
-						Aliases: []string{"e"},
-						Usage:   "end of the `key` range (exclusive)",
+						Name:  "key-format",
+						Usage: "output `format` for keys (escaped, raw, base64, base64url, hex); overrides -r and -b",
 					},
 					&cli.StringFlag{
-						Name:    "end-raw",
-						Aliases: []string{"E"},
-						Usage:   "end of the `key` range (no backslash escapes, exclusive)",
+						Name:  "value-format",
+						Usage: "output `format` for values (escaped, raw, base64, base64url, hex); overrides -r and -b",
 					},
-					&cli.StringFlag{
-						Name:  "end-base64",
-						Usage: "end of the `key` range (base64, exclusive)",
+					&cli.BoolFlag{
+						Name:    "keys-only",
+						Aliases: []string{"k"},
+						Usage:   "show only keys",
 					},
-					&cli.StringFlag{
-						Name:    "prefix",
-						Aliases: []string{"p"},
-						Usage:   "limit the key range to a range that satisfy the given `prefix`",
+					&cli.Uint64Flag{
+						Name:  "min-sequence",
+						Usage: "show only entries written at sequence number `seq` or later",
+					},
+					&cli.BoolFlag{
+						Name:  "all-versions",
+						Usage: "show every version of a key in the file, not just the newest one",
+					},
+				},
+				UseShortOptionHandling: true,
+				Action:                 sstCmd,
+			},
+			{
+				Name:      "count",
+				Usage:     "count the entries in the key range",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "estimate",
+						Usage: "estimate the count from the table sizes instead of scanning all entries",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print the result as a JSON object",
 					},
+					&cli.BoolFlag{
+						Name:  "no-snapshot",
+						Usage: "iterate without a snapshot (the output may not be consistent if the database is modified)",
+					},
+				}, keyRangeFlags()...),
+				Action: countCmd,
+			},
+			{
+				Name:      "size",
+				Aliases:   []string{"du"},
+				Usage:     "show the logical and on-disk size of the database",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print the result as a JSON object",
+					},
+					&cli.BoolFlag{
+						Name:  "no-snapshot",
+						Usage: "iterate without a snapshot (the output may not be consistent if the database is modified)",
+					},
+				}, keyRangeFlags()...),
+				Action: sizeCmd,
+			},
+			{
+				Name:      "describe",
+				Usage:     "show the metadata recorded in the MANIFEST file",
+				ArgsUsage: " ",
+				Description: "With --runtime, the database is opened and goleveldb's cache, table and\n" +
+					"iterator properties are shown. They are those of a freshly opened database,\n" +
+					"so the cache is empty and no tables are open; to see them after a scan, run\n" +
+					"a reading command such as show or count with --verbose, which logs them when the\n" +
+					"scan ends.",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print the result as a JSON object",
+					},
+					&cli.BoolFlag{
+						Name:  "runtime",
+						Usage: "open the database and also show its cache, table and iterator properties right after opening",
+					},
+				},
+				Action: describeCmd,
+			},
+			{
+				Name:      "hash",
+				Usage:     "compute a digest of all entries",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
-						Name:    "prefix-raw",
-						Aliases: []string{"P"},
-						Usage:   "limit the key range to a range that satisfy the given `prefix` (no backslash escapes)",
+						Name:  "hash-algo",
+						Value: "sha256",
+						Usage: "hash `algorithm` to use (sha256, sha1, md5, crc32)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-snapshot",
+						Usage: "iterate without a snapshot (the output may not be consistent if the database is modified)",
+					},
+				}, keyRangeFlags()...),
+				UseShortOptionHandling: true,
+				Action:                 hashCmd,
+			},
+			{
+				Name:      "verify",
+				Usage:     "read all entries and verify their checksums",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    "concurrency",
+						Aliases: []string{"j"},
+						Value:   1,
+						Usage:   "verify `N` ranges of the key space concurrently",
+					},
+				},
+				Action: verifyCmd,
+			},
+			{
+				Name:      "repl",
+				Usage:     "start an interactive shell",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "read-only",
+						Usage: "open the database in read-only mode",
+					},
+					&cli.BoolFlag{
+						Name:    "raw",
+						Aliases: []string{"r"},
+						Usage:   "do not interpret backslash escapes",
+					},
+					&cli.BoolFlag{
+						Name:    "base64",
+						Aliases: []string{"b"},
+						Usage:   "interpret arguments as base64-encoded (standard or URL-safe)",
 					},
 					&cli.StringFlag{
-						Name:  "prefix-base64",
-						Usage: "limit the key range to a range that satisfy the given `prefix` (base64)",
+						Name:    "history-file",
+						Usage:   "load the command history from `file` and append new commands to it",
+						EnvVars: []string{"LEVELDB_HISTORY"},
 					},
 				},
-				UseShortOptionHandling: true,
-				Action:                 showCmd,
+				Action: replCmd,
 			},
 			{
 				Name:      "dump",
-				Usage:     "dump the database as MessagePack",
+				Usage:     "dump the database as MessagePack, CSV or a self-describing archive",
 				ArgsUsage: "[output]",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Value:   "msgpack",
+						Usage:   "dump file `format` (msgpack, csv, archive)",
+					},
+					&cli.StringFlag{
+						Name:  "archive-format",
+						Value: "msgpack",
+						Usage: "`format` of the dump inside an archive (msgpack, csv)",
+					},
 					&cli.BoolFlag{
 						Name:    "no-clobber",
 						Aliases: []string{"n"},
 						Usage:   "do not overwrite an existing file",
 					},
-				},
+					&cli.IntFlag{
+						Name:    "parallel",
+						Aliases: []string{"j"},
+						Value:   1,
+						Usage:   "scan the database with `N` concurrent iterators",
+					},
+					&cli.StringFlag{
+						Name:    "compress",
+						Aliases: []string{"z"},
+						Value:   "none",
+						Usage:   "compress the output with the given `method` (none, gzip, zstd)",
+					},
+					&cli.IntFlag{
+						Name:  "max-entries",
+						Usage: "dump at most `N` entries from the start of the key range",
+					},
+					&cli.BoolFlag{
+						Name:  "keys-only",
+						Usage: "dump only keys (load stores empty values)",
+					},
+					&cli.BoolFlag{
+						Name:  "values-only",
+						Usage: "dump only values (cannot be loaded)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-header",
+						Usage: "omit the header and the comparer record of CSV dumps",
+					},
+					&cli.IntFlag{
+						Name:  "tee",
+						Usage: "also print the first `N` entries to stderr as show does",
+					},
+				}, keyRangeFlags()...),
 				Action: dumpCmd,
 			},
 			{
 				Name:      "load",
-				Usage:     "load MessagePack into the database",
+				Usage:     "load MessagePack, CSV or an archive (optionally gzip- or zstd-compressed) into the database, creating it if needed",
 				ArgsUsage: "[input]",
-				Action:    loadCmd,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "sync",
+						Usage: "wait for the write to reach stable storage",
+					},
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Value:   "auto",
+						Usage:   "dump file `format` (auto, msgpack, csv, archive)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-header",
+						Usage: "read a CSV dump written with --no-header (requires --format csv)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-overwrite",
+						Usage: "skip keys that already exist in the database",
+					},
+					&cli.BoolFlag{
+						Name:  "error-on-conflict",
+						Usage: "abort if a key already exists with a different value",
+					},
+					&cli.IntFlag{
+						Name:  "write-buffer",
+						Usage: "size of the memtable in `MiB` (default: 4)",
+					},
+					&cli.IntFlag{
+						Name:  "block-size",
+						Usage: "size of the table blocks in `KiB` (default: 4)",
+					},
+				},
+				Action: loadCmd,
+			},
+			{
+				Name:      "backup",
+				Usage:     "copy the database files to a new directory, hard-linking tables where possible",
+				ArgsUsage: "<dest>",
+				Action:    backupCmd,
+			},
+			{
+				Name:      "restore",
+				Usage:     "replace the database with a copy of the database at the given path",
+				ArgsUsage: "<src>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "force",
+						Aliases: []string{"f"},
+						Usage:   "replace the database files in a non-empty directory",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
+					},
+				},
+				Action: restoreCmd,
 			},
 			{
 				Name:      "repair",
@@ -338,7 +1028,14 @@ func main() {
 				Name:      "compact",
 				Usage:     "compact the database",
 				ArgsUsage: " ",
-				Action:    compactCmd,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "dry-run",
+						Aliases: []string{"n"},
+						Usage:   "do not actually compact; just estimate the space that would be reclaimed",
+					},
+				},
+				Action: compactCmd,
 			},
 			{
 				Name:      "destroy",
@@ -350,17 +1047,100 @@ func main() {
 						Aliases: []string{"n"},
 						Usage:   "do not actually remove; just show what would be removed",
 					},
+					&cli.BoolFlag{
+						Name:  "fast",
+						Usage: "remove files in parallel and print only the number of files removed",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
+					},
 				},
 				Action: destroyCmd,
 			},
+			{
+				Name:      "bench",
+				Usage:     "measure write, scan and get throughput on a scratch database",
+				ArgsUsage: " ",
+				Hidden:    true,
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    "entries",
+						Aliases: []string{"n"},
+						Value:   100000,
+						Usage:   "number of entries to write",
+					},
+					&cli.IntFlag{
+						Name:  "key-size",
+						Value: 16,
+						Usage: "size of the random keys in `bytes`",
+					},
+					&cli.IntFlag{
+						Name:  "value-size",
+						Value: 100,
+						Usage: "size of the values in `bytes`",
+					},
+					&cli.IntFlag{
+						Name:  "gets",
+						Usage: "number of random gets (default: the number of entries)",
+					},
+					&cli.Int64Flag{
+						Name:  "seed",
+						Value: 1,
+						Usage: "seed of the random data",
+					},
+					&cli.BoolFlag{
+						Name:  "sync",
+						Usage: "wait for each write batch to reach stable storage",
+					},
+				},
+				Action: benchCmd,
+			},
+			{
+				Name:      "completion",
+				Usage:     "generate a shell completion script",
+				ArgsUsage: "bash|zsh|fish",
+				Action:    completionCmd,
+			},
 		},
 	}
+}
+
+func main() {
+	var lockFile string
+	app := newApp(&lockFile)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		// Restore the default behavior so that a second interrupt
+		// terminates the process immediately.
+		<-ctx.Done()
+		stop()
+	}()
 
-	if err := app.Run(os.Args); err != nil {
+	if err := app.RunContext(ctx, os.Args); err != nil {
 		if lockFile != "" {
 			os.Remove(lockFile)
 		}
-		fmt.Fprintf(os.Stderr, "leveldb: error: %v\n", err)
-		os.Exit(1)
+		code := exitCode(err)
+		if code == 130 {
+			fmt.Fprintln(os.Stderr, "leveldb: interrupted")
+		} else {
+			fmt.Fprintf(os.Stderr, "leveldb: error: %v\n", err)
+		}
+		os.Exit(code)
+	}
+}
+
+// exitCode returns the exit status for an error returned by a command.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errInterrupted), errors.Is(err, context.Canceled):
+		return 130
+	case errors.Is(err, errKeyNotFound):
+		return 4
+	default:
+		return 1
 	}
 }
@@ -0,0 +1,517 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cions/leveldb-cli/indexeddb"
+	"github.com/fatih/color"
+	"github.com/klauspost/compress/snappy"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/table"
+)
+
+// runApp runs the application with the given arguments and returns what
+// the command wrote to standard output.
+func runApp(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	return runAppContext(t, context.Background(), args...)
+}
+
+// runAppContext is like runApp, but runs the application with ctx.
+func runAppContext(t *testing.T, ctx context.Context, args ...string) (string, error) {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	func(stdout *os.File, output io.Writer, noColor bool) {
+		defer func() { os.Stdout, color.Output, color.NoColor = stdout, output, noColor }()
+		os.Stdout, color.Output, color.NoColor = f, f, true
+		var lockFile string
+		err = newApp(&lockFile).RunContext(ctx, append([]string{"leveldb"}, args...))
+	}(os.Stdout, color.Output, color.NoColor)
+
+	out, rerr := os.ReadFile(f.Name())
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	return string(out), err
+}
+
+// newTestDB creates a database in a temporary directory with the given
+// keys, each set to its own name as the value.
+func newTestDB(t *testing.T, keys ...string) string {
+	t.Helper()
+	dbpath := t.TempDir()
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := db.Put([]byte(key), []byte(key), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dbpath
+}
+
+// dbKeys returns the keys in the database at dbpath.
+func dbKeys(t *testing.T, dbpath string) []string {
+	t.Helper()
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var keys []string
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{errKeyNotFound, 4},
+		{fmt.Errorf("get: %w", errKeyNotFound), 4},
+		// Lookups that fail inside other commands are ordinary errors.
+		{leveldb.ErrNotFound, 1},
+		{fmt.Errorf("describe: %w", leveldb.ErrNotFound), 1},
+		{errInterrupted, 130},
+		{context.Canceled, 130},
+		{errors.New("other"), 1},
+	}
+	for _, tc := range cases {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	want := fmt.Sprintf("leveldb version %s\n", getVersion())
+	for _, arg := range []string{"-v", "--version"} {
+		out, err := runApp(t, arg)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", arg, err)
+		} else if out != want {
+			t.Errorf("%s = %q, want %q", arg, out, want)
+		}
+	}
+}
+
+func TestDeleteCmd(t *testing.T) {
+	cases := []struct {
+		args []string
+		want []string
+	}{
+		// The empty key is only deleted when given explicitly.
+		{[]string{"delete", "a"}, []string{"", "b", "c"}},
+		{[]string{"delete", "a", ""}, []string{"b", "c"}},
+		{[]string{"delete", "a", "c"}, []string{"", "b"}},
+		{[]string{"delete", "--dry-run", "a"}, []string{"", "a", "b", "c"}},
+	}
+	for _, tc := range cases {
+		dbpath := newTestDB(t, "", "a", "b", "c")
+		if _, err := runApp(t, append([]string{"-d", dbpath}, tc.args...)...); err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.args, err)
+			continue
+		}
+		if got := dbKeys(t, dbpath); !slices.Equal(got, tc.want) {
+			t.Errorf("%q: keys = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestShowStripValueHeader(t *testing.T) {
+	dbpath := t.TempDir()
+	db, err := leveldb.OpenFile(dbpath, &opt.Options{Comparer: indexeddb.Comparer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[float64][]byte{
+		1: []byte("\x01\xff\x14\xff\x0f\x22\x03abc"),
+		2: slices.Concat([]byte("\x01\xff\x11\x02"), snappy.Encode(nil, []byte("\xff\x14\xff\x0f\x22\x03def"))),
+		3: []byte("not a value"),
+	}
+	for f, value := range values {
+		if err := db.Put(idbNumberKey(f), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runApp(t, "-i", "-d", dbpath, "show", "--strip-value-header", "--value-format", "hex")
+	if err != nil {
+		t.Fatalf("show: unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"(payload at offset 3) ff0f2203616263\n",
+		"(compressed payload) ff0f2203646566\n",
+		": 6e6f7420612076616c7565\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("show output %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestShowIDBDecode(t *testing.T) {
+	// The keys are laid out as Chromium writes them: a key prefix, the
+	// encoded user key and, for index data, a sequence number and the
+	// encoded primary key.
+	primaryKey := "\x04\x02\x01\x04\x00u\x00s\x00e\x00r\x03\x00\x00\x00\x00\x00\x00\x45\x40"
+	entries := [][2]string{
+		{"\x00\x00\x00\x00\x00", "\x03"},
+		{string(indexeddb.EncodeKeyPrefix(indexeddb.KeyPrefix{DatabaseId: 1, ObjectStoreId: 2, IndexId: 1})) + primaryKey,
+			"\x01\xff\x14\xff\x0fo\"\x04name\"\x05Alice\"\x03ageI\x3c{\x02"},
+		{string(indexeddb.EncodeKeyPrefix(indexeddb.KeyPrefix{DatabaseId: 1, ObjectStoreId: 2, IndexId: 1})) + "\x01\x01\x00b",
+			"\x01\xff\x14\xff\x0f\\"},
+		{string(indexeddb.EncodeKeyPrefix(indexeddb.KeyPrefix{DatabaseId: 1, ObjectStoreId: 2, IndexId: 30})) + "\x01\x05\x00A\x00l\x00i\x00c\x00e\x01" + primaryKey,
+			"\x01"},
+	}
+	dbpath := t.TempDir()
+	db, err := leveldb.OpenFile(dbpath, &opt.Options{Comparer: indexeddb.Comparer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := db.Put([]byte(e[0]), []byte(e[1]), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runApp(t, "-i", "-d", dbpath, "show", "--idb-decode")
+	if err != nil {
+		t.Fatalf("show: unexpected error: %v", err)
+	}
+	// Values that cannot be decoded, such as the Blob in the second
+	// entry, are shown as they are.
+	want := `\0\0\0\0\0: "\x03"` + "\n" +
+		`db1/store2 key=["user",42]: {` + "\n" +
+		`  "name": "Alice",` + "\n" +
+		`  "age": 30` + "\n" +
+		`}` + "\n" +
+		`db1/store2 key="b": "\x01\xff\x14\xff\x0f\\"` + "\n" +
+		`db1/store2/index30 key="Alice" seq=1 primary=["user",42]: "\x01"` + "\n"
+	if out != want {
+		t.Errorf("show --idb-decode = %q, want %q", out, want)
+	}
+
+	if _, err := runApp(t, "-d", dbpath, "show", "--idb-decode"); err == nil {
+		t.Error("show --idb-decode without --indexeddb should fail")
+	}
+}
+
+func TestShowLocalStorageFormats(t *testing.T) {
+	dbpath := t.TempDir()
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put([]byte("_https://example.com\x00\x01k"), []byte("\x01abc"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--value-format", "hex"}, "\"https://example.com / k\": 616263\n"},
+		{[]string{"--value-format", "base64"}, "\"https://example.com / k\": YWJj\n"},
+		{[]string{"-b"}, "aHR0cHM6Ly9leGFtcGxlLmNvbSAvIGs=: YWJj\n"},
+		{[]string{"--key-format", "hex", "--value-format", "raw"}, "68747470733a2f2f6578616d706c652e636f6d202f206b: abc\n"},
+	}
+	for _, tc := range cases {
+		out, err := runApp(t, append([]string{"-l", "-d", dbpath, "show"}, tc.args...)...)
+		if err != nil {
+			t.Errorf("show %q: unexpected error: %v", tc.args, err)
+		} else if out != tc.want {
+			t.Errorf("show %q = %q, want %q", tc.args, out, tc.want)
+		}
+	}
+}
+
+func TestRenameCmd(t *testing.T) {
+	cases := []struct {
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"rename", "a/", "c/"}, []string{"b/1", "c/1", "c/2"}, false},
+		// Renaming onto an existing key requires --force.
+		{[]string{"rename", "a/1", "b/1"}, []string{"a/1", "a/2", "b/1"}, true},
+		{[]string{"rename", "--force", "a/1", "b/1"}, []string{"a/2", "b/1"}, false},
+		// Keys under the old prefix do not count as existing.
+		{[]string{"rename", "a/", "a/x"}, []string{"a/x1", "a/x2", "b/1"}, false},
+		{[]string{"rename", "x", "y"}, []string{"a/1", "a/2", "b/1"}, true},
+		{[]string{"rename", "--dry-run", "a/", "c/"}, []string{"a/1", "a/2", "b/1"}, false},
+	}
+	for _, tc := range cases {
+		dbpath := newTestDB(t, "a/1", "a/2", "b/1")
+		_, err := runApp(t, append([]string{"-d", dbpath}, tc.args...)...)
+		if tc.wantErr && err == nil {
+			t.Errorf("%q should fail", tc.args)
+		} else if !tc.wantErr && err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.args, err)
+		}
+		if got := dbKeys(t, dbpath); !slices.Equal(got, tc.want) {
+			t.Errorf("%q: keys = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+
+	// The value moves with the key.
+	dbpath := newTestDB(t, "a/1", "b/1")
+	if _, err := runApp(t, "-d", dbpath, "rename", "-f", "a/1", "b/1"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runApp(t, "-d", dbpath, "get", "b/1")
+	if err != nil {
+		t.Fatal(err)
+	} else if out != "a/1" {
+		t.Errorf("value of b/1 = %q, want %q", out, "a/1")
+	}
+}
+
+func TestClearCmd(t *testing.T) {
+	cases := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"clear"}, nil},
+		{[]string{"clear", "--prefix", "a/"}, []string{"a", "b/1", "c"}},
+		{[]string{"clear", "--start", "a/2", "--end", "c"}, []string{"a", "a/1", "c"}},
+		{[]string{"clear", "--dry-run"}, []string{"a", "a/1", "a/2", "b/1", "c"}},
+		{[]string{"clear", "--dry-run", "--prefix", "a/"}, []string{"a", "a/1", "a/2", "b/1", "c"}},
+	}
+	for _, tc := range cases {
+		dbpath := newTestDB(t, "a", "a/1", "a/2", "b/1", "c")
+		if _, err := runApp(t, append([]string{"-d", dbpath}, tc.args...)...); err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.args, err)
+			continue
+		}
+		if got := dbKeys(t, dbpath); !slices.Equal(got, tc.want) {
+			t.Errorf("%q: keys = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+
+	dbpath := newTestDB(t, "a", "a/1", "a/2", "b/1")
+	out, err := runApp(t, "-d", dbpath, "clear", "--dry-run", "--prefix", "a/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Would delete \"a/1\"\nWould delete \"a/2\"\n"; out != want {
+		t.Errorf("clear --dry-run --prefix a/ printed %q, want %q", out, want)
+	}
+}
+
+// countdownContext is cancelled after its Err method has been called n
+// times.
+type countdownContext struct {
+	context.Context
+	n *int
+}
+
+func (ctx countdownContext) Err() error {
+	if *ctx.n--; *ctx.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestClearCmdInterrupted(t *testing.T) {
+	keys := make([]string, 2500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%04d", i)
+	}
+	dbpath := newTestDB(t, keys...)
+
+	// Interrupted after the first batch of 1000 entries has been written.
+	n := 1500
+	_, err := runAppContext(t, countdownContext{context.Background(), &n}, "-d", dbpath, "clear")
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("clear: error = %v, want %v", err, errInterrupted)
+	}
+	if want := "1000 entries were already deleted"; !strings.Contains(err.Error(), want) {
+		t.Errorf("clear: error %q does not contain %q", err, want)
+	}
+	if got := len(dbKeys(t, dbpath)); got != 1500 {
+		t.Errorf("clear: %d keys left, want 1500", got)
+	}
+}
+
+func TestDeleteStdinInterrupted(t *testing.T) {
+	keys := make([]string, 2500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%04d", i)
+	}
+	dbpath := newTestDB(t, keys...)
+
+	stdin, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	if _, err := stdin.WriteString(strings.Join(keys, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stdin.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interrupted after the first batch of 1000 keys has been written.
+	n := 1500
+	func(orig *os.File) {
+		defer func() { os.Stdin = orig }()
+		os.Stdin = stdin
+		_, err = runAppContext(t, countdownContext{context.Background(), &n}, "-d", dbpath, "delete", "--stdin")
+	}(os.Stdin)
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("delete --stdin: error = %v, want %v", err, errInterrupted)
+	}
+	if want := "the first 1000 of 2500 keys were already deleted"; !strings.Contains(err.Error(), want) {
+		t.Errorf("delete --stdin: error %q does not contain %q", err, want)
+	}
+	if got := dbKeys(t, dbpath); len(got) != 1500 {
+		t.Errorf("delete --stdin: %d keys left, want 1500", len(got))
+	} else if got[0] != "key1000" {
+		t.Errorf("delete --stdin: first key left is %q, want %q", got[0], "key1000")
+	}
+}
+
+// internalKeyComparer orders internal keys by user key, then from the
+// newest version, as goleveldb does in tables.
+type internalKeyComparer struct {
+	comparer.Comparer
+}
+
+func (internalKeyComparer) Compare(a, b []byte) int {
+	if c := bytes.Compare(a[:len(a)-8], b[:len(b)-8]); c != 0 {
+		return c
+	}
+	return cmp.Compare(binary.LittleEndian.Uint64(b[len(b)-8:]), binary.LittleEndian.Uint64(a[len(a)-8:]))
+}
+
+func TestSSTVersions(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "000001.ldb")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ikey := func(key string, seq uint64, kind byte) []byte {
+		return binary.LittleEndian.AppendUint64([]byte(key), seq<<8|uint64(kind))
+	}
+	w := table.NewWriter(f, &opt.Options{Comparer: internalKeyComparer{comparer.DefaultComparer}}, nil, 0)
+	for _, e := range []struct {
+		key   []byte
+		value string
+	}{
+		{ikey("a", 5, 1), "a2"},
+		{ikey("a", 3, 1), "a1"},
+		{ikey("b", 4, 0), ""},
+		{ikey("b", 2, 1), "b1"},
+		{ikey("c", 1, 1), "c1"},
+	} {
+		if err := w.Append(e.key, []byte(e.value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "a: a2\nc: c1\n"},
+		{[]string{"--all-versions"}, "a: a2\na: a1\nb: b1\nc: c1\n"},
+		{[]string{"--min-sequence", "3"}, "a: a2\n"},
+		{[]string{"--min-sequence", "3", "--all-versions"}, "a: a2\na: a1\n"},
+	}
+	for _, tc := range cases {
+		args := append(append([]string{"sst", "-r"}, tc.args...), name)
+		out, err := runApp(t, args...)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", args, err)
+		} else if out != tc.want {
+			t.Errorf("%q = %q, want %q", args, out, tc.want)
+		}
+	}
+}
+
+func TestEndInclusive(t *testing.T) {
+	dbpath := newTestDB(t, "a", "a/1", "a/2", "b")
+	// The end key is included, but not the keys it is a strict prefix of.
+	out, err := runApp(t, "-d", dbpath, "keys", "--end", "a", "--end-inclusive")
+	if err != nil {
+		t.Fatalf("keys: unexpected error: %v", err)
+	}
+	if want := "a\n"; out != want {
+		t.Errorf("keys --end a --end-inclusive = %q, want %q", out, want)
+	}
+}
+
+func TestExclude(t *testing.T) {
+	dbpath := newTestDB(t, "a/1", "a/2", "a/tmp", "a/x/1", "b/1")
+	args := []string{"--prefix", "a/", "--exclude", "tmp$", "--exclude", "^a/x/"}
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"keys", "a/1\na/2\n"},
+		{"show", "\"a/1\": \"a/1\"\n\"a/2\": \"a/2\"\n"},
+	}
+	for _, tc := range cases {
+		out, err := runApp(t, append([]string{"-d", dbpath, tc.cmd}, args...)...)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.cmd, err)
+		} else if out != tc.want {
+			t.Errorf("%s %q = %q, want %q", tc.cmd, args, out, tc.want)
+		}
+	}
+
+	if _, err := runApp(t, "-d", dbpath, "keys", "--exclude", "("); err == nil {
+		t.Error("keys --exclude '(': expected an error")
+	}
+}
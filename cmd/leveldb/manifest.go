@@ -0,0 +1,207 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/journal"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+const (
+	manifestComparer       = 1
+	manifestJournalNum     = 2
+	manifestNextFileNum    = 3
+	manifestSeqNum         = 4
+	manifestCompPtr        = 5
+	manifestDelTable       = 6
+	manifestAddTable       = 7
+	manifestPrevJournalNum = 9
+)
+
+// manifestMaxLevels bounds the level numbers accepted from a MANIFEST.
+// goleveldb adds levels as needed, but a real database never gets close.
+const manifestMaxLevels = 32
+
+type levelInfo struct {
+	Files int
+	Size  int64
+}
+
+type manifestInfo struct {
+	Name           string
+	Comparer       string
+	JournalNum     uint64
+	PrevJournalNum uint64
+	NextFileNum    uint64
+	LastSequence   uint64
+	Levels         []levelInfo
+}
+
+type manifestParser struct {
+	info   *manifestInfo
+	tables []map[uint64]int64
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// level returns the tables of a level. Level numbers come from the file,
+// so they are bounded before the levels are allocated.
+func (p *manifestParser) level(level uint64) (map[uint64]int64, error) {
+	if level >= manifestMaxLevels {
+		return nil, lerrors.NewErrCorrupted(storage.FileDesc{}, &leveldb.ErrManifestCorrupted{
+			Field:  "level",
+			Reason: fmt.Sprintf("invalid level number %d", level),
+		})
+	}
+	for uint64(len(p.tables)) <= level {
+		p.tables = append(p.tables, make(map[uint64]int64))
+	}
+	return p.tables[level], nil
+}
+
+func (p *manifestParser) parseRecord(b []byte) error {
+	r := bytes.NewReader(b)
+	for r.Len() > 0 {
+		tag, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case manifestComparer:
+			name, err := readBytes(r)
+			if err != nil {
+				return err
+			}
+			p.info.Comparer = string(name)
+		case manifestJournalNum:
+			if p.info.JournalNum, err = binary.ReadUvarint(r); err != nil {
+				return err
+			}
+		case manifestPrevJournalNum:
+			if p.info.PrevJournalNum, err = binary.ReadUvarint(r); err != nil {
+				return err
+			}
+		case manifestNextFileNum:
+			if p.info.NextFileNum, err = binary.ReadUvarint(r); err != nil {
+				return err
+			}
+		case manifestSeqNum:
+			if p.info.LastSequence, err = binary.ReadUvarint(r); err != nil {
+				return err
+			}
+		case manifestCompPtr:
+			if _, err := binary.ReadUvarint(r); err != nil {
+				return err
+			}
+			if _, err := readBytes(r); err != nil {
+				return err
+			}
+		case manifestDelTable:
+			level, err := binary.ReadUvarint(r)
+			if err != nil {
+				return err
+			}
+			num, err := binary.ReadUvarint(r)
+			if err != nil {
+				return err
+			}
+			tables, err := p.level(level)
+			if err != nil {
+				return err
+			}
+			delete(tables, num)
+		case manifestAddTable:
+			var fields [3]uint64
+			for i := range fields {
+				if fields[i], err = binary.ReadUvarint(r); err != nil {
+					return err
+				}
+			}
+			for range 2 {
+				if _, err := readBytes(r); err != nil {
+					return err
+				}
+			}
+			tables, err := p.level(fields[0])
+			if err != nil {
+				return err
+			}
+			tables[fields[1]] = int64(fields[2])
+		default:
+			return fmt.Errorf("unknown field %d", tag)
+		}
+	}
+	return nil
+}
+
+// readManifest reads the current MANIFEST file of the database at dbpath
+// without opening the database.
+func readManifest(dbpath string) (*manifestInfo, error) {
+	current, err := os.ReadFile(filepath.Join(dbpath, "CURRENT"))
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(string(current), "\n")
+	if !strings.HasPrefix(name, "MANIFEST-") || strings.ContainsAny(name, "/\\") {
+		return nil, fmt.Errorf("CURRENT: invalid manifest name %q", name)
+	}
+
+	f, err := os.Open(filepath.Join(dbpath, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &manifestParser{info: &manifestInfo{Name: name}}
+	jr := journal.NewReader(f, nil, true, true)
+	for nrecords := 1; ; nrecords++ {
+		r, err := jr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := p.parseRecord(b); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w", name, nrecords, err)
+		}
+	}
+
+	for _, tables := range p.tables {
+		var li levelInfo
+		for _, size := range tables {
+			li.Files++
+			li.Size += size
+		}
+		p.info.Levels = append(p.info.Levels, li)
+	}
+	return p.info, nil
+}
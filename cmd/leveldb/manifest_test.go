@@ -0,0 +1,100 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/journal"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestReadManifest(t *testing.T) {
+	dbpath := t.TempDir()
+
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 100 {
+		if err := db.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("value"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.CompactRange(util.Range{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := readManifest(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(info.Name, "MANIFEST-") {
+		t.Errorf("Name = %q, want MANIFEST-*", info.Name)
+	}
+	if info.Comparer != "leveldb.BytewiseComparator" {
+		t.Errorf("Comparer = %q, want %q", info.Comparer, "leveldb.BytewiseComparator")
+	}
+	if info.LastSequence < 100 {
+		t.Errorf("LastSequence = %d, want >= 100", info.LastSequence)
+	}
+	files := 0
+	for _, li := range info.Levels {
+		files += li.Files
+		if li.Files > 0 && li.Size <= 0 {
+			t.Errorf("level with %d files has size %d", li.Files, li.Size)
+		}
+	}
+	if files == 0 {
+		t.Error("no tables found")
+	}
+}
+
+func TestReadManifestInvalidLevel(t *testing.T) {
+	dbpath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dbpath, "CURRENT"), []byte("MANIFEST-000001\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dbpath, "MANIFEST-000001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// An add-table record for level 2^40.
+	var rec []byte
+	for _, x := range []uint64{manifestAddTable, 1 << 40, 5, 100} {
+		rec = binary.AppendUvarint(rec, x)
+	}
+	rec = append(rec, 1, 'a', 1, 'b')
+
+	jw := journal.NewWriter(f)
+	w, err := jw.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := jw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = readManifest(dbpath)
+	var corrupted *lerrors.ErrCorrupted
+	if !errors.As(err, &corrupted) {
+		t.Errorf("readManifest: got error %v, want a corruption error", err)
+	}
+}
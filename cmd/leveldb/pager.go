@@ -0,0 +1,91 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+type pager struct {
+	cmd    *exec.Cmd
+	w      *os.File
+	stdout *os.File
+	output io.Writer
+}
+
+// startPager starts $PAGER (or less) and redirects the standard output to it.
+func startPager() (*pager, error) {
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = []string{"less"}
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		// -R passes through the color escape sequences.
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = r
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return nil, err
+	}
+	r.Close()
+
+	p := &pager{cmd: cmd, w: w, stdout: os.Stdout, output: color.Output}
+	os.Stdout = w
+	color.Output = w
+	return p, nil
+}
+
+// Close restores the standard output and waits for the pager to exit.
+func (p *pager) Close() error {
+	os.Stdout = p.stdout
+	color.Output = p.output
+	if err := p.w.Close(); err != nil {
+		return err
+	}
+	return p.cmd.Wait()
+}
+
+// pagedAction runs action with its output piped to a pager if --pager is
+// given and the standard output is a terminal.
+func pagedAction(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if !c.Bool("pager") || !isTerminal(os.Stdout) {
+			return action(c)
+		}
+
+		p, err := startPager()
+		if err != nil {
+			return err
+		}
+		err = action(c)
+		if errors.Is(err, syscall.EPIPE) {
+			// The user quit the pager before reading all output.
+			err = nil
+		}
+		if perr := p.Close(); err == nil {
+			err = perr
+		}
+		return err
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiling starts writing a CPU profile to cpuFile and returns a
+// function that stops it and writes a heap profile to memFile. Either
+// name may be empty.
+func startProfiling(cpuFile, memFile string) (func() error, error) {
+	var cpu *os.File
+	if cpuFile != "" {
+		fh, err := os.Create(cpuFile)
+		if err != nil {
+			return nil, fmt.Errorf("option --cpuprofile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(fh); err != nil {
+			fh.Close()
+			return nil, fmt.Errorf("option --cpuprofile: %w", err)
+		}
+		cpu = fh
+	}
+
+	stop := func() error {
+		var errs []error
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			errs = append(errs, cpu.Close())
+		}
+		if memFile != "" {
+			errs = append(errs, writeHeapProfile(memFile))
+		}
+		return errors.Join(errs...)
+	}
+	return stop, nil
+}
+
+func writeHeapProfile(name string) error {
+	fh, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("option --memprofile: %w", err)
+	}
+	// Collect garbage so that the profile shows live objects only.
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(fh); err != nil {
+		fh.Close()
+		return fmt.Errorf("option --memprofile: %w", err)
+	}
+	return fh.Close()
+}
@@ -0,0 +1,388 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/cions/leveldb-cli/format"
+	"github.com/fatih/color"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/urfave/cli/v2"
+)
+
+const replHelp = `Commands:
+  get <key>               print the value for the given key
+  put <key> <value>       set the value for the given key
+  delete <key>...         delete the given keys
+  keys [<prefix>]         list keys
+  show [<prefix>]         show entries
+  scan <prefix>           show entries with the given prefix
+  history                 list previous command lines
+  !!                      repeat the previous command line
+  !<n>                    repeat command line <n> of the history
+  help                    show this help
+  quit                    exit the shell
+
+Ctrl-C aborts the running command and returns to the prompt. --timeout
+limits each command rather than the session.
+Arguments are separated by whitespace and may be quoted with '' or "".
+Keys and values are decoded as in other commands, following --raw and
+--base64. For line editing, run the shell under a wrapper such as rlwrap.
+`
+
+var errQuit = errors.New("quit")
+
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur []byte
+	inArg := false
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote != '\'' && ch == '\\':
+			if i+1 == len(line) {
+				return nil, errors.New("truncated backslash escape")
+			}
+			cur = append(cur, ch, line[i+1])
+			inArg = true
+			i++
+		case quote != 0:
+			cur = append(cur, ch)
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inArg = true
+		case ch == ' ' || ch == '\t':
+			if inArg {
+				args = append(args, string(cur))
+				cur = cur[:0]
+				inArg = false
+			}
+		default:
+			cur = append(cur, ch)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quoted string")
+	}
+	if inArg {
+		args = append(args, string(cur))
+	}
+	return args, nil
+}
+
+type repl struct {
+	c          *cli.Context
+	db         *leveldb.DB
+	history    []string
+	histw      io.Writer
+	interrupts chan os.Signal
+}
+
+// expandHistory replaces a line of the form !! or !<n> with the previous
+// or the nth line of the history.
+func (r *repl) expandHistory(line string) (string, error) {
+	event := strings.TrimSpace(line)
+	if !strings.HasPrefix(event, "!") {
+		return line, nil
+	}
+	if event == "!!" {
+		if len(r.history) == 0 {
+			return "", errors.New("!!: history is empty")
+		}
+		return r.history[len(r.history)-1], nil
+	}
+	n, err := strconv.Atoi(event[1:])
+	if err != nil || n < 1 || n > len(r.history) {
+		return "", fmt.Errorf("%s: event not found", event)
+	}
+	return r.history[n-1], nil
+}
+
+// addHistory appends a line to the history unless it repeats the previous
+// one, and saves it to the history file if there is one.
+func (r *repl) addHistory(line string) error {
+	if len(r.history) > 0 && r.history[len(r.history)-1] == line {
+		return nil
+	}
+	r.history = append(r.history, line)
+	if r.histw != nil {
+		if _, err := fmt.Fprintln(r.histw, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repl) printHistory(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: history")
+	}
+	for i, line := range r.history {
+		fmt.Printf("%5d  %s\n", i+1, line)
+	}
+	return nil
+}
+
+func (r *repl) prefixRange(args []string) (*util.Range, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	prefix, err := decodeArg(r.c, []byte(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	return getPrefixRange(r.c, prefix), nil
+}
+
+func (r *repl) get(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: get <key>")
+	}
+	key, err := decodeArg(r.c, []byte(args[0]))
+	if err != nil {
+		return err
+	}
+	value, err := r.db.Get(key, nil)
+	if err != nil {
+		return err
+	}
+	format.NewFormatter(color.Output).SetParseJSON(true).Write(value)
+	fmt.Println()
+	return nil
+}
+
+func (r *repl) put(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: put <key> <value>")
+	}
+	key, err := decodeArg(r.c, []byte(args[0]))
+	if err != nil {
+		return err
+	}
+	value, err := decodeArg(r.c, []byte(args[1]))
+	if err != nil {
+		return err
+	}
+	return r.db.Put(key, value, nil)
+}
+
+func (r *repl) delete(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: delete <key>...")
+	}
+	batch := new(leveldb.Batch)
+	for _, arg := range args {
+		key, err := decodeArg(r.c, []byte(arg))
+		if err != nil {
+			return err
+		}
+		batch.Delete(key)
+	}
+	return r.db.Write(batch, nil)
+}
+
+func (r *repl) scan(ctx context.Context, args []string, keysOnly bool) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments")
+	}
+	slice, err := r.prefixRange(args)
+	if err != nil {
+		return err
+	}
+
+	kw := format.NewFormatter(color.Output).SetQuoting(!keysOnly)
+	vw := format.NewFormatter(color.Output).
+		SetQuoting(true).
+		SetTruncate(format.DefaultTruncate).
+		SetParseJSON(true)
+
+	iter := r.db.NewIterator(slice, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		kw.Write(iter.Key())
+		if !keysOnly {
+			fmt.Print(": ")
+			vw.Write(iter.Value())
+		}
+		fmt.Println()
+	}
+	return iter.Error()
+}
+
+func (r *repl) exec(ctx context.Context, args []string) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "get":
+		return r.get(args[1:])
+	case "put":
+		return r.put(args[1:])
+	case "delete":
+		return r.delete(args[1:])
+	case "keys":
+		return r.scan(ctx, args[1:], true)
+	case "show":
+		return r.scan(ctx, args[1:], false)
+	case "scan":
+		if len(args) != 2 {
+			return errors.New("usage: scan <prefix>")
+		}
+		return r.scan(ctx, args[1:], false)
+	case "history":
+		return r.printHistory(args[1:])
+	case "help", "?":
+		fmt.Print(replHelp)
+		return nil
+	case "quit", "exit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %q (type \"help\" for help)", args[0])
+	}
+}
+
+// run runs a command. An interrupt received while it runs, or the expiry
+// of --timeout, aborts the command, but not the session.
+func (r *repl) run(ctx context.Context, args []string) error {
+	// Interrupts received at the prompt have nothing to abort.
+	for len(r.interrupts) > 0 {
+		<-r.interrupts
+	}
+
+	var cancel context.CancelFunc
+	if timeout := r.c.Duration("timeout"); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-r.interrupts:
+			cancel()
+		case <-done:
+		}
+	}()
+	return r.exec(ctx, args)
+}
+
+// readHistory reads the lines of a history file. A missing file is an
+// empty history.
+func readHistory(name string) ([]string, error) {
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var history []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history, nil
+}
+
+func replCmd(c *cli.Context) error {
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = c.Bool("read-only")
+	db, err := openDB(c.String("dbpath"), o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The shell handles interrupts and --timeout for each command, so the
+	// session does not end with the context of the repl command.
+	ctx := context.WithoutCancel(c.Context)
+	r := &repl{c: c, db: db, interrupts: make(chan os.Signal, 1)}
+	signal.Notify(r.interrupts, os.Interrupt)
+	defer signal.Stop(r.interrupts)
+
+	if name := c.String("history-file"); name != "" {
+		if r.history, err = readHistory(name); err != nil {
+			return fmt.Errorf("option --history-file: %w", err)
+		}
+		fh, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return fmt.Errorf("option --history-file: %w", err)
+		}
+		defer fh.Close()
+		r.histw = fh
+	}
+	interactive := isTerminal(os.Stdin)
+
+	sc := bufio.NewScanner(os.Stdin)
+	sc.Buffer(nil, 64*1024*1024)
+	for {
+		if interactive {
+			fmt.Fprint(os.Stderr, "leveldb> ")
+		}
+		if !sc.Scan() {
+			if interactive {
+				fmt.Fprintln(os.Stderr)
+			}
+			break
+		}
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		line, err := r.expandHistory(sc.Text())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "leveldb: error: %v\n", err)
+			continue
+		}
+		if line != sc.Text() {
+			fmt.Fprintln(os.Stderr, line)
+		}
+		if strings.TrimSpace(line) != "" {
+			if err := r.addHistory(line); err != nil {
+				return fmt.Errorf("option --history-file: %w", err)
+			}
+		}
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "leveldb: error: %v\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if err := r.run(ctx, args); errors.Is(err, errQuit) {
+			break
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "leveldb: error: %v\n", err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/urfave/cli/v2"
+)
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
+
+func TestSplitArgs(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{``, nil, false},
+		{`  `, nil, false},
+		{`get key`, []string{"get", "key"}, false},
+		{`  put  a   b  `, []string{"put", "a", "b"}, false},
+		{`put "a b" 'c d'`, []string{"put", "a b", "c d"}, false},
+		{`put "" ''`, []string{"put", "", ""}, false},
+		{`put a"b"'c'`, []string{"put", "abc"}, false},
+		{`put a\ b`, []string{"put", `a\ b`}, false},
+		{`put "a\"b" 'a\b'`, []string{"put", `a\"b`, `a\b`}, false},
+		{`put \x00\n`, []string{"put", `\x00\n`}, false},
+		{`put "a`, nil, true},
+		{`put a\`, nil, true},
+	}
+
+	for _, tc := range cases {
+		got, err := splitArgs(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("splitArgs(%q) should fail", tc.input)
+			}
+		} else if err != nil {
+			t.Errorf("splitArgs(%q): unexpected error: %v", tc.input, err)
+		} else if !slices.Equal(got, tc.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestReplHistory(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(name, []byte("get a\n\nkeys\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	history, err := readHistory(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &repl{history: history}
+	if err := r.addHistory("keys"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.addHistory("put a b"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"get a", "keys", "put a b"}; !slices.Equal(r.history, want) {
+		t.Errorf("history = %q, want %q", r.history, want)
+	}
+
+	cases := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{`get b`, `get b`, false},
+		{`  get !b`, `  get !b`, false},
+		{`!!`, `put a b`, false},
+		{` !1 `, `get a`, false},
+		{`!3`, `put a b`, false},
+		{`!0`, ``, true},
+		{`!4`, ``, true},
+		{`!x`, ``, true},
+	}
+	for _, tc := range cases {
+		got, err := r.expandHistory(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("expandHistory(%q) should fail", tc.input)
+			}
+		} else if err != nil {
+			t.Errorf("expandHistory(%q): unexpected error: %v", tc.input, err)
+		} else if got != tc.want {
+			t.Errorf("expandHistory(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestReplDecodesArgs(t *testing.T) {
+	db, err := leveldb.OpenFile(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	flags := []cli.Flag{&cli.BoolFlag{Name: "raw"}, &cli.BoolFlag{Name: "base64"}}
+	cases := []struct {
+		flag       string
+		key, value string
+		want       []byte
+	}{
+		{"", `k\x00`, `v\n`, []byte("v\n")},
+		{"--raw", `k\x00`, `v\n`, []byte(`v\n`)},
+		{"--base64", `awA=`, `dgo=`, []byte("v\n")},
+	}
+	for _, tc := range cases {
+		var args []string
+		if tc.flag != "" {
+			args = append(args, tc.flag)
+		}
+		r := &repl{c: newTestContext(t, flags, args...), db: db}
+		if err := r.put([]string{tc.key, tc.value}); err != nil {
+			t.Errorf("%s: put: unexpected error: %v", tc.flag, err)
+			continue
+		}
+		key := []byte("k\x00")
+		if tc.flag == "--raw" {
+			key = []byte(`k\x00`)
+		}
+		if got, err := db.Get(key, nil); err != nil {
+			t.Errorf("%s: Get(%q): unexpected error: %v", tc.flag, key, err)
+		} else if !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: Get(%q) = %q, want %q", tc.flag, key, got, tc.want)
+		}
+	}
+}
+
+func TestReplInterrupt(t *testing.T) {
+	db, err := leveldb.OpenFile(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := range 1000 {
+		if err := db.Put([]byte(fmt.Sprintf("key%04d", i)), []byte("v"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+	defer func(stdout *os.File, output io.Writer) { os.Stdout, color.Output = stdout, output }(os.Stdout, color.Output)
+	os.Stdout = devnull
+
+	// Interrupt the scan when it prints the first key.
+	r := &repl{c: newTestContext(t, nil), db: db, interrupts: make(chan os.Signal, 1)}
+	nkeys, interrupt := 0, true
+	color.Output = writerFunc(func(b []byte) (int, error) {
+		nkeys++
+		if interrupt {
+			interrupt = false
+			r.interrupts <- os.Interrupt
+			time.Sleep(10 * time.Millisecond)
+		}
+		return len(b), nil
+	})
+	if err := r.run(context.Background(), []string{"keys"}); !errors.Is(err, errInterrupted) {
+		t.Errorf("keys: error = %v, want %v", err, errInterrupted)
+	}
+	if nkeys >= 1000 {
+		t.Errorf("keys printed all %d keys after an interrupt", nkeys)
+	}
+
+	// The session goes on, and an interrupt received at the prompt does
+	// not abort the next command.
+	r.interrupts <- os.Interrupt
+	nkeys = 0
+	if err := r.run(context.Background(), []string{"keys", "key09"}); err != nil {
+		t.Errorf("keys after an interrupt: unexpected error: %v", err)
+	}
+	if nkeys != 100 {
+		t.Errorf("keys after an interrupt printed %d keys, want 100", nkeys)
+	}
+}
+
+func TestReplTimeout(t *testing.T) {
+	cases := []struct {
+		timeout string
+		want    []string
+	}{
+		// Each command times out, but the session does not.
+		{"1ns", nil},
+		{"1h", []string{"a", "c"}},
+	}
+	for _, tc := range cases {
+		stdin, err := os.CreateTemp(t.TempDir(), "stdin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stdin.Close()
+		if _, err := stdin.WriteString("put a b\nput c d\n"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stdin.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+
+		dbpath := newTestDB(t)
+		func(orig *os.File) {
+			defer func() { os.Stdin = orig }()
+			os.Stdin = stdin
+			_, err = runApp(t, "-d", dbpath, "--timeout", tc.timeout, "repl")
+		}(os.Stdin)
+		if err != nil {
+			t.Errorf("--timeout %s: unexpected error: %v", tc.timeout, err)
+		}
+		if got := dbKeys(t, dbpath); !slices.Equal(got, tc.want) {
+			t.Errorf("--timeout %s: keys = %q, want %q", tc.timeout, got, tc.want)
+		}
+	}
+}
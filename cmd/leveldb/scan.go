@@ -0,0 +1,170 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/urfave/cli/v2"
+)
+
+// errStopScan is returned by visit functions to end a scan early.
+var errStopScan = errors.New("stop scan")
+
+// dbScanner reads the entries in a key range of a database opened
+// read-only. Read commands configure one and supply a visit function
+// instead of opening and iterating the database themselves.
+type dbScanner struct {
+	Options *opt.Options
+	Range   *util.Range
+	// NoSnapshot reads from the database directly instead of from a
+	// snapshot.
+	NoSnapshot bool
+	// WrapIterator, if not nil, wraps the iterator over Range.
+	WrapIterator func(iterator.Iterator) iterator.Iterator
+	// Finish, if not nil, is called after the last entry while the
+	// database is still open.
+	Finish func(db *leveldb.DB) error
+	// ReadAhead reads the table files in the background during the scan
+	// so that the iterator finds them in the OS page cache.
+	ReadAhead bool
+}
+
+// newDBScanner returns a scanner over slice configured by the global
+// options and --no-snapshot.
+func newDBScanner(c *cli.Context, slice *util.Range) *dbScanner {
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = true
+	return &dbScanner{
+		Options:    o,
+		Range:      slice,
+		NoSnapshot: c.Bool("no-snapshot"),
+		ReadAhead:  c.Bool("read-ahead"),
+	}
+}
+
+// readTables reads the table files of the database at dbpath and discards
+// their contents until done is closed. It returns the number of bytes read.
+func readTables(done <-chan struct{}, dbpath string) int64 {
+	entries, err := os.ReadDir(dbpath)
+	if err != nil {
+		return 0
+	}
+	buf := make([]byte, 1<<20)
+	var nread int64
+	for _, e := range entries {
+		if !tableFilenamePattern.MatchString(e.Name()) {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dbpath, e.Name()))
+		if err != nil {
+			continue
+		}
+		for {
+			select {
+			case <-done:
+				f.Close()
+				return nread
+			default:
+			}
+			n, err := f.Read(buf)
+			nread += int64(n)
+			if err != nil {
+				break
+			}
+		}
+		f.Close()
+	}
+	return nread
+}
+
+// readAhead runs readTables in the background. The returned function
+// stops it and returns the number of bytes read.
+func readAhead(dbpath string) func() int64 {
+	done := make(chan struct{})
+	result := make(chan int64, 1)
+	go func() {
+		result <- readTables(done, dbpath)
+	}()
+	return func() int64 {
+		close(done)
+		return <-result
+	}
+}
+
+// Scan calls visit for each entry of the database at dbpath until visit
+// returns errStopScan. The slices passed to visit must not be retained
+// after it returns.
+func (s *dbScanner) Scan(ctx context.Context, dbpath string, visit func(key, value []byte) error) error {
+	db, err := openDB(dbpath, s.Options)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if s.ReadAhead {
+		stop := readAhead(dbpath)
+		defer func() {
+			logf("read ahead %d bytes of table files", stop())
+		}()
+	}
+
+	var r dbReader = db
+	release := func() {}
+	if !s.NoSnapshot {
+		snap, err := db.GetSnapshot()
+		if err != nil {
+			return err
+		}
+		r, release = snap, snap.Release
+	}
+	defer release()
+
+	start := time.Now()
+	nentries := 0
+	iter := r.NewIterator(s.Range, nil)
+	if s.WrapIterator != nil {
+		iter = s.WrapIterator(iter)
+	}
+	defer iter.Release()
+	for iter.Next() {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		nentries++
+		if err := visit(iter.Key(), iter.Value()); errors.Is(err, errStopScan) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	iter.Release()
+	release()
+	logPhase(fmt.Sprintf("scanning %d entries", nentries), start)
+	if props, err := runtimeProperties(db); err == nil {
+		for _, p := range props {
+			logf("%s: %s", p.Name, p.Value)
+		}
+	}
+
+	if s.Finish != nil {
+		if err := s.Finish(db); err != nil {
+			return err
+		}
+	}
+	return db.Close()
+}
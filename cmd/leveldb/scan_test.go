@@ -0,0 +1,109 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestDBScanner(t *testing.T) {
+	dir := t.TempDir()
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a/1", "a/2", "b/1", "c"} {
+		if err := db.Put([]byte(key), []byte("v"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, noSnapshot := range []bool{false, true} {
+		finished := false
+		s := &dbScanner{
+			Options:    &opt.Options{ErrorIfMissing: true, ReadOnly: true},
+			Range:      util.BytesPrefix([]byte("a/")),
+			NoSnapshot: noSnapshot,
+			Finish: func(db *leveldb.DB) error {
+				finished = true
+				return nil
+			},
+		}
+		var keys []string
+		err := s.Scan(context.Background(), dir, func(key, value []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Scan(NoSnapshot=%v): unexpected error: %v", noSnapshot, err)
+		}
+		if want := []string{"a/1", "a/2"}; !slices.Equal(keys, want) {
+			t.Errorf("Scan(NoSnapshot=%v) visited %q, want %q", noSnapshot, keys, want)
+		}
+		if !finished {
+			t.Errorf("Scan(NoSnapshot=%v) did not call Finish", noSnapshot)
+		}
+	}
+}
+
+func TestReadTables(t *testing.T) {
+	dir := t.TempDir()
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 1000 {
+		if err := db.Put([]byte(fmt.Sprintf("key%04d", i)), bytes.Repeat([]byte("v"), 100), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.CompactRange(util.Range{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var want int64
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if tableFilenamePattern.MatchString(e.Name()) {
+			info, err := e.Info()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want += info.Size()
+		}
+	}
+	if want == 0 {
+		t.Fatal("no table files")
+	}
+
+	if got := readTables(make(chan struct{}), dir); got != want {
+		t.Errorf("readTables read %d bytes, want %d", got, want)
+	}
+	done := make(chan struct{})
+	close(done)
+	if got := readTables(done, dir); got != 0 {
+		t.Errorf("readTables(closed) read %d bytes, want 0", got)
+	}
+	if got := readAhead(dir)(); got < 0 || got > want {
+		t.Errorf("readAhead read %d bytes, want at most %d", got, want)
+	}
+}
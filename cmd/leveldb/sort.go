@@ -0,0 +1,62 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+type sortedEntry struct {
+	Target     dbTarget
+	Key, Value []byte
+}
+
+// entrySorter keeps the first limit entries in the order given to
+// newEntrySorter. It holds at most twice as many entries at a time.
+type entrySorter struct {
+	compare func(a, b sortedEntry) int
+	limit   int
+	entries []sortedEntry
+}
+
+// newEntrySorter returns a sorter for the given order: value sorts by
+// value in ascending order, value-length and key-length sort the longest
+// entries first. Entries that compare equal stay in scan order.
+func newEntrySorter(order string, limit int) (*entrySorter, error) {
+	s := &entrySorter{limit: limit}
+	switch order {
+	case "value":
+		s.compare = func(a, b sortedEntry) int { return bytes.Compare(a.Value, b.Value) }
+	case "value-length":
+		s.compare = func(a, b sortedEntry) int { return cmp.Compare(len(b.Value), len(a.Value)) }
+	case "key-length":
+		s.compare = func(a, b sortedEntry) int { return cmp.Compare(len(b.Key), len(a.Key)) }
+	default:
+		return nil, fmt.Errorf("unknown order %q (expected value, value-length or key-length)", order)
+	}
+	return s, nil
+}
+
+// Add adds a copy of an entry.
+func (s *entrySorter) Add(t dbTarget, key, value []byte) {
+	s.entries = append(s.entries, sortedEntry{t, bytes.Clone(key), bytes.Clone(value)})
+	if len(s.entries) >= 2*s.limit {
+		s.truncate()
+	}
+}
+
+func (s *entrySorter) truncate() {
+	slices.SortStableFunc(s.entries, s.compare)
+	clear(s.entries[min(s.limit, len(s.entries)):])
+	s.entries = s.entries[:min(s.limit, len(s.entries))]
+}
+
+// Entries returns the first limit entries in order.
+func (s *entrySorter) Entries() []sortedEntry {
+	s.truncate()
+	return s.entries
+}
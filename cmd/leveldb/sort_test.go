@@ -0,0 +1,44 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEntrySorter(t *testing.T) {
+	entries := []struct{ key, value string }{
+		{"a", "ccc"}, {"bb", "a"}, {"c", "bb"}, {"dddd", ""}, {"e", "ddd"}, {"f", "b"},
+	}
+	for _, tc := range []struct {
+		order string
+		limit int
+		want  []string
+	}{
+		{"value", 3, []string{"dddd", "bb", "f"}},
+		{"value-length", 3, []string{"a", "e", "c"}},
+		{"key-length", 2, []string{"dddd", "bb"}},
+		{"value-length", 10, []string{"a", "e", "c", "bb", "f", "dddd"}},
+	} {
+		s, err := newEntrySorter(tc.order, tc.limit)
+		if err != nil {
+			t.Fatalf("newEntrySorter(%q): unexpected error: %v", tc.order, err)
+		}
+		for _, e := range entries {
+			s.Add(dbTarget{}, []byte(e.key), []byte(e.value))
+		}
+		var got []string
+		for _, e := range s.Entries() {
+			got = append(got, string(e.Key))
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s, limit %d: got %q, want %q", tc.order, tc.limit, got, tc.want)
+		}
+	}
+
+	if _, err := newEntrySorter("size", 1); err == nil {
+		t.Error("newEntrySorter(\"size\"): expected an error")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"encoding/hex"
+	"io"
+	"log"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// verboseLog receives the diagnostics enabled by --verbose. It discards
+// them by default.
+var verboseLog = log.New(io.Discard, "leveldb: debug: ", 0)
+
+func setVerbose(w io.Writer) {
+	verboseLog.SetOutput(w)
+}
+
+func logf(format string, args ...interface{}) {
+	verboseLog.Printf(format, args...)
+}
+
+// logOptions logs the options a database at dbpath is opened with.
+func logOptions(dbpath string, o *opt.Options) {
+	filter := "none"
+	if f := o.GetFilter(); f != nil {
+		filter = f.Name()
+	}
+	logf("opening %s (comparer %s, read-only %t, error-if-missing %t, block cache %d bytes, filter %s)",
+		dbpath, o.GetComparer().Name(), o.GetReadOnly(), o.GetErrorIfMissing(), o.GetBlockCacheCapacity(), filter)
+}
+
+// logRange logs a key range in hex.
+func logRange(slice *util.Range) {
+	bound := func(b []byte) string {
+		if b == nil {
+			return "(unbounded)"
+		}
+		return hex.EncodeToString(b)
+	}
+	logf("key range: start %s, limit %s", bound(slice.Start), bound(slice.Limit))
+}
+
+// logPhase logs the time since start for the named phase.
+func logPhase(name string, start time.Time) {
+	logf("%s took %v", name, time.Since(start).Round(time.Microsecond))
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestLogRange(t *testing.T) {
+	buf := new(bytes.Buffer)
+	setVerbose(buf)
+	defer setVerbose(io.Discard)
+
+	logRange(&util.Range{Start: []byte("a/")})
+	want := "leveldb: debug: key range: start 612f, limit (unbounded)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("logRange wrote %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/urfave/cli/v2"
+)
+
+// verifyRange reads every entry in slice with strict checksums. A
+// corrupted block ends the scan, so at most one error is returned.
+func verifyRange(ctx context.Context, s *leveldb.Snapshot, slice *util.Range, nentries *atomic.Int64) error {
+	iter := s.NewIterator(slice, &opt.ReadOptions{Strict: opt.StrictAll})
+	defer iter.Release()
+	for iter.Next() {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		_ = iter.Value()
+		nentries.Add(1)
+	}
+	return iter.Error()
+}
+
+func formatRange(slice *util.Range) string {
+	bound := func(b []byte) string {
+		if b == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%q", b)
+	}
+	return fmt.Sprintf("[%s, %s)", bound(slice.Start), bound(slice.Limit))
+}
+
+func verifyCmd(c *cli.Context) error {
+	concurrency := c.Int("concurrency")
+	if concurrency < 1 {
+		return fmt.Errorf("option --concurrency: must be a positive integer")
+	}
+
+	dbpath := c.String("dbpath")
+	o := getOptions(c)
+	o.ErrorIfMissing = true
+	o.ReadOnly = true
+	o.Strict = opt.StrictAll
+	db, err := openDB(dbpath, o)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s, err := db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer s.Release()
+
+	var splitKeys [][]byte
+	if concurrency > 1 {
+		// Sampling reads the first block of every table, so corruption may
+		// show up here; verify without splitting to report it per shard.
+		if splitKeys, err = sampleSplitKeys(dbpath, o, concurrency); err != nil {
+			fmt.Fprintf(os.Stderr, "leveldb: warning: cannot split the key space: %v\n", err)
+			splitKeys = nil
+		}
+	}
+	ranges := splitRanges(splitKeys)
+
+	var nentries atomic.Int64
+	done := make(chan struct{})
+	var progress sync.WaitGroup
+	if isTerminal(os.Stderr) {
+		progress.Add(1)
+		go func() {
+			defer progress.Done()
+			ticker := time.NewTicker(500 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					fmt.Fprint(os.Stderr, "\r\x1b[K")
+					return
+				case <-ticker.C:
+					fmt.Fprintf(os.Stderr, "\rverified %d entries", nentries.Load())
+				}
+			}
+		}()
+	}
+
+	errs := make([]error, len(ranges))
+	var wg sync.WaitGroup
+	for i, slice := range ranges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = verifyRange(c.Context, s, slice, &nentries)
+		}()
+	}
+	wg.Wait()
+	close(done)
+	progress.Wait()
+
+	nfailed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if err := checkContext(c.Context); err != nil {
+			return err
+		}
+		nfailed++
+		fmt.Fprintf(os.Stderr, "shard %d/%d %s: %v\n", i+1, len(ranges), formatRange(ranges[i]), err)
+	}
+	if nfailed > 0 {
+		return fmt.Errorf("verification failed in %d of %d shards", nfailed, len(ranges))
+	}
+
+	fmt.Printf("OK: %d entries in %d shards\n", nentries.Load(), len(ranges))
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestVerifyRange(t *testing.T) {
+	dbpath := t.TempDir()
+	db, err := leveldb.OpenFile(dbpath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := bytes.Repeat([]byte("v"), 100)
+	for i := range 1000 {
+		if err := db.Put([]byte(fmt.Sprintf("key%04d", i)), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.CompactRange(util.Range{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func() (int64, []error) {
+		db, err := leveldb.OpenFile(dbpath, &opt.Options{ReadOnly: true, Strict: opt.StrictAll})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		s, err := db.GetSnapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Release()
+
+		var nentries atomic.Int64
+		var errs []error
+		for _, slice := range splitRanges([][]byte{[]byte("key0250"), []byte("key0500")}) {
+			if err := verifyRange(context.Background(), s, slice, &nentries); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return nentries.Load(), errs
+	}
+
+	if n, errs := verify(); len(errs) > 0 {
+		t.Errorf("verifyRange: unexpected errors: %v", errs)
+	} else if n != 1000 {
+		t.Errorf("verifyRange: verified %d entries, want 1000", n)
+	}
+
+	tables, err := filepath.Glob(filepath.Join(dbpath, "*.ldb"))
+	if err != nil || len(tables) == 0 {
+		t.Fatalf("no tables found: %v", err)
+	}
+	data, err := os.ReadFile(tables[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/3] ^= 0xff
+	if err := os.WriteFile(tables[0], data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, errs := verify(); len(errs) == 0 {
+		t.Error("verifyRange: expected a corruption error")
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+// Package format formats binary keys and values as escaped, human-readable
+// text.
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// EscapeStyle selects how unprintable characters are escaped.
+type EscapeStyle int
+
+const (
+	GoEscape EscapeStyle = iota
+	JSONEscape
+	CEscape
+)
+
+// ParseEscapeStyle parses an escape style name: go, json or c.
+func ParseEscapeStyle(s string) (EscapeStyle, error) {
+	switch s {
+	case "go":
+		return GoEscape, nil
+	case "json":
+		return JSONEscape, nil
+	case "c":
+		return CEscape, nil
+	default:
+		return 0, fmt.Errorf("unknown escape style %q", s)
+	}
+}
+
+var controlEscapes = map[rune]string{
+	'\a': `\a`,
+	'\b': `\b`,
+	'\f': `\f`,
+	'\n': `\n`,
+	'\r': `\r`,
+	'\t': `\t`,
+	'\v': `\v`,
+}
+
+// DefaultTruncate is the truncation width used for interactive output.
+const DefaultTruncate = 250
+
+// Formatter is an io.Writer that writes each byte slice as escaped,
+// human-readable text.
+type Formatter struct {
+	w         io.Writer
+	quoting   bool
+	truncate  int
+	byBytes   bool
+	parseJSON bool
+	utf16     bool
+	jsonDepth int
+	escape    EscapeStyle
+	noColor   bool
+}
+
+// NewFormatter returns a Formatter that writes to w.
+func NewFormatter(w io.Writer) *Formatter {
+	return &Formatter{w: w}
+}
+
+// SetQuoting sets whether output is enclosed in double quotes.
+func (w *Formatter) SetQuoting(b bool) *Formatter {
+	w.quoting = b
+	return w
+}
+
+// SetTruncate sets the output width after which output is truncated.
+// Zero disables truncation.
+func (w *Formatter) SetTruncate(n int) *Formatter {
+	w.truncate = n
+	return w
+}
+
+// SetTruncateBytes sets whether the truncation limit counts input bytes
+// instead of output width. A character is never split.
+func (w *Formatter) SetTruncateBytes(b bool) *Formatter {
+	w.byBytes = b
+	return w
+}
+
+// SetParseJSON sets whether JSON input is pretty-printed.
+func (w *Formatter) SetParseJSON(b bool) *Formatter {
+	w.parseJSON = b
+	return w
+}
+
+// SetJSONDepth sets the depth beyond which JSON objects and arrays are
+// collapsed into a summary. Zero disables collapsing.
+func (w *Formatter) SetJSONDepth(n int) *Formatter {
+	w.jsonDepth = n
+	return w
+}
+
+// SetUTF16 sets whether input is decoded as UTF-16LE when possible.
+func (w *Formatter) SetUTF16(b bool) *Formatter {
+	w.utf16 = b
+	return w
+}
+
+// SetEscapeStyle sets the escape style for unprintable characters.
+func (w *Formatter) SetEscapeStyle(style EscapeStyle) *Formatter {
+	w.escape = style
+	return w
+}
+
+// SetColor sets whether escape sequences are dimmed. Color is enabled by
+// default unless disabled globally by color.NoColor.
+func (w *Formatter) SetColor(b bool) *Formatter {
+	w.noColor = !b
+	return w
+}
+
+// escapeByte escapes a byte that is not part of valid UTF-8. The JSON
+// style has no byte escape, so it borrows \xHH from Go: \u00HH would
+// stand for a character instead.
+func (w *Formatter) escapeByte(c byte) string {
+	switch w.escape {
+	case CEscape:
+		return fmt.Sprintf("\\%03o", c)
+	default:
+		return fmt.Sprintf("\\x%02x", c)
+	}
+}
+
+func (w *Formatter) escapeRune(r rune, raw []byte) string {
+	switch w.escape {
+	case JSONEscape:
+		if r > 0xffff {
+			r1, r2 := utf16.EncodeRune(r)
+			return fmt.Sprintf("\\u%04x\\u%04x", r1, r2)
+		}
+		return fmt.Sprintf("\\u%04x", r)
+	case CEscape:
+		esc := ""
+		for _, c := range raw {
+			esc += w.escapeByte(c)
+		}
+		return esc
+	default:
+		switch {
+		case r <= 0x7f:
+			return fmt.Sprintf("\\x%02x", r)
+		case r <= 0xffff:
+			return fmt.Sprintf("\\u%04x", r)
+		default:
+			return fmt.Sprintf("\\U%08x", r)
+		}
+	}
+}
+
+// Write writes the formatted form of b.
+func (w *Formatter) Write(b []byte) (int, error) {
+	dim := color.New(color.Faint)
+	if w.noColor {
+		dim.DisableColor()
+	}
+	dimmed := dim.FprintfFunc()
+
+	if w.utf16 {
+		if s, ok := DecodeUTF16LE(b); ok {
+			b = s
+		}
+	}
+
+	if w.parseJSON {
+		for {
+			var s *string
+			if err := json.Unmarshal(b, &s); err != nil || s == nil {
+				break
+			}
+			b = []byte(*s)
+		}
+
+		if obj, err := decodeJSON(b); err == nil {
+			buf := new(bytes.Buffer)
+			p := &jsonPrinter{buf, w.jsonDepth, dimmed}
+			if err := p.print(obj, 0); err != nil {
+				return 0, err
+			}
+			n, err := buf.WriteTo(w.w)
+			return int(n), err
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if w.truncate == 0 {
+		buf.Grow(len(b))
+	}
+	if w.quoting {
+		buf.WriteByte('"')
+	}
+	nwritten, nconsumed := 0, 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if w.truncate > 0 && w.byBytes && nconsumed+size > w.truncate {
+			dimmed(buf, "...")
+			break
+		}
+		var esc string
+		switch {
+		case r == utf8.RuneError && size == 1:
+			esc = w.escapeByte(b[0])
+		case r == 0 && w.escape == GoEscape && !isOctal(b[1:]):
+			esc = `\0`
+		case r == '"' && w.quoting:
+			esc = `\"`
+		case r == '\\':
+			esc = `\\`
+		case (r == '\a' || r == '\v') && w.escape == JSONEscape:
+			esc = w.escapeRune(r, b[:size])
+		case controlEscapes[r] != "":
+			esc = controlEscapes[r]
+		case unicode.IsPrint(r):
+			buf.WriteRune(r)
+			nwritten += 1
+		default:
+			esc = w.escapeRune(r, b[:size])
+		}
+		if esc != "" {
+			dimmed(buf, "%s", esc)
+			nwritten += len(esc)
+		}
+		b = b[size:]
+		nconsumed += size
+		if w.truncate > 0 && !w.byBytes && nwritten >= w.truncate && len(b) > 0 {
+			dimmed(buf, "...")
+			break
+		}
+	}
+	if w.quoting {
+		buf.WriteByte('"')
+	}
+	n, err := buf.WriteTo(w.w)
+	return int(n), err
+}
+
+// DecodeUTF16LE decodes b as UTF-16LE into UTF-8. It reports false if b
+// is not valid UTF-16LE.
+func DecodeUTF16LE(b []byte) ([]byte, bool) {
+	if len(b)%2 != 0 {
+		return nil, false
+	}
+	dst := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i += 2 {
+		r := rune(b[i]) | rune(b[i+1])<<8
+		if utf16.IsSurrogate(r) {
+			if i+3 >= len(b) {
+				return nil, false
+			}
+			r2 := rune(b[i+2]) | rune(b[i+3])<<8
+			r = utf16.DecodeRune(r, r2)
+			if r == utf8.RuneError {
+				return nil, false
+			}
+			i += 2
+		}
+		dst = utf8.AppendRune(dst, r)
+	}
+	return dst, true
+}
+
+// isOctal reports whether b starts with an octal digit.
+func isOctal(b []byte) bool {
+	return len(b) > 0 && '0' <= b[0] && b[0] <= '7'
+}
+
+func parseHex(b []byte, n int) (uint32, bool) {
+	if len(b) < n {
+		return 0, false
+	}
+	x := uint32(0)
+	for i := 0; i < n; i++ {
+		x <<= 4
+		switch {
+		case '0' <= b[i] && b[i] <= '9':
+			x |= uint32(b[i] - '0')
+		case 'A' <= b[i] && b[i] <= 'F':
+			x |= uint32(b[i] - 'A' + 10)
+		case 'a' <= b[i] && b[i] <= 'f':
+			x |= uint32(b[i] - 'a' + 10)
+		default:
+			return 0, false
+		}
+	}
+	return x, true
+}
+
+// Unescape decodes the backslash escapes produced by Formatter in any
+// escape style: \a, \b, \f, \n, \r, \t, \v, \xHH, \uHHHH, \UHHHHHHHH
+// and octal escapes of one to three digits such as \0 and \377. A pair
+// of \uHHHH escapes that forms a UTF-16 surrogate pair stands for one
+// character. Any other escaped character stands for itself. b is not
+// modified.
+func Unescape(b []byte) ([]byte, error) {
+	dst := make([]byte, 0, len(b))
+	i := 0
+	for i < len(b) {
+		if b[i] != '\\' {
+			dst = append(dst, b[i])
+			i += 1
+			continue
+		}
+		if i+1 == len(b) {
+			return nil, fmt.Errorf("truncated backslash escape at position %d", i)
+		}
+		advance := 2
+		switch b[i+1] {
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			x, n := 0, 0
+			for n < 3 && isOctal(b[i+1+n:]) {
+				x = x<<3 | int(b[i+1+n]-'0')
+				n++
+			}
+			if x > 0xff {
+				return nil, fmt.Errorf("octal escape out of range at position %d", i)
+			}
+			dst = append(dst, byte(x))
+			advance = 1 + n
+		case 'a':
+			dst = append(dst, '\a')
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		case 'v':
+			dst = append(dst, '\v')
+		case 'x':
+			cp, ok := parseHex(b[i+2:], 2)
+			if !ok {
+				return nil, fmt.Errorf("truncated \\x escape at position %d", i)
+			}
+			dst = append(dst, byte(cp))
+			advance = 4
+		case 'u':
+			cp, ok := parseHex(b[i+2:], 4)
+			if !ok {
+				return nil, fmt.Errorf("truncated \\u escape at position %d", i)
+			}
+			advance = 6
+			if utf16.IsSurrogate(rune(cp)) && bytes.HasPrefix(b[i+6:], []byte(`\u`)) {
+				if cp2, ok := parseHex(b[i+8:], 4); ok {
+					if r := utf16.DecodeRune(rune(cp), rune(cp2)); r != utf8.RuneError {
+						cp = uint32(r)
+						advance = 12
+					}
+				}
+			}
+			dst = utf8.AppendRune(dst, rune(cp))
+		case 'U':
+			cp, ok := parseHex(b[i+2:], 8)
+			if !ok {
+				return nil, fmt.Errorf("truncated \\U escape at position %d", i)
+			}
+			dst = utf8.AppendRune(dst, rune(cp))
+			advance = 10
+		default:
+			dst = append(dst, b[i+1])
+		}
+		i += advance
+	}
+	return dst, nil
+}
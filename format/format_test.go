@@ -0,0 +1,322 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestFormatter(t *testing.T) {
+	cases := []struct {
+		input, want []byte
+		quoting     bool
+		truncate    int
+		parseJSON   bool
+	}{
+		{[]byte(""), []byte(``), false, 0, false},
+		{[]byte(""), []byte(`""`), true, 0, false},
+		{[]byte("Hello, 世界！"), []byte(`Hello, 世界！`), false, 0, false},
+		{[]byte("Hello, 世界！"), []byte(`"Hello, 世界！"`), true, 0, false},
+		{[]byte("\"\x00\x01\a\b\f\n\r\t\v\\\""), []byte(`"\0\x01\a\b\f\n\r\t\v\\"`), false, 0, false},
+		{[]byte("\"\x00\x01\a\b\f\n\r\t\v\\\""), []byte(`"\"\0\x01\a\b\f\n\r\t\v\\\""`), true, 0, false},
+		{[]byte("\x80\u0080\U0001d53a"), []byte(`\x80\u0080\U0001d53a`), false, 0, false},
+		{[]byte("\x000\x00a"), []byte(`\x000\0a`), false, 0, false},
+		{[]byte("\x80\u0080\U0001d53a"), []byte(`"\x80\u0080\U0001d53a"`), true, 0, false},
+		{[]byte(`null`), []byte(`null`), false, 0, true},
+		{[]byte(`"string"`), []byte(`string`), false, 0, true},
+		{[]byte(`{"key":"value"}`), []byte("{\n  \"key\": \"value\"\n}"), false, 0, true},
+		{[]byte(`"{\"key\":\"value\"}"`), []byte("{\n  \"key\": \"value\"\n}"), false, 0, true},
+		{bytes.Repeat([]byte("a\x80"), 100), bytes.Repeat([]byte(`a\x80`), 100), false, 0, false},
+		{bytes.Repeat([]byte("a\x80"), 100), append(bytes.Repeat([]byte(`a\x80`), 50), '.', '.', '.'), false, 250, false},
+		{bytes.Repeat([]byte("a\x80"), 100), append(bytes.Repeat([]byte(`a\x80`), 2), '.', '.', '.'), false, 10, false},
+		{bytes.Repeat([]byte("a"), 10), bytes.Repeat([]byte("a"), 10), false, 10, false},
+	}
+
+	color.NoColor = true
+	buf := new(bytes.Buffer)
+	w := NewFormatter(buf)
+	for _, tc := range cases {
+		buf.Reset()
+		w.SetQuoting(tc.quoting)
+		w.SetTruncate(tc.truncate)
+		w.SetParseJSON(tc.parseJSON)
+		n, err := w.Write(tc.input)
+		if err != nil {
+			t.Errorf("Write(%q): unexpected error: %v", tc.input, err)
+		} else if !bytes.Equal(buf.Bytes(), tc.want) {
+			t.Errorf("Write(%q) = %q, want %q", tc.input, buf.Bytes(), tc.want)
+		} else if n != len(tc.want) {
+			t.Errorf("Write(%q) returns %d, want %d", tc.input, n, len(tc.want))
+		}
+	}
+}
+
+func TestDecodeUTF16LE(t *testing.T) {
+	cases := []struct {
+		input, want []byte
+	}{
+		{[]byte(""), []byte("")},
+		{[]byte("h\x00r\x00o\x00m\x00e\x00"), []byte("hrome")},
+		{[]byte("\x16\x4e\x4c\x75"), []byte("世界")},
+		{[]byte("\x35\xd8\x3a\xdd"), []byte("\U0001d53a")},
+		{[]byte("a"), nil},
+		{[]byte("\x35\xd8"), nil},
+		{[]byte("\x3a\xdd\x35\xd8"), nil},
+	}
+
+	for _, tc := range cases {
+		got, ok := DecodeUTF16LE(tc.input)
+		if tc.want == nil && ok {
+			t.Errorf("DecodeUTF16LE(%q) should fail", tc.input)
+		} else if tc.want != nil && !ok {
+			t.Errorf("DecodeUTF16LE(%q) should succeed", tc.input)
+		} else if !bytes.Equal(got, tc.want) {
+			t.Errorf("DecodeUTF16LE(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestFormatterEscapeStyle(t *testing.T) {
+	cases := []struct {
+		style       EscapeStyle
+		input, want []byte
+	}{
+		{GoEscape, []byte("\x00\a\v\x01\x80\u0080\U0001d53a"), []byte(`\0\a\v\x01\x80\u0080\U0001d53a`)},
+		{JSONEscape, []byte("\x00\a\v\x01\x80\u0080\U0001d53a"), []byte(`\u0000\u0007\u000b\u0001\x80\u0080\ud835\udd3a`)},
+		{CEscape, []byte("\x00\a\v\x01\x80\u0080\U0001d53a"), []byte(`\000\a\v\001\200\302\200\360\235\224\272`)},
+		{JSONEscape, []byte("a\"\\\n\tb"), []byte(`a"\\\n\tb`)},
+		{CEscape, []byte("a\"\\\n\tb"), []byte(`a"\\\n\tb`)},
+	}
+
+	color.NoColor = true
+	buf := new(bytes.Buffer)
+	w := NewFormatter(buf)
+	for _, tc := range cases {
+		buf.Reset()
+		w.SetEscapeStyle(tc.style)
+		if _, err := w.Write(tc.input); err != nil {
+			t.Errorf("Write(%q): unexpected error: %v", tc.input, err)
+		} else if !bytes.Equal(buf.Bytes(), tc.want) {
+			t.Errorf("Write(%q) = %q, want %q", tc.input, buf.Bytes(), tc.want)
+		}
+	}
+
+	if _, err := ParseEscapeStyle("python"); err == nil {
+		t.Errorf("ParseEscapeStyle(%q) should fail", "python")
+	}
+}
+
+func TestFormatterTruncateBytes(t *testing.T) {
+	cases := []struct {
+		n           int
+		input, want []byte
+	}{
+		{4, []byte("\x00\x01\x02\x03\x04\x05"), []byte(`\0\x01\x02\x03...`)},
+		{4, []byte("abcd"), []byte(`abcd`)},
+		{4, []byte("abc\u00e9"), []byte(`abc...`)},
+		{5, []byte("abc\u00e9f"), []byte("abc\u00e9...")},
+		{0, []byte("\x00\x01"), []byte(`\0\x01`)},
+	}
+
+	color.NoColor = true
+	buf := new(bytes.Buffer)
+	w := NewFormatter(buf).SetTruncateBytes(true)
+	for _, tc := range cases {
+		buf.Reset()
+		w.SetTruncate(tc.n)
+		if _, err := w.Write(tc.input); err != nil {
+			t.Errorf("Write(%q): unexpected error: %v", tc.input, err)
+		} else if !bytes.Equal(buf.Bytes(), tc.want) {
+			t.Errorf("SetTruncate(%d).Write(%q) = %q, want %q", tc.n, tc.input, buf.Bytes(), tc.want)
+		}
+	}
+}
+
+func TestFormatterColor(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = false
+
+	buf := new(bytes.Buffer)
+	w := NewFormatter(buf).SetColor(false)
+	if _, err := w.Write([]byte("a\x00")); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	if got, want := buf.String(), `a\0`; got != want {
+		t.Errorf("Write(%q) = %q, want %q", "a\x00", got, want)
+	}
+
+	buf.Reset()
+	w.SetColor(true)
+	if _, err := w.Write([]byte("a\x00")); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	if got := buf.String(); got == `a\0` {
+		t.Errorf("Write(%q) = %q, want dimmed escape", "a\x00", got)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	cases := []struct {
+		input, want []byte
+	}{
+		{[]byte(``), []byte{}},
+		{[]byte(`abc`), []byte{'a', 'b', 'c'}},
+		{[]byte(`\\\"\0\x01\a\b\f\n\r\t\v`), []byte{'\\', '"', 0, 1, '\a', '\b', '\f', '\n', '\r', '\t', '\v'}},
+		{[]byte(`\x80\u0080\U0001d53Aa`), []byte{0x80, 0xc2, 0x80, 0xf0, 0x9d, 0x94, 0xba, 'a'}},
+		{[]byte(`\0`), []byte{0}},
+		{[]byte(`\x00`), []byte{0}},
+		{[]byte(`\00`), []byte{0}},
+		{[]byte(`\012`), []byte{'\n'}},
+		{[]byte(`\0012`), []byte{1, '2'}},
+		{[]byte(`\377\8`), []byte{0xff, '8'}},
+		{[]byte(`\302\200`), []byte{0xc2, 0x80}},
+		{[]byte(`\x000`), []byte{0, '0'}},
+		{[]byte(`\u0000`), []byte{0}},
+		{[]byte(`\U00000000`), []byte{0}},
+		{[]byte(`\ud835\udd3a`), []byte{0xf0, 0x9d, 0x94, 0xba}},
+		{[]byte(`\ud835x`), []byte{0xef, 0xbf, 0xbd, 'x'}},
+		{[]byte(`\ud835\u0041`), []byte{0xef, 0xbf, 0xbd, 'A'}},
+		{[]byte(`\xff\xFF`), []byte{0xff, 0xff}},
+		{[]byte(`\q\'`), []byte{'q', '\''}},
+		{[]byte(`\`), nil},
+		{[]byte(`a\`), nil},
+		{[]byte(`\x`), nil},
+		{[]byte(`\x0`), nil},
+		{[]byte(`\xXX`), nil},
+		{[]byte(`\u123`), nil},
+		{[]byte(`\uXXXX`), nil},
+		{[]byte(`\U0001d53`), nil},
+		{[]byte(`\UXXXXXXXX`), nil},
+		{[]byte(`\400`), nil},
+	}
+
+	for _, tc := range cases {
+		input := bytes.Clone(tc.input)
+		got, err := Unescape(input)
+		if tc.want == nil && err == nil {
+			t.Errorf("Unescape(%q) should fail", tc.input)
+		} else if tc.want != nil && err != nil {
+			t.Errorf("Unescape(%q): unexpected error: %v", tc.input, err)
+		} else if tc.want != nil && !bytes.Equal(got, tc.want) {
+			t.Errorf("Unescape(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+		if !bytes.Equal(input, tc.input) {
+			t.Errorf("Unescape(%q) modified its input to %q", tc.input, input)
+		}
+	}
+}
+
+func FuzzFormatterRoundTrip(f *testing.F) {
+	for _, s := range []string{
+		"",
+		"Hello, 世界！",
+		"\"\x00\x01\a\b\f\n\r\t\v\\\"",
+		"\x000\x001",
+		"\x00\x01\a\v7\x80\x008",
+		"\x80\u0080\U0001d53a",
+		"�\xef\xbf",
+		" \U000e0001",
+	} {
+		f.Add([]byte(s))
+	}
+
+	color.NoColor = true
+	f.Fuzz(func(t *testing.T, input []byte) {
+		for _, style := range []EscapeStyle{GoEscape, JSONEscape, CEscape} {
+			buf := new(bytes.Buffer)
+			if _, err := NewFormatter(buf).SetEscapeStyle(style).Write(input); err != nil {
+				t.Fatalf("style %d: Write(%q): unexpected error: %v", style, input, err)
+			}
+			got, err := Unescape(buf.Bytes())
+			if err != nil {
+				t.Fatalf("style %d: Unescape(%q): unexpected error: %v", style, buf.Bytes(), err)
+			}
+			if !bytes.Equal(got, input) {
+				t.Errorf("style %d: Unescape(Write(%q)) = %q (formatted as %q)", style, input, got, buf.Bytes())
+			}
+		}
+	})
+}
+
+func TestFormatterJSONDepth(t *testing.T) {
+	input := []byte(`{"a":{"b":[1,2,{"c":3}]},"d":[],"e":{},"f":"x"}`)
+	cases := []struct {
+		depth int
+		want  string
+	}{
+		{0, "{\n  \"a\": {\n    \"b\": [\n      1,\n      2,\n      {\n        \"c\": 3\n      }\n    ]\n  },\n  \"d\": [],\n  \"e\": {},\n  \"f\": \"x\"\n}"},
+		{1, "{\n  \"a\": {...},\n  \"d\": [],\n  \"e\": {},\n  \"f\": \"x\"\n}"},
+		{2, "{\n  \"a\": {\n    \"b\": [...3 items]\n  },\n  \"d\": [],\n  \"e\": {},\n  \"f\": \"x\"\n}"},
+		{4, "{\n  \"a\": {\n    \"b\": [\n      1,\n      2,\n      {\n        \"c\": 3\n      }\n    ]\n  },\n  \"d\": [],\n  \"e\": {},\n  \"f\": \"x\"\n}"},
+	}
+
+	color.NoColor = true
+	buf := new(bytes.Buffer)
+	w := NewFormatter(buf).SetParseJSON(true)
+	for _, tc := range cases {
+		buf.Reset()
+		w.SetJSONDepth(tc.depth)
+		if _, err := w.Write(input); err != nil {
+			t.Errorf("Write(%q): unexpected error: %v", input, err)
+		} else if buf.String() != tc.want {
+			t.Errorf("SetJSONDepth(%d).Write(%q) = %q, want %q", tc.depth, input, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	cases := []struct {
+		input string
+		ok    bool
+	}{
+		{`{"b":1,"a":[true,null,"x"],"c":{}}`, true},
+		{` 1.50 `, true},
+		{`{"a" 1}`, false},
+		{`{"a":1,}`, false},
+		{`{1:1}`, false},
+		{`[1 2]`, false},
+		{`[1,]`, false},
+		{`{"a":1}}`, false},
+		{`1 2`, false},
+		{`]`, false},
+		{``, false},
+	}
+
+	for _, tc := range cases {
+		_, err := decodeJSON([]byte(tc.input))
+		if ok := err == nil; ok != tc.ok {
+			t.Errorf("decodeJSON(%q): error = %v, want ok = %v", tc.input, err, tc.ok)
+		}
+	}
+}
+
+func TestFormatterJSONKeyOrder(t *testing.T) {
+	input := []byte(`{"z":1,"a":{"y":1.50,"b":2},"m":null}`)
+	want := "{\n  \"z\": 1,\n  \"a\": {\n    \"y\": 1.50,\n    \"b\": 2\n  },\n  \"m\": null\n}"
+
+	color.NoColor = true
+	buf := new(bytes.Buffer)
+	if _, err := NewFormatter(buf).SetParseJSON(true).Write(input); err != nil {
+		t.Errorf("Write(%q): unexpected error: %v", input, err)
+	} else if buf.String() != want {
+		t.Errorf("Write(%q) = %q, want %q", input, buf.String(), want)
+	}
+}
+
+func FuzzDecodeJSON(f *testing.F) {
+	for _, s := range []string{`{"b":1,"a":[true,null,"x"],"c":{}}`, `[1,]`, `{"a":1}}`, `"é"`} {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		_, err := decodeJSON(input)
+		if valid := json.Valid(input); valid != (err == nil) {
+			t.Errorf("decodeJSON(%q): error = %v, but json.Valid = %v", input, err, valid)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// jsonObject is a decoded JSON object that keeps its members in their
+// original order.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	Key   string
+	Value interface{}
+}
+
+// decodeJSON decodes b like json.Unmarshal into an interface{}, except that
+// objects are decoded as jsonObject and numbers as json.Number.
+func decodeJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return v, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := jsonObject{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return nil, errors.New("object key is not a string")
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonMember{key, value})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	case json.Delim('}'), json.Delim(']'):
+		return nil, errors.New("unexpected end of object or array")
+	default:
+		return tok, nil
+	}
+}
+
+// jsonPrinter pretty-prints a decoded JSON value, collapsing objects and
+// arrays nested deeper than maxDepth. Zero means no limit.
+type jsonPrinter struct {
+	buf      *bytes.Buffer
+	maxDepth int
+	dimmed   func(w io.Writer, format string, a ...interface{})
+}
+
+func (p *jsonPrinter) scalar(v interface{}) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	p.buf.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return nil
+}
+
+func (p *jsonPrinter) print(v interface{}, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	switch v := v.(type) {
+	case jsonObject:
+		if len(v) == 0 {
+			p.buf.WriteString("{}")
+			return nil
+		}
+		if p.maxDepth > 0 && depth >= p.maxDepth {
+			p.dimmed(p.buf, "{...}")
+			return nil
+		}
+		p.buf.WriteString("{\n")
+		for i, m := range v {
+			p.buf.WriteString(indent + "  ")
+			if err := p.scalar(m.Key); err != nil {
+				return err
+			}
+			p.buf.WriteString(": ")
+			if err := p.print(m.Value, depth+1); err != nil {
+				return err
+			}
+			if i < len(v)-1 {
+				p.buf.WriteByte(',')
+			}
+			p.buf.WriteByte('\n')
+		}
+		p.buf.WriteString(indent + "}")
+	case []interface{}:
+		if len(v) == 0 {
+			p.buf.WriteString("[]")
+			return nil
+		}
+		if p.maxDepth > 0 && depth >= p.maxDepth {
+			p.dimmed(p.buf, "[...%d items]", len(v))
+			return nil
+		}
+		p.buf.WriteString("[\n")
+		for i, elem := range v {
+			p.buf.WriteString(indent + "  ")
+			if err := p.print(elem, depth+1); err != nil {
+				return err
+			}
+			if i < len(v)-1 {
+				p.buf.WriteByte(',')
+			}
+			p.buf.WriteByte('\n')
+		}
+		p.buf.WriteString(indent + "]")
+	default:
+		return p.scalar(v)
+	}
+	return nil
+}
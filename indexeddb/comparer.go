@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"cmp"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -18,16 +19,42 @@ import (
 //   https://source.chromium.org/chromium/chromium/src/+/main:content/browser/indexed_db/indexed_db_leveldb_coding.cc
 //   https://chromium.googlesource.com/chromium/src/+/main/content/browser/indexed_db/docs/leveldb_coding_scheme.md
 
+// KeyType is the type of an IndexedDB key, determined by its key prefix.
+type KeyType int
+
+// Key types.
 const (
-	globalMetadata   = 0
-	databaseMetadata = 1
-	objectStoreData  = 2
-	existsEntry      = 3
-	indexData        = 4
-	invalidType      = 5
-	blobEntry        = 6
+	GlobalMetadata   KeyType = 0
+	DatabaseMetadata KeyType = 1
+	ObjectStoreData  KeyType = 2
+	ExistsEntry      KeyType = 3
+	IndexData        KeyType = 4
+	InvalidType      KeyType = 5
+	BlobEntry        KeyType = 6
 )
 
+func (t KeyType) String() string {
+	switch t {
+	case GlobalMetadata:
+		return "GlobalMetadata"
+	case DatabaseMetadata:
+		return "DatabaseMetadata"
+	case ObjectStoreData:
+		return "ObjectStoreData"
+	case ExistsEntry:
+		return "ExistsEntry"
+	case IndexData:
+		return "IndexData"
+	case BlobEntry:
+		return "BlobEntry"
+	default:
+		return "InvalidType"
+	}
+}
+
+// ErrInvalidKey is returned when a key is not a valid IndexedDB key.
+var ErrInvalidKey = errors.New("indexeddb: invalid key")
+
 const (
 	objectStoreDataIndexId = 1
 	existsEntryIndexId     = 2
@@ -73,68 +100,84 @@ const (
 	indexedDBMinKeyType     = 7
 )
 
-func decodeInt(a []byte) int64 {
+func decodeInt(a []byte) (int64, error) {
 	if len(a) == 0 || len(a) > 8 {
-		panic("invalid key")
+		return 0, ErrInvalidKey
 	}
 	v := uint64(0)
 	for i, b := range a {
 		v |= uint64(b) << (8 * i)
 	}
-	return int64(v)
+	return int64(v), nil
 }
 
-func decodeVarInt(a []byte) ([]byte, int64) {
+func decodeVarInt(a []byte) ([]byte, int64, error) {
 	v := uint64(0)
 	for i := 0; i < len(a) && i < 9; i++ {
 		v |= uint64(a[i]&0x7f) << (7 * i)
 		if a[i]&0x80 == 0 {
-			return a[i+1:], int64(v)
+			return a[i+1:], int64(v), nil
 		}
 	}
-	panic("invalid key")
+	return nil, 0, ErrInvalidKey
+}
+
+func decodeVarInts(a, b []byte) ([]byte, int64, []byte, int64, error) {
+	a, v1, err := decodeVarInt(a)
+	if err != nil {
+		return nil, 0, nil, 0, err
+	}
+	b, v2, err := decodeVarInt(b)
+	if err != nil {
+		return nil, 0, nil, 0, err
+	}
+	return a, v1, b, v2, nil
 }
 
-func compareBinary(a, b []byte) ([]byte, []byte, int) {
-	a, len1 := decodeVarInt(a)
-	b, len2 := decodeVarInt(b)
+func compareBinary(a, b []byte) ([]byte, []byte, int, error) {
+	a, len1, b, len2, err := decodeVarInts(a, b)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 
 	if uint64(len(a)) < uint64(len1) || uint64(len(b)) < uint64(len2) {
 		minlen := min(uint64(len1), uint64(len2), uint64(len(a)), uint64(len(b)))
 		if ret := bytes.Compare(a[:minlen], b[:minlen]); ret != 0 {
-			return nil, nil, ret
+			return nil, nil, ret, nil
 		}
-		return nil, nil, cmp.Compare(len1, len2)
+		return nil, nil, cmp.Compare(len1, len2), nil
 	}
 
-	return a[len1:], b[len2:], bytes.Compare(a[:len1], b[:len2])
+	return a[len1:], b[len2:], bytes.Compare(a[:len1], b[:len2]), nil
 }
 
-func compareStringWithLength(a, b []byte) ([]byte, []byte, int) {
-	a, v1 := decodeVarInt(a)
+func compareStringWithLength(a, b []byte) ([]byte, []byte, int, error) {
+	a, v1, b, v2, err := decodeVarInts(a, b)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 	len1 := 2 * uint64(v1)
-	b, v2 := decodeVarInt(b)
 	len2 := 2 * uint64(v2)
 
 	if uint64(len(a)) < len1 || uint64(len(b)) < len2 {
 		minlen := min(len1, len2, uint64(len(a)), uint64(len(b)))
 		if ret := bytes.Compare(a[:minlen], b[:minlen]); ret != 0 {
-			return nil, nil, ret
+			return nil, nil, ret, nil
 		}
-		return nil, nil, cmp.Compare(v1, v2)
+		return nil, nil, cmp.Compare(v1, v2), nil
 	}
 
-	return a[len1:], b[len2:], bytes.Compare(a[:len1], b[:len2])
+	return a[len1:], b[len2:], bytes.Compare(a[:len1], b[:len2]), nil
 }
 
-func compareDouble(a, b []byte) ([]byte, []byte, int) {
+func compareDouble(a, b []byte) ([]byte, []byte, int, error) {
 	if len(a) < 8 || len(b) < 8 {
-		panic("invalid key")
+		return nil, nil, 0, ErrInvalidKey
 	}
 
 	f1 := math.Float64frombits(binary.NativeEndian.Uint64(a))
 	f2 := math.Float64frombits(binary.NativeEndian.Uint64(b))
-	return a[8:], b[8:], cmp.Compare(f1, f2)
+	return a[8:], b[8:], cmp.Compare(f1, f2), nil
 }
 
 func keyTypeByteToKeyType(b byte) int {
@@ -158,14 +201,14 @@ func keyTypeByteToKeyType(b byte) int {
 	}
 }
 
-func compareEncodedIDBKeys(a, b []byte) ([]byte, []byte, int) {
+func compareEncodedIDBKeys(a, b []byte) ([]byte, []byte, int, error) {
 	if len(a) == 0 || len(b) == 0 {
-		return a, b, cmp.Compare(len(a), len(b))
+		return a, b, cmp.Compare(len(a), len(b)), nil
 	}
 
 	ret := cmp.Compare(keyTypeByteToKeyType(a[0]), keyTypeByteToKeyType(b[0]))
 	if ret != 0 {
-		return a[1:], b[1:], ret
+		return a[1:], b[1:], ret, nil
 	}
 
 	typeByte := a[0]
@@ -173,69 +216,73 @@ func compareEncodedIDBKeys(a, b []byte) ([]byte, []byte, int) {
 
 	switch typeByte {
 	case indexedDBKeyNullTypeByte, indexedDBKeyMinKeyTypeByte:
-		return a, b, 0
+		return a, b, 0, nil
 	case indexedDBKeyArrayTypeByte:
 		if len(a) == 0 || len(b) == 0 {
-			return a, b, cmp.Compare(len(a), len(b))
+			return a, b, cmp.Compare(len(a), len(b)), nil
+		}
+		a, len1, b, len2, err := decodeVarInts(a, b)
+		if err != nil {
+			return nil, nil, 0, err
 		}
-		a, len1 := decodeVarInt(a)
-		b, len2 := decodeVarInt(b)
 		for i := int64(0); i < len1 && i < len2; i++ {
 			if len(a) == 0 || len(b) == 0 {
 				break
 			}
-			a, b, ret = compareEncodedIDBKeys(a, b)
-			if ret != 0 {
-				return a, b, ret
+			a, b, ret, err = compareEncodedIDBKeys(a, b)
+			if err != nil || ret != 0 {
+				return a, b, ret, err
 			}
 		}
-		return a, b, cmp.Compare(len1, len2)
+		return a, b, cmp.Compare(len1, len2), nil
 	case indexedDBKeyBinaryTypeByte:
 		if len(a) == 0 || len(b) == 0 {
-			return a, b, cmp.Compare(len(a), len(b))
+			return a, b, cmp.Compare(len(a), len(b)), nil
 		}
 		return compareBinary(a, b)
 	case indexedDBKeyStringTypeByte:
 		if len(a) == 0 || len(b) == 0 {
-			return a, b, cmp.Compare(len(a), len(b))
+			return a, b, cmp.Compare(len(a), len(b)), nil
 		}
 		return compareStringWithLength(a, b)
 	case indexedDBKeyDateTypeByte, indexedDBKeyNumberTypeByte:
 		if len(a) == 0 || len(b) == 0 {
-			return a, b, cmp.Compare(len(a), len(b))
+			return a, b, cmp.Compare(len(a), len(b)), nil
 		}
 		return compareDouble(a, b)
 	default:
-		panic("invalid key")
+		return nil, nil, 0, ErrInvalidKey
 	}
 }
 
-type keyPrefix struct {
+// KeyPrefix is the prefix common to all keys in an IndexedDB database.
+type KeyPrefix struct {
 	DatabaseId, ObjectStoreId, IndexId int64
 }
 
-func (prefix *keyPrefix) Type() int {
+// Type returns the type of keys with the prefix.
+func (prefix *KeyPrefix) Type() KeyType {
 	switch {
 	case prefix.DatabaseId == 0:
-		return globalMetadata
+		return GlobalMetadata
 	case prefix.ObjectStoreId == 0:
-		return databaseMetadata
+		return DatabaseMetadata
 	case prefix.IndexId == objectStoreDataIndexId:
-		return objectStoreData
+		return ObjectStoreData
 	case prefix.IndexId == existsEntryIndexId:
-		return existsEntry
+		return ExistsEntry
 	case prefix.IndexId == blobEntryIndexId:
-		return blobEntry
+		return BlobEntry
 	case prefix.IndexId >= minimumIndexId:
-		return indexData
+		return IndexData
 	default:
-		return invalidType
+		return InvalidType
 	}
 }
 
-func decodeKeyPrefix(a []byte) ([]byte, *keyPrefix) {
+func decodeKeyPrefix(a []byte) ([]byte, *KeyPrefix, error) {
 	if len(a) == 0 {
-		panic("invalid key")
+		return nil, nil, ErrInvalidKey
 	}
 
 	firstByte := a[0]
@@ -246,22 +293,41 @@ func decodeKeyPrefix(a []byte) ([]byte, *keyPrefix) {
 	indexIdBytes := int((firstByte & 0x03) + 1)
 
 	if len(a) < databaseIdBytes+objectStoreIdBytes+indexIdBytes {
-		panic("invalid key")
+		return nil, nil, ErrInvalidKey
 	}
 
-	databaseId := decodeInt(a[:databaseIdBytes])
+	databaseId, err := decodeInt(a[:databaseIdBytes])
+	if err != nil {
+		return nil, nil, err
+	}
 	a = a[databaseIdBytes:]
 
-	objectStoreId := decodeInt(a[:objectStoreIdBytes])
+	objectStoreId, err := decodeInt(a[:objectStoreIdBytes])
+	if err != nil {
+		return nil, nil, err
+	}
 	a = a[objectStoreIdBytes:]
 
-	indexId := decodeInt(a[:indexIdBytes])
+	indexId, err := decodeInt(a[:indexIdBytes])
+	if err != nil {
+		return nil, nil, err
+	}
 	a = a[indexIdBytes:]
 
-	return a, &keyPrefix{databaseId, objectStoreId, indexId}
+	return a, &KeyPrefix{databaseId, objectStoreId, indexId}, nil
+}
+
+// DecodeKeyPrefix decodes the key prefix of the given key.
+// It returns the decoded prefix and the remaining bytes.
+func DecodeKeyPrefix(key []byte) (KeyPrefix, []byte, error) {
+	rest, prefix, err := decodeKeyPrefix(key)
+	if err != nil {
+		return KeyPrefix{}, nil, err
+	}
+	return *prefix, rest, nil
 }
 
-func compareKeyPrefix(a, b *keyPrefix) int {
+func compareKeyPrefix(a, b *KeyPrefix) int {
 	if ret := cmp.Compare(a.DatabaseId, b.DatabaseId); ret != 0 {
 		return ret
 	}
@@ -274,203 +340,242 @@ func compareKeyPrefix(a, b *keyPrefix) int {
 	return 0
 }
 
-type idbCmp1 struct{}
-
-func (idbCmp1) Compare(a, b []byte) int {
-	defer func(a, b []byte) {
-		if err := recover(); err != nil {
-			fmt.Fprintln(os.Stderr, "leveldb: warning: idb_cmp1: invalid IndexedDB key found")
-			fmt.Fprintf(os.Stderr, "leveldb: debug: a = %x\n", a)
-			fmt.Fprintf(os.Stderr, "leveldb: debug: b = %x\n", b)
-		}
-	}(a, b)
+func compareGlobalMetadata(a, b []byte) (int, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return cmp.Compare(len(a), len(b)), nil
+	}
+	if ret := cmp.Compare(a[0], b[0]); ret != 0 {
+		return ret, nil
+	}
 
-	a, prefixA := decodeKeyPrefix(a)
-	b, prefixB := decodeKeyPrefix(b)
+	typeByte := a[0]
+	a, b = a[1:], b[1:]
 
-	if ret := compareKeyPrefix(prefixA, prefixB); ret != 0 {
-		return ret
+	if typeByte < maxSimpleGlobalMetaDataTypeByte {
+		return 0, nil
 	}
 
-	switch prefixA.Type() {
-	case globalMetadata:
+	switch typeByte {
+	case scopesPrefixByte:
+		return bytes.Compare(a, b), nil
+	case databaseFreeListTypeByte:
 		if len(a) == 0 || len(b) == 0 {
-			return cmp.Compare(len(a), len(b))
-		}
-		if ret := cmp.Compare(a[0], b[0]); ret != 0 {
-			return ret
-		}
-
-		typeByte := a[0]
-		a, b = a[1:], b[1:]
-
-		if typeByte < maxSimpleGlobalMetaDataTypeByte {
-			return 0
+			return cmp.Compare(len(a), len(b)), nil
 		}
-
-		switch typeByte {
-		case scopesPrefixByte:
-			return bytes.Compare(a, b)
-		case databaseFreeListTypeByte:
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			_, databaseIdA := decodeVarInt(a)
-			_, databaseIdB := decodeVarInt(b)
-			return cmp.Compare(databaseIdA, databaseIdB)
-		case databaseNameTypeByte:
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			a, b, ret := compareStringWithLength(a, b)
-			if ret != 0 {
-				return ret
-			}
-
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			_, _, ret = compareStringWithLength(a, b)
-			return ret
-		default:
-			panic("invalid key")
+		_, databaseIdA, _, databaseIdB, err := decodeVarInts(a, b)
+		if err != nil {
+			return 0, err
 		}
-	case databaseMetadata:
+		return cmp.Compare(databaseIdA, databaseIdB), nil
+	case databaseNameTypeByte:
 		if len(a) == 0 || len(b) == 0 {
-			return cmp.Compare(len(a), len(b))
+			return cmp.Compare(len(a), len(b)), nil
 		}
-		if ret := cmp.Compare(a[0], b[0]); ret != 0 {
-			return ret
+		a, b, ret, err := compareStringWithLength(a, b)
+		if err != nil || ret != 0 {
+			return ret, err
 		}
 
-		typeByte := a[0]
-		a, b = a[1:], b[1:]
-
-		if typeByte < maxSimpleDatabaseMetaDataTypeByte {
-			return 0
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
 		}
+		_, _, ret, err = compareStringWithLength(a, b)
+		return ret, err
+	default:
+		return 0, ErrInvalidKey
+	}
+}
 
-		switch typeByte {
-		case objectStoreMetaDataTypeByte:
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			a, objectStoreIdA := decodeVarInt(a)
-			b, objectStoreIdB := decodeVarInt(b)
-			if ret := cmp.Compare(objectStoreIdA, objectStoreIdB); ret != 0 {
-				return ret
-			}
+func compareDatabaseMetadata(a, b []byte) (int, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return cmp.Compare(len(a), len(b)), nil
+	}
+	if ret := cmp.Compare(a[0], b[0]); ret != 0 {
+		return ret, nil
+	}
 
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			return cmp.Compare(a[0], b[0])
-		case indexMetaDataTypeByte:
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			a, objectStoreIdA := decodeVarInt(a)
-			b, objectStoreIdB := decodeVarInt(b)
-			if ret := cmp.Compare(objectStoreIdA, objectStoreIdB); ret != 0 {
-				return ret
-			}
+	typeByte := a[0]
+	a, b = a[1:], b[1:]
 
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			a, indexIdA := decodeVarInt(a)
-			b, indexIdB := decodeVarInt(b)
-			if ret := cmp.Compare(indexIdA, indexIdB); ret != 0 {
-				return ret
-			}
+	if typeByte < maxSimpleDatabaseMetaDataTypeByte {
+		return 0, nil
+	}
 
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			return cmp.Compare(a[0], b[0])
-		case objectStoreFreeListTypeByte:
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			_, objectStoreIdA := decodeVarInt(a)
-			_, objectStoreIdB := decodeVarInt(b)
-			return cmp.Compare(objectStoreIdA, objectStoreIdB)
-		case indexFreeListTypeByte:
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			a, objectStoreIdA := decodeVarInt(a)
-			b, objectStoreIdB := decodeVarInt(b)
-			if ret := cmp.Compare(objectStoreIdA, objectStoreIdB); ret != 0 {
-				return ret
-			}
+	switch typeByte {
+	case objectStoreMetaDataTypeByte:
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		a, objectStoreIdA, b, objectStoreIdB, err := decodeVarInts(a, b)
+		if err != nil {
+			return 0, err
+		}
+		if ret := cmp.Compare(objectStoreIdA, objectStoreIdB); ret != 0 {
+			return ret, nil
+		}
 
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			_, indexIdA := decodeVarInt(a)
-			_, indexIdB := decodeVarInt(b)
-			return cmp.Compare(indexIdA, indexIdB)
-		case objectStoreNamesTypeByte:
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			_, _, ret := compareStringWithLength(a, b)
-			return ret
-		case indexNamesKeyTypeByte:
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			a, objectStoreIdA := decodeVarInt(a)
-			b, objectStoreIdB := decodeVarInt(b)
-			if ret := cmp.Compare(objectStoreIdA, objectStoreIdB); ret != 0 {
-				return ret
-			}
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		return cmp.Compare(a[0], b[0]), nil
+	case indexMetaDataTypeByte:
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		a, objectStoreIdA, b, objectStoreIdB, err := decodeVarInts(a, b)
+		if err != nil {
+			return 0, err
+		}
+		if ret := cmp.Compare(objectStoreIdA, objectStoreIdB); ret != 0 {
+			return ret, nil
+		}
 
-			if len(a) == 0 || len(b) == 0 {
-				return cmp.Compare(len(a), len(b))
-			}
-			_, _, ret := compareStringWithLength(a, b)
-			return ret
-		default:
-			panic("invalid key")
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
 		}
-	case objectStoreData:
-		_, _, ret := compareEncodedIDBKeys(a, b)
-		return ret
-	case existsEntry:
-		_, _, ret := compareEncodedIDBKeys(a, b)
-		return ret
-	case blobEntry:
-		_, _, ret := compareEncodedIDBKeys(a, b)
-		return ret
-	case indexData:
-		a, b, ret := compareEncodedIDBKeys(a, b)
-		if ret != 0 {
-			return ret
+		a, indexIdA, b, indexIdB, err := decodeVarInts(a, b)
+		if err != nil {
+			return 0, err
+		}
+		if ret := cmp.Compare(indexIdA, indexIdB); ret != 0 {
+			return ret, nil
+		}
+
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		return cmp.Compare(a[0], b[0]), nil
+	case objectStoreFreeListTypeByte:
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		_, objectStoreIdA, _, objectStoreIdB, err := decodeVarInts(a, b)
+		if err != nil {
+			return 0, err
+		}
+		return cmp.Compare(objectStoreIdA, objectStoreIdB), nil
+	case indexFreeListTypeByte:
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		a, objectStoreIdA, b, objectStoreIdB, err := decodeVarInts(a, b)
+		if err != nil {
+			return 0, err
+		}
+		if ret := cmp.Compare(objectStoreIdA, objectStoreIdB); ret != 0 {
+			return ret, nil
 		}
 
-		sequenceNumberA := int64(-1)
-		sequenceNumberB := int64(-1)
-		if len(a) > 0 {
-			a, sequenceNumberA = decodeVarInt(a)
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
 		}
-		if len(b) > 0 {
-			b, sequenceNumberB = decodeVarInt(b)
+		_, indexIdA, _, indexIdB, err := decodeVarInts(a, b)
+		if err != nil {
+			return 0, err
+		}
+		return cmp.Compare(indexIdA, indexIdB), nil
+	case objectStoreNamesTypeByte:
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		_, _, ret, err := compareStringWithLength(a, b)
+		return ret, err
+	case indexNamesKeyTypeByte:
+		if len(a) == 0 || len(b) == 0 {
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		a, objectStoreIdA, b, objectStoreIdB, err := decodeVarInts(a, b)
+		if err != nil {
+			return 0, err
+		}
+		if ret := cmp.Compare(objectStoreIdA, objectStoreIdB); ret != 0 {
+			return ret, nil
 		}
 
 		if len(a) == 0 || len(b) == 0 {
-			return cmp.Compare(len(a), len(b))
+			return cmp.Compare(len(a), len(b)), nil
+		}
+		_, _, ret, err := compareStringWithLength(a, b)
+		return ret, err
+	default:
+		return 0, ErrInvalidKey
+	}
+}
+
+func compareIndexData(a, b []byte) (int, error) {
+	a, b, ret, err := compareEncodedIDBKeys(a, b)
+	if err != nil || ret != 0 {
+		return ret, err
+	}
+
+	sequenceNumberA := int64(-1)
+	sequenceNumberB := int64(-1)
+	if len(a) > 0 {
+		a, sequenceNumberA, err = decodeVarInt(a)
+		if err != nil {
+			return 0, err
 		}
-		_, _, ret = compareEncodedIDBKeys(a, b)
-		if ret != 0 {
-			return ret
+	}
+	if len(b) > 0 {
+		b, sequenceNumberB, err = decodeVarInt(b)
+		if err != nil {
+			return 0, err
 		}
+	}
+
+	if len(a) == 0 || len(b) == 0 {
+		return cmp.Compare(len(a), len(b)), nil
+	}
+	_, _, ret, err = compareEncodedIDBKeys(a, b)
+	if err != nil || ret != 0 {
+		return ret, err
+	}
+
+	return cmp.Compare(sequenceNumberA, sequenceNumberB), nil
+}
+
+func compare(a, b []byte) (int, error) {
+	a, prefixA, err := decodeKeyPrefix(a)
+	if err != nil {
+		return 0, err
+	}
+	b, prefixB, err := decodeKeyPrefix(b)
+	if err != nil {
+		return 0, err
+	}
+
+	if ret := compareKeyPrefix(prefixA, prefixB); ret != 0 {
+		return ret, nil
+	}
 
-		return cmp.Compare(sequenceNumberA, sequenceNumberB)
+	switch prefixA.Type() {
+	case GlobalMetadata:
+		return compareGlobalMetadata(a, b)
+	case DatabaseMetadata:
+		return compareDatabaseMetadata(a, b)
+	case ObjectStoreData, ExistsEntry, BlobEntry:
+		_, _, ret, err := compareEncodedIDBKeys(a, b)
+		return ret, err
+	case IndexData:
+		return compareIndexData(a, b)
 	default:
-		panic("invalid key")
+		return 0, ErrInvalidKey
+	}
+}
+
+type idbCmp1 struct{}
+
+// Compare reports invalid keys on stderr and treats them as equal, since
+// the comparer.Comparer interface provides no way to return an error.
+func (idbCmp1) Compare(a, b []byte) int {
+	ret, err := compare(a, b)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "leveldb: warning: idb_cmp1: invalid IndexedDB key found")
+		fmt.Fprintf(os.Stderr, "leveldb: debug: a = %x\n", a)
+		fmt.Fprintf(os.Stderr, "leveldb: debug: b = %x\n", b)
+		return 0
 	}
+	return ret
 }
 
 func (idbCmp1) Name() string {
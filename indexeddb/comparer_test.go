@@ -0,0 +1,97 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package indexeddb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecodeKeyPrefix(t *testing.T) {
+	cases := []struct {
+		Key    string
+		Prefix KeyPrefix
+		Rest   string
+		Type   KeyType
+		Valid  bool
+	}{
+		{"00 00 00 00", KeyPrefix{0, 0, 0}, "", GlobalMetadata, true},
+		{"00 01 00 00 32", KeyPrefix{1, 0, 0}, "32", DatabaseMetadata, true},
+		{"00 01 02 01 0100", KeyPrefix{1, 2, 1}, "0100", ObjectStoreData, true},
+		{"00 01 02 1e 01", KeyPrefix{1, 2, 30}, "01", IndexData, true},
+		{"20 0001 02 03", KeyPrefix{256, 2, 3}, "", BlobEntry, true},
+		{"", KeyPrefix{}, "", InvalidType, false},
+		{"00 01 02", KeyPrefix{}, "", InvalidType, false},
+		{"20 0001 02", KeyPrefix{}, "", InvalidType, false},
+	}
+
+	for _, tc := range cases {
+		prefix, rest, err := DecodeKeyPrefix(decodeHex(tc.Key))
+		if !tc.Valid {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("DecodeKeyPrefix(%s): expected ErrInvalidKey, got %v", tc.Key, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DecodeKeyPrefix(%s): unexpected error: %v", tc.Key, err)
+		} else if prefix != tc.Prefix || !bytes.Equal(rest, decodeHex(tc.Rest)) {
+			t.Errorf("DecodeKeyPrefix(%s) = (%v, %x), want (%v, %s)", tc.Key, prefix, rest, tc.Prefix, tc.Rest)
+		} else if prefix.Type() != tc.Type {
+			t.Errorf("DecodeKeyPrefix(%s).Type() = %v, want %v", tc.Key, prefix.Type(), tc.Type)
+		}
+	}
+}
+
+func TestEncodeKeyPrefix(t *testing.T) {
+	for _, prefix := range []KeyPrefix{{0, 0, 0}, {1, 2, 1}, {256, 2, 30}, {1 << 40, 70000, 1 << 20}} {
+		encoded := EncodeKeyPrefix(prefix)
+		decoded, rest, err := DecodeKeyPrefix(encoded)
+		if err != nil {
+			t.Errorf("DecodeKeyPrefix(EncodeKeyPrefix(%v)): unexpected error: %v", prefix, err)
+		} else if decoded != prefix || len(rest) != 0 {
+			t.Errorf("DecodeKeyPrefix(EncodeKeyPrefix(%v)) = (%v, %x)", prefix, decoded, rest)
+		}
+	}
+}
+
+func FuzzDecodeKeyPrefix(f *testing.F) {
+	f.Add(decodeHex("00 00 00 00"))
+	f.Add(decodeHex("00 01 02 01 0100"))
+	f.Add(decodeHex("20 0001 02"))
+
+	f.Fuzz(func(t *testing.T, key []byte) {
+		_, rest, err := DecodeKeyPrefix(key)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("DecodeKeyPrefix(%x): unexpected error: %v", key, err)
+			}
+			return
+		}
+		if !bytes.HasSuffix(key, rest) {
+			t.Errorf("DecodeKeyPrefix(%x): rest %x is not a suffix of the key", key, rest)
+		}
+	})
+}
+
+func FuzzCompare(f *testing.F) {
+	f.Add(decodeHex("00 00 00 00 32"), decodeHex("00 00 00 00 c9 01 00"))
+	f.Add(decodeHex("00 01 00 00 32 01 00"), decodeHex("00 01 00 00 64 01 01 00"))
+	f.Add(decodeHex("00 01 02 01 04 02 01 01 00 03 0000000000000000"), decodeHex("00 01 02 01 06 01 00"))
+	f.Add(decodeHex("00 01 02 1e 01 01 00 01 00 01 00"), decodeHex("00 01 02 1e 01 01 00 02 00 01 00"))
+
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		ret, err := compare(a, b)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("compare(%x, %x): unexpected error: %v", a, b, err)
+			}
+			return
+		}
+		if ret < -1 || ret > 1 {
+			t.Errorf("compare(%x, %x) = %d", a, b, ret)
+		}
+	})
+}
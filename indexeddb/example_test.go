@@ -0,0 +1,59 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package indexeddb_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cions/leveldb-cli/indexeddb"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// Go programs open an IndexedDB database with Comparer and read it with
+// goleveldb directly.
+func ExampleComparer() {
+	dbpath, err := os.MkdirTemp("", "indexeddb-example-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dbpath)
+
+	db, err := leveldb.OpenFile(dbpath, &opt.Options{Comparer: indexeddb.Comparer})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// An object store value with the primary key "ab".
+	prefix := indexeddb.EncodeKeyPrefix(indexeddb.KeyPrefix{DatabaseId: 1, ObjectStoreId: 1, IndexId: 1})
+	key := append(prefix, "\x01\x02\x00a\x00b"...)
+	if err := db.Put(key, []byte("\x01\xff\x14\xff\x0f\x22\x03abc"), nil); err != nil {
+		log.Fatal(err)
+	}
+
+	iter := db.NewIterator(indexeddb.Prefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		_, rest, err := indexeddb.DecodeKeyPrefix(iter.Key())
+		if err != nil {
+			log.Fatal(err)
+		}
+		primaryKey, _, err := indexeddb.DecodeKey(rest)
+		if err != nil {
+			log.Fatal(err)
+		}
+		payload, err := indexeddb.StripValueHeader(iter.Value())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s: %x\n", primaryKey, payload)
+	}
+	if err := iter.Error(); err != nil {
+		log.Fatal(err)
+	}
+	// Output: "ab": ff0f2203616263
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package indexeddb
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// An encoded IndexedDB key, such as the primary key that follows the key
+// prefix of object store data, is a type byte followed by the value:
+//
+//	0x00                                         (null)
+//	0x01 <length: VarInt> <UTF-16BE code units>  (string)
+//	0x02 <milliseconds: double>                  (date)
+//	0x03 <double>                                (number)
+//	0x04 <length: VarInt> <encoded key>...       (array)
+//	0x05                                         (min key)
+//	0x06 <length: VarInt> <bytes>                (binary)
+//
+// Doubles are stored in little-endian byte order.
+
+// DecodeKey decodes an encoded IndexedDB key into a JavaScript-like
+// notation, e.g. ["user",42], and returns the remaining bytes. Dates are
+// written as Date(<RFC 3339 time>) and binary keys as Binary(<hex>).
+func DecodeKey(b []byte) (string, []byte, error) {
+	sb := new(strings.Builder)
+	rest, err := decodeKey(sb, b)
+	if err != nil {
+		return "", nil, err
+	}
+	return sb.String(), rest, nil
+}
+
+func decodeKey(sb *strings.Builder, b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, ErrInvalidKey
+	}
+	typeByte := b[0]
+	b = b[1:]
+
+	switch typeByte {
+	case indexedDBKeyNullTypeByte:
+		sb.WriteString("null")
+		return b, nil
+	case indexedDBKeyMinKeyTypeByte:
+		sb.WriteString("MinKey")
+		return b, nil
+	case indexedDBKeyStringTypeByte:
+		b, n, err := decodeVarInt(b)
+		if err != nil || n < 0 || uint64(len(b)) < 2*uint64(n) {
+			return nil, ErrInvalidKey
+		}
+		units := make([]uint16, n)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+		sb.WriteString(strconv.Quote(string(utf16.Decode(units))))
+		return b[2*n:], nil
+	case indexedDBKeyDateTypeByte, indexedDBKeyNumberTypeByte:
+		if len(b) < 8 {
+			return nil, ErrInvalidKey
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		if typeByte == indexedDBKeyDateTypeByte {
+			sb.WriteString("Date(")
+			sb.WriteString(time.UnixMilli(int64(f)).UTC().Format(time.RFC3339Nano))
+			sb.WriteString(")")
+		} else {
+			sb.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return b[8:], nil
+	case indexedDBKeyArrayTypeByte:
+		b, n, err := decodeVarInt(b)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidKey
+		}
+		sb.WriteString("[")
+		for i := int64(0); i < n; i++ {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			if b, err = decodeKey(sb, b); err != nil {
+				return nil, err
+			}
+		}
+		sb.WriteString("]")
+		return b, nil
+	case indexedDBKeyBinaryTypeByte:
+		b, n, err := decodeVarInt(b)
+		if err != nil || n < 0 || uint64(len(b)) < uint64(n) {
+			return nil, ErrInvalidKey
+		}
+		sb.WriteString("Binary(")
+		sb.WriteString(hex.EncodeToString(b[:n]))
+		sb.WriteString(")")
+		return b[n:], nil
+	default:
+		return nil, ErrInvalidKey
+	}
+}
+
+// DecodeIndexDataKey decodes the part of an index data key that follows
+// its key prefix: the index key, then the sequence number and the primary
+// key of the entry. seq is -1 and primaryKey is empty if the key ends
+// after the index key.
+func DecodeIndexDataKey(b []byte) (key string, seq int64, primaryKey string, err error) {
+	key, b, err = DecodeKey(b)
+	if err != nil {
+		return "", 0, "", err
+	}
+	if len(b) == 0 {
+		return key, -1, "", nil
+	}
+	b, seq, err = decodeVarInt(b)
+	if err != nil {
+		return "", 0, "", err
+	}
+	primaryKey, _, err = DecodeKey(b)
+	if err != nil {
+		return "", 0, "", err
+	}
+	return key, seq, primaryKey, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package indexeddb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeKey(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+		rest  string
+	}{
+		{"00", "null", ""},
+		{"05 ff", "MinKey", "ff"},
+		{"01 00", `""`, ""},
+		{"01 02 0075 00e9", `"ué"`, ""},
+		{"01 02 d83d de00 01", `"😀"`, "01"},
+		{"03 000000000000f03f", "1", ""},
+		{"03 000000000000f8bf", "-1.5", ""},
+		{"02 00008056febc7842", "Date(2023-11-14T22:13:20Z)", ""},
+		{"04 00", "[]", ""},
+		{"04 02 01 04 0075 0073 0065 0072 03 0000000000004540", `["user",42]`, ""},
+		{"04 01 04 01 00", "[[null]]", ""},
+		{"06 03 00ff10", "Binary(00ff10)", ""},
+	}
+
+	for _, tc := range cases {
+		got, rest, err := DecodeKey(decodeHex(tc.input))
+		if err != nil {
+			t.Errorf("DecodeKey(%s): unexpected error: %v", tc.input, err)
+		} else if got != tc.want || !bytes.Equal(rest, decodeHex(tc.rest)) {
+			t.Errorf("DecodeKey(%s) = (%s, %x), want (%s, %s)", tc.input, got, rest, tc.want, tc.rest)
+		}
+	}
+
+	for _, input := range []string{"", "07", "01 02 0075", "03 0000", "04 02 00", "06 03 00", "01 ff"} {
+		if _, _, err := DecodeKey(decodeHex(input)); err == nil {
+			t.Errorf("DecodeKey(%s) should fail", input)
+		}
+	}
+}
+
+func TestDecodeIndexDataKey(t *testing.T) {
+	cases := []struct {
+		input      string
+		key        string
+		seq        int64
+		primaryKey string
+	}{
+		{"01 01 0061", `"a"`, -1, ""},
+		{"01 01 0061 05 03 000000000000f03f", `"a"`, 5, "1"},
+		{"04 01 00 8001 01 00", "[null]", 128, `""`},
+	}
+	for _, tc := range cases {
+		key, seq, primaryKey, err := DecodeIndexDataKey(decodeHex(tc.input))
+		if err != nil {
+			t.Errorf("DecodeIndexDataKey(%s): unexpected error: %v", tc.input, err)
+		} else if key != tc.key || seq != tc.seq || primaryKey != tc.primaryKey {
+			t.Errorf("DecodeIndexDataKey(%s) = (%s, %d, %s), want (%s, %d, %s)", tc.input, key, seq, primaryKey, tc.key, tc.seq, tc.primaryKey)
+		}
+	}
+
+	for _, input := range []string{"", "01 01 0061 05", "01 01 0061 80", "01 01 0061 05 07"} {
+		if _, _, _, err := DecodeIndexDataKey(decodeHex(input)); err == nil {
+			t.Errorf("DecodeIndexDataKey(%s) should fail", input)
+		}
+	}
+}
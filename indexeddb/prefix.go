@@ -39,15 +39,6 @@ func prefixByte(prefix []byte, nexts ...prefixComponent) ([]byte, []byte) {
 	return slices.Concat(start, startTail), nil
 }
 
-func validVarInt(prefix []byte) bool {
-	for i := 0; i < len(prefix) && i < 9; i++ {
-		if prefix[i]&0x80 == 0 {
-			return true
-		}
-	}
-	return false
-}
-
 func encodeVarInt(v int64) []byte {
 	u := uint64(v)
 	buf := make([]byte, 9)
@@ -102,11 +93,12 @@ func prefixVarInt(prefix []byte, nexts ...prefixComponent) ([]byte, []byte) {
 }
 
 func prefixBinary(prefix []byte, nexts ...prefixComponent) ([]byte, []byte) {
-	if !validVarInt(prefix) {
+	rest, length, err := decodeVarInt(prefix)
+	if err != nil {
 		return prefixVarInt(prefix)
 	}
 
-	prefix, length := decodeVarInt(prefix)
+	prefix = rest
 	body := prefix
 	if uint64(len(body)) > uint64(length) {
 		body = body[:length]
@@ -131,11 +123,12 @@ func prefixBinary(prefix []byte, nexts ...prefixComponent) ([]byte, []byte) {
 }
 
 func prefixStringWithLength(prefix []byte, nexts ...prefixComponent) ([]byte, []byte) {
-	if !validVarInt(prefix) {
+	rest, v, err := decodeVarInt(prefix)
+	if err != nil {
 		return prefixVarInt(prefix)
 	}
 
-	prefix, v := decodeVarInt(prefix)
+	prefix = rest
 	length := 2 * uint64(v)
 	body := prefix
 	if uint64(len(body)) > length {
@@ -198,11 +191,14 @@ func prefixEncodedIDBKeys(prefix []byte, nexts ...prefixComponent) ([]byte, []by
 		if len(prefix) == 0 {
 			break
 		}
-		if !validVarInt(prefix) {
+		rest, length, err := decodeVarInt(prefix)
+		if err != nil {
 			startTail, limitTail = prefixVarInt(prefix)
 			break
 		}
-		_, length := decodeVarInt(prefix)
+		// Each element occupies at least one byte, so no more elements
+		// than the remaining bytes can be constrained by the prefix.
+		length = min(length, int64(len(rest)))
 
 		elements := make([]prefixComponent, length)
 		for i := int64(0); i < length; i++ {
@@ -256,9 +252,9 @@ func prefixEncodedIDBKeys(prefix []byte, nexts ...prefixComponent) ([]byte, []by
 	return slices.Concat(start, startTail), limit
 }
 
-func prefixKeyBody(prefix []byte, k *keyPrefix) ([]byte, []byte) {
+func prefixKeyBody(prefix []byte, k *KeyPrefix) ([]byte, []byte) {
 	switch k.Type() {
-	case globalMetadata:
+	case GlobalMetadata:
 		switch prefix[0] {
 		case scopesPrefixByte:
 			return prefix, succBytes(prefix)
@@ -269,7 +265,7 @@ func prefixKeyBody(prefix []byte, k *keyPrefix) ([]byte, []byte) {
 		default:
 			return prefixByte(prefix)
 		}
-	case databaseMetadata:
+	case DatabaseMetadata:
 		switch prefix[0] {
 		case objectStoreMetaDataTypeByte:
 			return prefixByte(prefix, prefixVarInt, prefixByte)
@@ -286,20 +282,20 @@ func prefixKeyBody(prefix []byte, k *keyPrefix) ([]byte, []byte) {
 		default:
 			return prefixByte(prefix)
 		}
-	case objectStoreData:
+	case ObjectStoreData:
 		return prefixEncodedIDBKeys(prefix)
-	case existsEntry:
+	case ExistsEntry:
 		return prefixEncodedIDBKeys(prefix)
-	case blobEntry:
+	case BlobEntry:
 		return prefixEncodedIDBKeys(prefix)
-	case indexData:
+	case IndexData:
 		return prefixEncodedIDBKeys(prefix)
 	default:
 		return nil, nil
 	}
 }
 
-func encodeKeyPrefix(k *keyPrefix) []byte {
+func encodeKeyPrefix(k *KeyPrefix) []byte {
 	if k == nil {
 		return nil
 	}
@@ -322,8 +318,14 @@ func encodeKeyPrefix(k *keyPrefix) []byte {
 	return encoded
 }
 
-func succKeyPrefix(k *keyPrefix) *keyPrefix {
-	succ := &keyPrefix{
+// EncodeKeyPrefix encodes the given key prefix. Prefix of the result
+// covers all keys with the same database, object store and index IDs.
+func EncodeKeyPrefix(prefix KeyPrefix) []byte {
+	return encodeKeyPrefix(&prefix)
+}
+
+func succKeyPrefix(k *KeyPrefix) *KeyPrefix {
+	succ := &KeyPrefix{
 		DatabaseId:    k.DatabaseId,
 		ObjectStoreId: k.ObjectStoreId,
 		IndexId:       k.IndexId,
@@ -354,16 +356,17 @@ func prefixPartialKeyPrefix(prefix []byte) ([]byte, []byte) {
 	indexIdBytes := int((prefix[0] & 0x03) + 1)
 
 	prefix = prefix[1:]
-	mink := &keyPrefix{}
-	maxk := &keyPrefix{
+	mink := &KeyPrefix{}
+	maxk := &KeyPrefix{
 		DatabaseId:    math.MaxInt64,
 		ObjectStoreId: math.MaxInt64,
 		IndexId:       math.MaxUint32,
 	}
 
+	// All the lengths below are between 1 and 8, so decodeInt never fails.
 	if len(prefix) >= databaseIdBytes {
-		mink.DatabaseId = decodeInt(prefix[:databaseIdBytes])
-		maxk.DatabaseId = decodeInt(prefix[:databaseIdBytes])
+		mink.DatabaseId, _ = decodeInt(prefix[:databaseIdBytes])
+		maxk.DatabaseId = mink.DatabaseId
 		prefix = prefix[databaseIdBytes:]
 	} else {
 		if databaseIdBytes > 1 {
@@ -373,7 +376,7 @@ func prefixPartialKeyPrefix(prefix []byte) ([]byte, []byte) {
 			maxk.DatabaseId = (int64(1) << (8 * databaseIdBytes)) - 1
 		}
 		if len(prefix) > 0 {
-			v := decodeInt(prefix)
+			v, _ := decodeInt(prefix)
 			mink.DatabaseId |= v
 			maxk.DatabaseId &^= (int64(1) << (8 * len(prefix))) - 1
 			maxk.DatabaseId |= v
@@ -382,8 +385,8 @@ func prefixPartialKeyPrefix(prefix []byte) ([]byte, []byte) {
 	}
 
 	if len(prefix) >= objectStoreIdBytes {
-		mink.ObjectStoreId = decodeInt(prefix[:objectStoreIdBytes])
-		maxk.ObjectStoreId = decodeInt(prefix[:objectStoreIdBytes])
+		mink.ObjectStoreId, _ = decodeInt(prefix[:objectStoreIdBytes])
+		maxk.ObjectStoreId = mink.ObjectStoreId
 		prefix = prefix[objectStoreIdBytes:]
 	} else {
 		if objectStoreIdBytes > 1 {
@@ -393,7 +396,7 @@ func prefixPartialKeyPrefix(prefix []byte) ([]byte, []byte) {
 			maxk.ObjectStoreId = (int64(1) << (8 * objectStoreIdBytes)) - 1
 		}
 		if len(prefix) > 0 {
-			v := decodeInt(prefix)
+			v, _ := decodeInt(prefix)
 			mink.ObjectStoreId |= v
 			maxk.ObjectStoreId &^= (int64(1) << (8 * len(prefix))) - 1
 			maxk.ObjectStoreId |= v
@@ -406,7 +409,7 @@ func prefixPartialKeyPrefix(prefix []byte) ([]byte, []byte) {
 	}
 	maxk.IndexId = (int64(1) << (8 * indexIdBytes)) - 1
 	if len(prefix) > 0 {
-		v := decodeInt(prefix)
+		v, _ := decodeInt(prefix)
 		mink.IndexId |= v
 		maxk.IndexId &^= (int64(1) << (8 * len(prefix))) - 1
 		maxk.IndexId |= v
@@ -417,15 +420,12 @@ func prefixPartialKeyPrefix(prefix []byte) ([]byte, []byte) {
 }
 
 func prefixKeyPrefix(prefix []byte) ([]byte, []byte) {
-	databaseIdBytes := int((((prefix[0] >> 5) & 0x07) + 1))
-	objectStoreIdBytes := int(((prefix[0] >> 2) & 0x07) + 1)
-	indexIdBytes := int((prefix[0] & 0x03) + 1)
-
-	if len(prefix) < 1+databaseIdBytes+objectStoreIdBytes+indexIdBytes {
+	rest, keyPrefix, err := decodeKeyPrefix(prefix)
+	if err != nil {
 		return prefixPartialKeyPrefix(prefix)
 	}
 
-	prefix, keyPrefix := decodeKeyPrefix(prefix)
+	prefix = rest
 
 	var startTail, limitTail []byte
 	if len(prefix) > 0 {
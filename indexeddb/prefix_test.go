@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 
@@ -201,3 +202,174 @@ func TestPrefix(t *testing.T) {
 		}
 	}
 }
+
+type canonicalComponent func(b []byte) ([]byte, bool)
+
+func canonicalSeq(b []byte, components ...canonicalComponent) bool {
+	for _, component := range components {
+		var ok bool
+		if b, ok = component(b); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func canonicalByte(b []byte) ([]byte, bool) {
+	if len(b) == 0 {
+		return nil, false
+	}
+	return b[1:], true
+}
+
+func canonicalVarInt(b []byte) ([]byte, bool) {
+	rest, _, err := decodeVarInt(b)
+	if err != nil {
+		return nil, false
+	}
+	if n := len(b) - len(rest); n > 1 && b[n-1] == 0 {
+		return nil, false
+	}
+	return rest, true
+}
+
+func canonicalBytes(b []byte, unit int) ([]byte, bool) {
+	rest, ok := canonicalVarInt(b)
+	if !ok {
+		return nil, false
+	}
+	_, v, _ := decodeVarInt(b)
+	if uint64(len(rest))/uint64(unit) < uint64(v) {
+		return nil, false
+	}
+	return rest[v*int64(unit):], true
+}
+
+func canonicalBinary(b []byte) ([]byte, bool) {
+	return canonicalBytes(b, 1)
+}
+
+func canonicalString(b []byte) ([]byte, bool) {
+	return canonicalBytes(b, 2)
+}
+
+func canonicalIDBKey(b []byte) ([]byte, bool) {
+	if len(b) == 0 {
+		return nil, false
+	}
+	typeByte := b[0]
+	b = b[1:]
+
+	switch typeByte {
+	case indexedDBKeyNullTypeByte, indexedDBKeyMinKeyTypeByte:
+		return b, true
+	case indexedDBKeyArrayTypeByte:
+		rest, ok := canonicalVarInt(b)
+		if !ok {
+			return nil, false
+		}
+		_, length, _ := decodeVarInt(b)
+		for i := int64(0); i < length; i++ {
+			if rest, ok = canonicalIDBKey(rest); !ok {
+				return nil, false
+			}
+		}
+		return rest, true
+	case indexedDBKeyBinaryTypeByte:
+		return canonicalBinary(b)
+	case indexedDBKeyStringTypeByte:
+		return canonicalString(b)
+	case indexedDBKeyDateTypeByte, indexedDBKeyNumberTypeByte:
+		if len(b) < 8 {
+			return nil, false
+		}
+		return b[8:], true
+	default:
+		return nil, false
+	}
+}
+
+// canonicalKey reports whether all the components of the key are complete
+// and use the shortest encoding, as Chromium always writes keys.
+func canonicalKey(key []byte) bool {
+	body, prefix, err := decodeKeyPrefix(key)
+	if err != nil || !bytes.Equal(encodeKeyPrefix(prefix), key[:len(key)-len(body)]) {
+		return false
+	}
+	if prefix.DatabaseId < 0 || prefix.ObjectStoreId < 0 {
+		return false
+	}
+	if len(body) == 0 {
+		return true
+	}
+
+	switch prefix.Type() {
+	case GlobalMetadata:
+		switch body[0] {
+		case databaseFreeListTypeByte:
+			return canonicalSeq(body[1:], canonicalVarInt)
+		case databaseNameTypeByte:
+			return canonicalSeq(body[1:], canonicalString, canonicalString)
+		default:
+			return true
+		}
+	case DatabaseMetadata:
+		switch body[0] {
+		case objectStoreMetaDataTypeByte:
+			return canonicalSeq(body[1:], canonicalVarInt, canonicalByte)
+		case indexMetaDataTypeByte:
+			return canonicalSeq(body[1:], canonicalVarInt, canonicalVarInt, canonicalByte)
+		case objectStoreFreeListTypeByte:
+			return canonicalSeq(body[1:], canonicalVarInt)
+		case indexFreeListTypeByte:
+			return canonicalSeq(body[1:], canonicalVarInt, canonicalVarInt)
+		case objectStoreNamesTypeByte:
+			return canonicalSeq(body[1:], canonicalString)
+		case indexNamesKeyTypeByte:
+			return canonicalSeq(body[1:], canonicalVarInt, canonicalString)
+		default:
+			return true
+		}
+	case ObjectStoreData, ExistsEntry, BlobEntry:
+		return canonicalSeq(body, canonicalIDBKey)
+	case IndexData:
+		return canonicalSeq(body, canonicalIDBKey, canonicalVarInt, canonicalIDBKey)
+	default:
+		return false
+	}
+}
+
+func FuzzPrefix(f *testing.F) {
+	f.Add(decodeHex("00"), decodeHex("00 00 00"))
+	f.Add(decodeHex("25 ffff"), decodeHex("ff 0001"))
+	f.Add(decodeHex("00 00 00 00 c9 01"), decodeHex("0000 00"))
+	f.Add(decodeHex("00 01 00 00 64"), decodeHex("00 00 00"))
+	f.Add(decodeHex("00 01 01 01 04 02"), decodeHex("06 00 01 00"))
+	f.Add(decodeHex("00 01 01 1e 01 01"), decodeHex("0000 00 01 00"))
+
+	f.Fuzz(func(t *testing.T, prefix, suffix []byte) {
+		slice := Prefix(prefix)
+		if slice == nil {
+			return
+		}
+
+		if slice.Limit != nil {
+			if ret, err := compare(slice.Start, slice.Limit); err == nil && ret > 0 {
+				t.Errorf("Start(%x) = %x is greater than Limit(%x) = %x", prefix, slice.Start, prefix, slice.Limit)
+			}
+		}
+
+		key := slices.Concat(prefix, suffix)
+		if !canonicalKey(key) {
+			return
+		}
+		if ret, err := compare(slice.Start, key); err == nil && ret > 0 {
+			t.Errorf("Start(%x) = %x is greater than %x", prefix, slice.Start, key)
+		}
+		if slice.Limit != nil {
+			if ret, err := compare(key, slice.Limit); err == nil && ret >= 0 {
+				t.Errorf("Limit(%x) = %x is less than or equal to %x", prefix, slice.Limit, key)
+			}
+		}
+	})
+}
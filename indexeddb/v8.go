@@ -0,0 +1,649 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package indexeddb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// A V8 ValueSerializer payload is a version tag followed by one value.
+// Each value begins with a tag byte:
+//
+//	'_' '0' 'T' 'F'                               (undefined, null, true, false)
+//	'I' <ZigZag VarInt> 'U' <VarInt> 'N' <double> (int32, uint32, number)
+//	'Z' <bitfield: VarInt> <digits>               (BigInt)
+//	'S' '"' 'c' <length: VarInt> <bytes>          (UTF-8, Latin-1, UTF-16LE string)
+//	'o' (<key> <value>)... '{' <count: VarInt>    (object)
+//	'A' <length: VarInt> <value>... (<key> <value>)... '$' <count: VarInt> <length: VarInt>
+//	                                              (dense array)
+//	'a' <length: VarInt> (<key> <value>)... '@' <count: VarInt> <length: VarInt>
+//	                                              (sparse array)
+//	'D' <milliseconds: double>                    (Date)
+//	'y' 'x' 'n' <double> 'z' <BigInt> 's' <string>
+//	                                              (boxed true, false, number, BigInt, string)
+//	'R' <pattern: string> <flags: VarInt>         (RegExp)
+//	';' (<key> <value>)... ':' <length: VarInt>   (Map)
+//	'\'' <value>... ',' <length: VarInt>          (Set)
+//	'B' <length: VarInt> <bytes>                  (ArrayBuffer)
+//	'V' <subtag> <offset: VarInt> <length: VarInt> [<flags: VarInt>]
+//	                                              (view of the preceding ArrayBuffer)
+//	'^' <id: VarInt>                              (reference to an earlier object)
+//
+// Doubles are stored in little-endian byte order, and VarInts as in keys.
+// Padding ('\0') and object count ('?' <VarInt>) tags may precede a tag.
+//
+// References:
+//   https://chromium.googlesource.com/v8/v8/+/main/src/objects/value-serializer.cc
+
+// ErrUnsupportedValue is returned when a V8 payload holds a value that
+// DecodeV8Value does not decode, such as a Blob or an Error.
+var ErrUnsupportedValue = errors.New("indexeddb: unsupported V8 value")
+
+const (
+	v8VersionTag            = 0xFF
+	v8PaddingTag            = '\x00'
+	v8VerifyObjectCountTag  = '?'
+	v8TheHoleTag            = '-'
+	v8UndefinedTag          = '_'
+	v8NullTag               = '0'
+	v8TrueTag               = 'T'
+	v8FalseTag              = 'F'
+	v8Int32Tag              = 'I'
+	v8Uint32Tag             = 'U'
+	v8DoubleTag             = 'N'
+	v8BigIntTag             = 'Z'
+	v8Utf8StringTag         = 'S'
+	v8OneByteStringTag      = '"'
+	v8TwoByteStringTag      = 'c'
+	v8ObjectReferenceTag    = '^'
+	v8BeginJSObjectTag      = 'o'
+	v8EndJSObjectTag        = '{'
+	v8BeginSparseJSArrayTag = 'a'
+	v8EndSparseJSArrayTag   = '@'
+	v8BeginDenseJSArrayTag  = 'A'
+	v8EndDenseJSArrayTag    = '$'
+	v8DateTag               = 'D'
+	v8TrueObjectTag         = 'y'
+	v8FalseObjectTag        = 'x'
+	v8NumberObjectTag       = 'n'
+	v8BigIntObjectTag       = 'z'
+	v8StringObjectTag       = 's'
+	v8RegExpTag             = 'R'
+	v8BeginJSMapTag         = ';'
+	v8EndJSMapTag           = ':'
+	v8BeginJSSetTag         = '\''
+	v8EndJSSetTag           = ','
+	v8ArrayBufferTag        = 'B'
+	v8ArrayBufferViewTag    = 'V'
+
+	// maxSparseArrayLength bounds the length of sparse arrays, which are
+	// written out with null for every missing element.
+	maxSparseArrayLength = 1 << 16
+
+	// maxV8Depth bounds the nesting of objects, which are decoded
+	// recursively.
+	maxV8Depth = 1000
+)
+
+// DecodeV8Value decodes a V8 ValueSerializer payload, as returned by
+// StripValueHeader, into JSON. Values that JSON cannot represent are
+// converted: undefined and array holes become null, non-finite numbers,
+// BigInts and RegExps become strings, Dates become RFC 3339 strings (null
+// if invalid), Maps become arrays of [key, value] pairs, Sets become
+// arrays, ArrayBuffers and DataViews become hex strings, and typed arrays
+// become arrays of numbers. Properties of arrays are dropped.
+func DecodeV8Value(payload []byte) ([]byte, error) {
+	if len(payload) == 0 || payload[0] != v8VersionTag {
+		return nil, ErrInvalidValue
+	}
+	d := &v8Decoder{b: payload[1:]}
+	var err error
+	if d.version, err = d.varint(); err != nil {
+		return nil, err
+	}
+	return d.value()
+}
+
+type v8Decoder struct {
+	b       []byte
+	version int64
+	depth   int
+	// objects holds the decoded objects by id, for references. An
+	// object is nil until it is fully decoded.
+	objects [][]byte
+	// buffers holds the contents of ArrayBuffers by id, for views.
+	buffers map[int][]byte
+}
+
+func (d *v8Decoder) varint() (int64, error) {
+	rest, v, err := decodeVarInt(d.b)
+	if err != nil {
+		return 0, ErrInvalidValue
+	}
+	d.b = rest
+	return v, nil
+}
+
+func (d *v8Decoder) bytes(n int64) ([]byte, error) {
+	if n < 0 || int64(len(d.b)) < n {
+		return nil, ErrInvalidValue
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b, nil
+}
+
+func (d *v8Decoder) double() (float64, error) {
+	b, err := d.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// peek returns the next tag without consuming it, skipping padding and
+// object count tags.
+func (d *v8Decoder) peek() (byte, error) {
+	for len(d.b) > 0 {
+		switch d.b[0] {
+		case v8PaddingTag:
+			d.b = d.b[1:]
+		case v8VerifyObjectCountTag:
+			d.b = d.b[1:]
+			if _, err := d.varint(); err != nil {
+				return 0, err
+			}
+		default:
+			return d.b[0], nil
+		}
+	}
+	return 0, ErrInvalidValue
+}
+
+func (d *v8Decoder) tag() (byte, error) {
+	tag, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+	d.b = d.b[1:]
+	return tag, nil
+}
+
+// newObject reserves the id of an object that is being decoded.
+func (d *v8Decoder) newObject() int {
+	d.objects = append(d.objects, nil)
+	return len(d.objects) - 1
+}
+
+func (d *v8Decoder) string(tag byte) (string, error) {
+	n, err := d.varint()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.bytes(n)
+	if err != nil {
+		return "", err
+	}
+	switch tag {
+	case v8Utf8StringTag:
+		return string(b), nil
+	case v8OneByteStringTag:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes), nil
+	case v8TwoByteStringTag:
+		if len(b)%2 != 0 {
+			return "", ErrInvalidValue
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units)), nil
+	default:
+		return "", ErrInvalidValue
+	}
+}
+
+func (d *v8Decoder) bigint() (string, error) {
+	bitfield, err := d.varint()
+	if err != nil {
+		return "", err
+	}
+	digits, err := d.bytes(bitfield >> 1)
+	if err != nil {
+		return "", err
+	}
+	// The digits are in little-endian byte order.
+	digits = slices.Clone(digits)
+	slices.Reverse(digits)
+	v := new(big.Int).SetBytes(digits)
+	if bitfield&1 != 0 {
+		v.Neg(v)
+	}
+	return v.String(), nil
+}
+
+func appendJSONString(dst []byte, s string) []byte {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return append(dst, bytes.TrimSuffix(buf.Bytes(), []byte("\n"))...)
+}
+
+func appendJSONNumber(dst []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(dst, `"Infinity"`...)
+	case math.IsInf(f, -1):
+		return append(dst, `"-Infinity"`...)
+	default:
+		return strconv.AppendFloat(dst, f, 'g', -1, 64)
+	}
+}
+
+func appendJSONArray(dst []byte, elems [][]byte) []byte {
+	dst = append(dst, '[')
+	for i, e := range elems {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, e...)
+	}
+	return append(dst, ']')
+}
+
+// property is a decoded key and value of an object. The key is the JSON
+// string of the property name.
+type property struct {
+	key, value []byte
+}
+
+// properties decodes key-value pairs up to the end tag and its count.
+func (d *v8Decoder) properties(end byte) ([]property, error) {
+	var props []property
+	for {
+		tag, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if tag == end {
+			d.b = d.b[1:]
+			if _, err := d.varint(); err != nil {
+				return nil, err
+			}
+			return props, nil
+		}
+		key, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 || key[0] != '"' {
+			// Numeric keys are written as numbers.
+			key = appendJSONString(nil, string(key))
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, property{key, value})
+	}
+}
+
+// values decodes values up to the end tag and its length.
+func (d *v8Decoder) values(end byte) ([][]byte, error) {
+	var values [][]byte
+	for {
+		tag, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if tag == end {
+			d.b = d.b[1:]
+			if _, err := d.varint(); err != nil {
+				return nil, err
+			}
+			return values, nil
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+func (d *v8Decoder) value() ([]byte, error) {
+	tag, err := d.tag()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case v8UndefinedTag, v8NullTag, v8TheHoleTag:
+		return []byte("null"), nil
+	case v8TrueTag:
+		return []byte("true"), nil
+	case v8FalseTag:
+		return []byte("false"), nil
+	case v8Int32Tag:
+		u, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		v := int32(uint32(u)>>1) ^ -int32(u&1)
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case v8Uint32Tag:
+		u, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendUint(nil, uint64(uint32(u)), 10), nil
+	case v8DoubleTag:
+		f, err := d.double()
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONNumber(nil, f), nil
+	case v8BigIntTag:
+		s, err := d.bigint()
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONString(nil, s), nil
+	case v8Utf8StringTag, v8OneByteStringTag, v8TwoByteStringTag:
+		s, err := d.string(tag)
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONString(nil, s), nil
+	case v8ObjectReferenceTag:
+		id, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		if id < 0 || id >= int64(len(d.objects)) || d.objects[id] == nil {
+			// A reference to an object that encloses it, i.e. a cycle.
+			return nil, ErrUnsupportedValue
+		}
+		if buf, ok := d.buffers[int(id)]; ok && len(d.b) > 0 && d.b[0] == v8ArrayBufferViewTag {
+			d.b = d.b[1:]
+			return d.view(buf)
+		}
+		return d.objects[id], nil
+	}
+
+	if d.depth++; d.depth > maxV8Depth {
+		return nil, ErrUnsupportedValue
+	}
+	defer func() { d.depth-- }()
+
+	id := d.newObject()
+	var out []byte
+	switch tag {
+	case v8BeginJSObjectTag:
+		props, err := d.properties(v8EndJSObjectTag)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, '{')
+		for i, p := range props {
+			if i > 0 {
+				out = append(out, ',')
+			}
+			out = append(out, p.key...)
+			out = append(out, ':')
+			out = append(out, p.value...)
+		}
+		out = append(out, '}')
+	case v8BeginDenseJSArrayTag:
+		n, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || n > int64(len(d.b)) {
+			return nil, ErrInvalidValue
+		}
+		elems := make([][]byte, n)
+		for i := range elems {
+			if elems[i], err = d.value(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := d.properties(v8EndDenseJSArrayTag); err != nil {
+			return nil, err
+		}
+		if _, err := d.varint(); err != nil {
+			return nil, err
+		}
+		out = appendJSONArray(out, elems)
+	case v8BeginSparseJSArrayTag:
+		n, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || n > maxSparseArrayLength {
+			return nil, ErrUnsupportedValue
+		}
+		props, err := d.properties(v8EndSparseJSArrayTag)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.varint(); err != nil {
+			return nil, err
+		}
+		elems := make([][]byte, n)
+		for i := range elems {
+			elems[i] = []byte("null")
+		}
+		for _, p := range props {
+			var index string
+			if json.Unmarshal(p.key, &index) != nil {
+				continue
+			}
+			if i, err := strconv.ParseUint(index, 10, 32); err == nil && i < uint64(n) {
+				elems[i] = p.value
+			}
+		}
+		out = appendJSONArray(out, elems)
+	case v8DateTag:
+		f, err := d.double()
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			out = []byte("null")
+		} else {
+			out = appendJSONString(out, time.UnixMilli(int64(f)).UTC().Format(time.RFC3339Nano))
+		}
+	case v8TrueObjectTag:
+		out = []byte("true")
+	case v8FalseObjectTag:
+		out = []byte("false")
+	case v8NumberObjectTag:
+		f, err := d.double()
+		if err != nil {
+			return nil, err
+		}
+		out = appendJSONNumber(out, f)
+	case v8BigIntObjectTag:
+		s, err := d.bigint()
+		if err != nil {
+			return nil, err
+		}
+		out = appendJSONString(out, s)
+	case v8StringObjectTag, v8RegExpTag:
+		stag, err := d.tag()
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.string(stag)
+		if err != nil {
+			return nil, err
+		}
+		if tag == v8RegExpTag {
+			flags, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			s = "/" + s + "/" + regExpFlags(flags)
+		}
+		out = appendJSONString(out, s)
+	case v8BeginJSMapTag:
+		values, err := d.values(v8EndJSMapTag)
+		if err != nil {
+			return nil, err
+		}
+		if len(values)%2 != 0 {
+			return nil, ErrInvalidValue
+		}
+		pairs := make([][]byte, 0, len(values)/2)
+		for i := 0; i < len(values); i += 2 {
+			pairs = append(pairs, appendJSONArray(nil, values[i:i+2]))
+		}
+		out = appendJSONArray(out, pairs)
+	case v8BeginJSSetTag:
+		values, err := d.values(v8EndJSSetTag)
+		if err != nil {
+			return nil, err
+		}
+		out = appendJSONArray(out, values)
+	case v8ArrayBufferTag:
+		n, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		buf, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		out = appendJSONString(out, hex.EncodeToString(buf))
+		if d.buffers == nil {
+			d.buffers = make(map[int][]byte)
+		}
+		d.buffers[id] = buf
+		// A view is written right after its buffer.
+		if len(d.b) > 0 && d.b[0] == v8ArrayBufferViewTag {
+			d.objects[id] = out
+			d.b = d.b[1:]
+			return d.view(buf)
+		}
+	default:
+		return nil, ErrUnsupportedValue
+	}
+	d.objects[id] = out
+	return out, nil
+}
+
+// view decodes an ArrayBufferView of buf.
+func (d *v8Decoder) view(buf []byte) ([]byte, error) {
+	id := d.newObject()
+	subtag, err := d.bytes(1)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	length, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if d.version >= 14 {
+		if _, err := d.varint(); err != nil {
+			return nil, err
+		}
+	}
+	if offset < 0 || length < 0 || offset+length > int64(len(buf)) {
+		return nil, ErrInvalidValue
+	}
+	b := buf[offset : offset+length]
+
+	var size int
+	var elem func(b []byte) []byte
+	switch subtag[0] {
+	case 'b':
+		size, elem = 1, func(b []byte) []byte { return strconv.AppendInt(nil, int64(int8(b[0])), 10) }
+	case 'B', 'C':
+		size, elem = 1, func(b []byte) []byte { return strconv.AppendUint(nil, uint64(b[0]), 10) }
+	case 'w':
+		size, elem = 2, func(b []byte) []byte { return strconv.AppendInt(nil, int64(int16(binary.LittleEndian.Uint16(b))), 10) }
+	case 'W':
+		size, elem = 2, func(b []byte) []byte { return strconv.AppendUint(nil, uint64(binary.LittleEndian.Uint16(b)), 10) }
+	case 'd':
+		size, elem = 4, func(b []byte) []byte { return strconv.AppendInt(nil, int64(int32(binary.LittleEndian.Uint32(b))), 10) }
+	case 'D':
+		size, elem = 4, func(b []byte) []byte { return strconv.AppendUint(nil, uint64(binary.LittleEndian.Uint32(b)), 10) }
+	case 'f':
+		size, elem = 4, func(b []byte) []byte {
+			return appendJSONNumber(nil, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		}
+	case 'F':
+		size, elem = 8, func(b []byte) []byte {
+			return appendJSONNumber(nil, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+	case 'q':
+		size, elem = 8, func(b []byte) []byte {
+			return appendJSONString(nil, strconv.FormatInt(int64(binary.LittleEndian.Uint64(b)), 10))
+		}
+	case 'Q':
+		size, elem = 8, func(b []byte) []byte {
+			return appendJSONString(nil, strconv.FormatUint(binary.LittleEndian.Uint64(b), 10))
+		}
+	case '?':
+		d.objects[id] = appendJSONString(nil, hex.EncodeToString(b))
+		return d.objects[id], nil
+	default:
+		return nil, ErrUnsupportedValue
+	}
+	if len(b)%size != 0 {
+		return nil, ErrInvalidValue
+	}
+	elems := make([][]byte, 0, len(b)/size)
+	for i := 0; i < len(b); i += size {
+		elems = append(elems, elem(b[i:i+size]))
+	}
+	d.objects[id] = appendJSONArray(nil, elems)
+	return d.objects[id], nil
+}
+
+// regExpFlags returns the flags of a RegExp in the order of the flags
+// property of RegExp.prototype.
+func regExpFlags(flags int64) string {
+	var s []byte
+	for _, f := range []struct {
+		bit  int64
+		flag byte
+	}{
+		{1 << 7, 'd'},
+		{1 << 0, 'g'},
+		{1 << 1, 'i'},
+		{1 << 2, 'm'},
+		{1 << 5, 's'},
+		{1 << 4, 'u'},
+		{1 << 8, 'v'},
+		{1 << 3, 'y'},
+	} {
+		if flags&f.bit != 0 {
+			s = append(s, f.flag)
+		}
+	}
+	return string(s)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package indexeddb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func v8Double(f float64) string {
+	return string(binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+func TestDecodeV8Value(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"_", "null"},
+		{"0", "null"},
+		{"T", "true"},
+		{"F", "false"},
+		{"I\x54", "42"},
+		{"I\x53", "-42"},
+		{"U\x80\x01", "128"},
+		{"N" + v8Double(1.5), "1.5"},
+		{"N" + v8Double(math.NaN()), `"NaN"`},
+		{"N" + v8Double(math.Inf(-1)), `"-Infinity"`},
+		{"Z\x10\x00\x00\x00\x00\x00\x00\x00\x80", `"9223372036854775808"`},
+		{"Z\x11\x00\x00\x00\x00\x00\x00\x00\x80", `"-9223372036854775808"`},
+		{"\"\x05Alice", `"Alice"`},
+		{"\"\x02\xe9<", `"é<"`},
+		{"S\x02\xc3\xa9", `"é"`},
+		{"\x00c\x04A\x00\xe9\x00", `"Aé"`},
+		{"o\"\x04name\"\x05AliceI\x03\"\x01x{\x02", `{"name":"Alice","-2":"x"}`},
+		{"A\x02I\x02-$\x00\x02", "[1,null]"},
+		{"A\x01I\x02\"\x01pT$\x01\x01", "[1]"},
+		{"a\x03I\x02\"\x01x@\x01\x03", `[null,"x",null]`},
+		{"D" + v8Double(1700000000000), `"2023-11-14T22:13:20Z"`},
+		{"D" + v8Double(math.NaN()), "null"},
+		{"o\"\x01ao{\x00\"\x01b^\x01{\x02", `{"a":{},"b":{}}`},
+		{";\"\x01kI\x02:\x02", `[["k",1]]`},
+		{"'I\x02I\x04,\x02", "[1,2]"},
+		{"R\"\x03a.b\x03", `"/a.b/gi"`},
+		{"s\"\x01x", `"x"`},
+		{"y", "true"},
+		{"n" + v8Double(2), "2"},
+		{"B\x03\x01\x02\x03", `"010203"`},
+		{"B\x03\x01\x02\x03VB\x00\x03\x00", "[1,2,3]"},
+		{"B\x04\xff\xff\x02\x00Vw\x00\x04\x00", "[-1,2]"},
+		{"B\x04\xff\xff\x02\x00V?\x02\x02\x00", `"0200"`},
+		{"A\x02B\x01\x07^\x01VB\x00\x01\x00$\x00\x02", `["07",[7]]`},
+		{"?\x02o\"\x01aT{\x01", `{"a":true}`},
+	}
+
+	for _, tc := range cases {
+		got, err := DecodeV8Value([]byte("\xff\x0f" + tc.input))
+		if err != nil {
+			t.Errorf("DecodeV8Value(%q): unexpected error: %v", tc.input, err)
+		} else if string(got) != tc.want {
+			t.Errorf("DecodeV8Value(%q) = %s, want %s", tc.input, got, tc.want)
+		}
+	}
+
+	errCases := []struct {
+		input string
+		err   error
+	}{
+		{"", ErrInvalidValue},
+		{"\xff", ErrInvalidValue},
+		{"\xff\x0f", ErrInvalidValue},
+		{"\xff\x0fo", ErrInvalidValue},
+		{"\xff\x0f\"\x05abc", ErrInvalidValue},
+		{"\xff\x0fA\x02I\x02", ErrInvalidValue},
+		{"\xff\x0fc\x03abc", ErrInvalidValue},
+		{"\xff\x0fo\"\x01a^\x00{\x01", ErrUnsupportedValue},
+		{"\xff\x0f\\", ErrUnsupportedValue},
+		{"\xff\x0f" + strings.Repeat("A\x01", maxV8Depth+1), ErrUnsupportedValue},
+	}
+	for _, tc := range errCases {
+		if _, err := DecodeV8Value([]byte(tc.input)); !errors.Is(err, tc.err) {
+			t.Errorf("DecodeV8Value(%q): error = %v, want %v", tc.input, err, tc.err)
+		}
+	}
+}
+
+func FuzzDecodeV8Value(f *testing.F) {
+	f.Add([]byte("\xff\x0fo\"\x04name\"\x05Alice{\x01"))
+	f.Add([]byte("\xff\x0fA\x02B\x01\x07^\x01VB\x00\x01\x00$\x00\x02"))
+	f.Add([]byte("\xff\x0f;\"\x01kI\x02:\x02"))
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		got, err := DecodeV8Value(payload)
+		if err == nil && !json.Valid(got) {
+			t.Errorf("DecodeV8Value(%q) = %s, which is not valid JSON", payload, got)
+		}
+	})
+}
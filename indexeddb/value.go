@@ -0,0 +1,102 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package indexeddb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// Object store data values are laid out as follows:
+//
+//	<object version: VarInt> <SSV>
+//
+// where SSV is a value serialized by Blink's SerializedScriptValue:
+//
+//	0xFF <Blink version: varint>          (version tag)
+//	0xFE <offset: uint64> <size: uint32>  (trailer offset, Blink version >= 21)
+//	0xFF <V8 version: varint> ...         (V8 ValueSerializer payload)
+//
+// Values that are too large, or that compress well, are wrapped by
+// IndexedDB before being stored:
+//
+//	0xFF 0x11 0x01 <size: varint> <blob index: varint>  (stored in a blob)
+//	0xFF 0x11 0x02 <snappy-compressed SSV>               (compressed)
+//
+// References:
+//   https://chromium.googlesource.com/chromium/src/+/main/third_party/blink/renderer/bindings/core/v8/serialization/serialization_tag.h
+//   https://chromium.googlesource.com/chromium/src/+/main/third_party/blink/renderer/modules/indexeddb/idb_value_wrapping.cc
+
+// ErrInvalidValue is returned when a value is not a valid IndexedDB value.
+var ErrInvalidValue = errors.New("indexeddb: invalid value")
+
+// ErrBlobValue is returned when a value is stored in an external blob.
+var ErrBlobValue = errors.New("indexeddb: value is stored in a blob")
+
+const (
+	ssvVersionTag       = 0xFF
+	ssvTrailerOffsetTag = 0xFE
+	ssvTrailerOffsetLen = 12
+
+	requiresProcessingSSVPseudoVersion = 0x11
+	replaceWithBlob                    = 0x01
+	compressedWithSnappy               = 0x02
+)
+
+// StripValueHeader strips the object version and the Blink
+// SerializedScriptValue header from an object store data value and returns
+// the V8 ValueSerializer payload, which begins with its own version tag.
+// Compressed values are decompressed.
+func StripValueHeader(value []byte) ([]byte, error) {
+	payload, _, err := SplitValueHeader(value)
+	return payload, err
+}
+
+// SplitValueHeader is like StripValueHeader, but also returns the offset of
+// the payload in value. The offset is -1 for compressed values, whose
+// payload is not part of value.
+func SplitValueHeader(value []byte) ([]byte, int, error) {
+	rest, _, err := decodeVarInt(value)
+	if err != nil {
+		return nil, 0, ErrInvalidValue
+	}
+	compressed := false
+
+	if len(rest) >= 3 && rest[0] == ssvVersionTag && rest[1] == requiresProcessingSSVPseudoVersion {
+		switch rest[2] {
+		case replaceWithBlob:
+			return nil, 0, ErrBlobValue
+		case compressedWithSnappy:
+			if rest, err = snappy.Decode(nil, rest[3:]); err != nil {
+				return nil, 0, fmt.Errorf("%w: %w", ErrInvalidValue, err)
+			}
+			compressed = true
+		default:
+			return nil, 0, ErrInvalidValue
+		}
+	}
+
+	if len(rest) == 0 || rest[0] != ssvVersionTag {
+		return nil, 0, ErrInvalidValue
+	}
+	rest, version, err := decodeVarInt(rest[1:])
+	if err != nil {
+		return nil, 0, ErrInvalidValue
+	}
+	if version >= 21 && len(rest) > 0 && rest[0] == ssvTrailerOffsetTag {
+		if len(rest) < 1+ssvTrailerOffsetLen {
+			return nil, 0, ErrInvalidValue
+		}
+		rest = rest[1+ssvTrailerOffsetLen:]
+	}
+	if len(rest) == 0 || rest[0] != ssvVersionTag {
+		return nil, 0, ErrInvalidValue
+	}
+	if compressed {
+		return rest, -1, nil
+	}
+	return rest, len(value) - len(rest), nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package indexeddb
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+func TestStripValueHeader(t *testing.T) {
+	payload := []byte("\xff\x0f\x22\x03abc")
+	trailer := []byte("\xfe\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+
+	cases := []struct {
+		name   string
+		value  []byte
+		want   []byte
+		offset int
+		err    error
+	}{
+		{"plain", slices.Concat([]byte("\x01\xff\x14"), payload), payload, 3, nil},
+		{"trailer", slices.Concat([]byte("\x01\xff\x15"), trailer, payload), payload, 16, nil},
+		{"multi-byte version", slices.Concat([]byte("\x81\x01\xff\x15"), trailer, payload), payload, 17, nil},
+		{"compressed", slices.Concat([]byte("\x01\xff\x11\x02"), snappy.Encode(nil, slices.Concat([]byte("\xff\x15"), trailer, payload))), payload, -1, nil},
+		{"blob", []byte("\x01\xff\x11\x01\x80\x08\x00"), nil, 0, ErrBlobValue},
+		{"empty", []byte(""), nil, 0, ErrInvalidValue},
+		{"no ssv", []byte("\x01abc"), nil, 0, ErrInvalidValue},
+		{"truncated trailer", []byte("\x01\xff\x15\xfe\x00\x00"), nil, 0, ErrInvalidValue},
+		{"truncated version", []byte("\x01\xff\x95"), nil, 0, ErrInvalidValue},
+		{"no v8 header", []byte("\x01\xff\x14abc"), nil, 0, ErrInvalidValue},
+		{"bad compression", []byte("\x01\xff\x11\x02\xff\xff"), nil, 0, ErrInvalidValue},
+	}
+
+	for _, tc := range cases {
+		if _, offset, err := SplitValueHeader(tc.value); err == nil && offset != tc.offset {
+			t.Errorf("%s: SplitValueHeader(%q): offset = %d, want %d", tc.name, tc.value, offset, tc.offset)
+		}
+		got, err := StripValueHeader(tc.value)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%s: StripValueHeader(%q): got error %v, want %v", tc.name, tc.value, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: StripValueHeader(%q): unexpected error: %v", tc.name, tc.value, err)
+		} else if !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: StripValueHeader(%q) = %q, want %q", tc.name, tc.value, got, tc.want)
+		}
+	}
+}
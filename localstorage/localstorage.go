@@ -0,0 +1,80 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package localstorage
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// References:
+//   https://source.chromium.org/chromium/chromium/src/+/main:components/services/storage/dom_storage/local_storage_impl.cc
+//   https://source.chromium.org/chromium/chromium/src/+/main:components/services/storage/dom_storage/dom_storage_database.cc
+
+const (
+	utf16Format  = 0
+	latin1Format = 1
+)
+
+// ErrInvalidKey is returned when a key does not follow the LocalStorage key scheme.
+var ErrInvalidKey = errors.New("localstorage: invalid key")
+
+// ErrInvalidString is returned when a string does not have a valid format byte or encoding.
+var ErrInvalidString = errors.New("localstorage: invalid string")
+
+// DecodeString decodes a string prefixed with a format byte
+// (0 for UTF-16LE, 1 for Latin-1) into UTF-8.
+func DecodeString(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, ErrInvalidString
+	}
+	switch b[0] {
+	case utf16Format:
+		b = b[1:]
+		if len(b)%2 != 0 {
+			return nil, ErrInvalidString
+		}
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+		}
+		dst := make([]byte, 0, len(b))
+		for _, r := range utf16.Decode(u) {
+			dst = utf8.AppendRune(dst, r)
+		}
+		return dst, nil
+	case latin1Format:
+		dst := make([]byte, 0, len(b)-1)
+		for _, c := range b[1:] {
+			dst = utf8.AppendRune(dst, rune(c))
+		}
+		return dst, nil
+	default:
+		return nil, ErrInvalidString
+	}
+}
+
+// DecodeKey splits a data key of the form "_<origin>\x00<key>" into
+// the origin and the decoded script key.
+func DecodeKey(key []byte) (origin, scriptKey []byte, err error) {
+	if len(key) == 0 || key[0] != '_' {
+		return nil, nil, ErrInvalidKey
+	}
+	origin, rest, ok := bytes.Cut(key[1:], []byte{0})
+	if !ok {
+		return nil, nil, ErrInvalidKey
+	}
+	scriptKey, err = DecodeString(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return origin, scriptKey, nil
+}
+
+// DecodeValue decodes a value stored in a LocalStorage database.
+func DecodeValue(value []byte) ([]byte, error) {
+	return DecodeString(value)
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2021-2024 cions
+// Licensed under the MIT License. See LICENSE for details.
+
+package localstorage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeString(t *testing.T) {
+	cases := []struct {
+		input, want []byte
+	}{
+		{[]byte("\x00"), []byte("")},
+		{[]byte("\x01"), []byte("")},
+		{[]byte("\x00h\x00i\x00"), []byte("hi")},
+		{[]byte("\x00\x16\x4e\x4c\x75"), []byte("世界")},
+		{[]byte("\x00\x35\xd8\x3a\xdd"), []byte("\U0001d53a")},
+		{[]byte("\x01caf\xe9"), []byte("café")},
+		{[]byte(""), nil},
+		{[]byte("\x00a"), nil},
+		{[]byte("\x02abc"), nil},
+	}
+
+	for _, tc := range cases {
+		got, err := DecodeString(tc.input)
+		if tc.want == nil && err == nil {
+			t.Errorf("DecodeString(%q) should fail", tc.input)
+		} else if tc.want != nil && err != nil {
+			t.Errorf("DecodeString(%q): unexpected error: %v", tc.input, err)
+		} else if !bytes.Equal(got, tc.want) {
+			t.Errorf("DecodeString(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	cases := []struct {
+		input, origin, key []byte
+	}{
+		{[]byte("_https://example.com\x00\x01key"), []byte("https://example.com"), []byte("key")},
+		{[]byte("_https://example.com\x00\x00k\x00"), []byte("https://example.com"), []byte("k")},
+		{[]byte("META:https://example.com"), nil, nil},
+		{[]byte("VERSION"), nil, nil},
+		{[]byte("_https://example.com"), nil, nil},
+		{[]byte("_https://example.com\x00"), nil, nil},
+	}
+
+	for _, tc := range cases {
+		origin, key, err := DecodeKey(tc.input)
+		if tc.origin == nil && err == nil {
+			t.Errorf("DecodeKey(%q) should fail", tc.input)
+		} else if tc.origin != nil && err != nil {
+			t.Errorf("DecodeKey(%q): unexpected error: %v", tc.input, err)
+		} else if !bytes.Equal(origin, tc.origin) || !bytes.Equal(key, tc.key) {
+			t.Errorf("DecodeKey(%q) = (%q, %q), want (%q, %q)", tc.input, origin, key, tc.origin, tc.key)
+		}
+	}
+}